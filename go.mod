@@ -3,14 +3,17 @@ module github.com/openshift-metal3/terraform-provider-ironic
 go 1.16
 
 require (
+	github.com/aws/aws-sdk-go v1.37.0 // indirect
 	github.com/gophercloud/gophercloud v0.22.0
 	github.com/gophercloud/utils v0.0.0-20210720165645-8a3ad2ad9e70
 	github.com/hashicorp/go-retryablehttp v0.7.0
 	github.com/hashicorp/go-version v1.3.0
-	github.com/hashicorp/terraform-plugin-sdk v1.17.2
+	github.com/hashicorp/hcl/v2 v2.8.2 // indirect
+	github.com/hashicorp/terraform-plugin-sdk/v2 v2.10.1
 	github.com/metal3-io/baremetal-operator v0.0.0-20220310151803-2b47127ed7ae
 	github.com/metal3-io/baremetal-operator/apis v0.0.0
 	golang.org/x/lint v0.0.0-20210508222113-6edffad5e616 // indirect
+	google.golang.org/api v0.34.0 // indirect
 )
 
 replace (
@@ -0,0 +1,391 @@
+package ironic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/drivers"
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/ports"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+	"github.com/gophercloud/gophercloud/pagination"
+	"github.com/hashicorp/go-version"
+)
+
+// IronicClient is the set of node operations the provider performs against Ironic. The resources and the provision
+// state workflow only use nodes through this interface, so tests can drive them with a fake instead of a live Ironic.
+type IronicClient interface {
+	CreateNode(opts nodes.CreateOptsBuilder) (*nodes.Node, error)
+
+	// GetNode decodes the node into node, which lets callers read fields gophercloud doesn't know about yet.
+	GetNode(uuid string, node interface{}) error
+
+	UpdateNode(uuid string, opts nodes.UpdateOpts) (*nodes.Node, error)
+	DeleteNode(uuid string) error
+	ChangePowerState(uuid string, opts nodes.PowerStateOptsBuilder) error
+	ChangeProvisionState(uuid string, opts nodes.ProvisionStateOptsBuilder) error
+	SetRAIDConfig(uuid string, opts nodes.RAIDConfigOptsBuilder) error
+
+	// ListBIOSSettings returns the node's cached BIOS settings, including whether they're read-only if the
+	// microversion is 1.74 or later.
+	ListBIOSSettings(uuid string) ([]nodes.BIOSSetting, error)
+
+	// GetDriverDiskProperties returns the logical disk properties the driver's default RAID interface supports, with
+	// a description of each.
+	GetDriverDiskProperties(driver string) (map[string]interface{}, error)
+
+	// GetDriver returns the details of a driver, including the hardware interfaces enabled for it.
+	GetDriver(driver string) (*drivers.Driver, error)
+
+	// ListNodes returns every node matching the filters, with the fields not present in nodes.Node. Filtering by shard
+	// requires microversion 1.82 or later, and by retired 1.61 or later.
+	ListNodes(opts nodeListOpts) ([]extendedNode, error)
+
+	// GetBootState returns the node's current boot mode and secure boot state, or nil if the microversion is older
+	// than 1.75, which first reports them.
+	GetBootState(uuid string) (*nodeBootState, error)
+
+	// GetConsole returns whether the node's console is enabled, and how to connect to it if it is.
+	GetConsole(uuid string) (*nodeConsole, error)
+
+	// ListConductors returns every conductor, including the ones that are no longer alive. Requires microversion 1.49
+	// or later.
+	ListConductors() ([]conductor, error)
+
+	// GetConductor returns the conductor with the hostname. Requires microversion 1.49 or later.
+	GetConductor(hostname string) (*conductor, error)
+
+	// ListNodePorts returns every port of the node.
+	ListNodePorts(uuid string) ([]ports.Port, error)
+
+	CreatePort(opts ports.CreateOptsBuilder) (*ports.Port, error)
+	DeletePort(uuid string) error
+
+	// ListVendorPassthruMethods returns the vendor passthru methods the node's vendor interface provides, by name.
+	ListVendorPassthruMethods(uuid string) (map[string]vendorPassthruMethod, error)
+
+	// CallVendorPassthru calls a synchronous vendor passthru method on the node with the HTTP method, and decodes
+	// what it returns into result.
+	CallVendorPassthru(uuid string, method string, httpMethod string, result interface{}) error
+
+	// AddTrait and RemoveTrait add a trait to, or remove a trait from, the node. Requires microversion 1.37 or later.
+	AddTrait(uuid string, trait string) error
+	RemoveTrait(uuid string, trait string) error
+
+	// RetryJitter returns whether requests Ironic rejected as busy are retried with a randomized backoff.
+	RetryJitter() bool
+
+	// ProjectID returns the ID of the project the provider authenticated as, from its token, or "" if the provider
+	// didn't authenticate with a token scoped to a project.
+	ProjectID() string
+
+	// WithContext returns a client whose requests are made with ctx instead.
+	WithContext(ctx context.Context) IronicClient
+
+	// Microversion returns the API microversion requests are made with.
+	Microversion() string
+}
+
+// nodeListOpts filters the nodes listed. It's like nodes.ListOpts, with the filters gophercloud doesn't know about
+// yet, and maintenance and retired as pointers so nodes that aren't in maintenance or retired can be listed too.
+type nodeListOpts struct {
+	ProvisionState string `q:"provision_state"`
+	Driver         string `q:"driver"`
+	Maintenance    *bool  `q:"maintenance"`
+	ConductorGroup string `q:"conductor_group"`
+	ResourceClass  string `q:"resource_class"`
+	Owner          string `q:"owner"`
+	Lessee         string `q:"lessee"`
+	Fault          string `q:"fault"`
+	Shard          string `q:"shard"`
+	Retired        *bool  `q:"retired"`
+}
+
+// ToNodeListQuery formats the filters as a query string.
+func (opts nodeListOpts) ToNodeListQuery() (string, error) {
+	q, err := gophercloud.BuildQueryString(opts)
+	return q.String(), err
+}
+
+// ToNodeListDetailQuery formats the filters as a query string, for listing the nodes with their details.
+func (opts nodeListOpts) ToNodeListDetailQuery() (string, error) {
+	return opts.ToNodeListQuery()
+}
+
+// vendorPassthruMethod describes a vendor passthru method of a node's vendor interface.
+type vendorPassthruMethod struct {
+	HTTPMethods []string `json:"http_methods"`
+	Async       bool     `json:"async"`
+	Description string   `json:"description"`
+}
+
+// nodeBootState is the boot mode and secure boot state of a node, from its states. Either is nil if the node's
+// management interface can't tell.
+type nodeBootState struct {
+	BootMode   *string `json:"boot_mode"`
+	SecureBoot *bool   `json:"secure_boot"`
+}
+
+// nodeConsole is the state of a node's serial console. ConsoleInfo is nil unless the console is enabled.
+type nodeConsole struct {
+	ConsoleEnabled bool             `json:"console_enabled"`
+	ConsoleInfo    *nodeConsoleInfo `json:"console_info"`
+}
+
+// nodeConsoleInfo is how to connect to an enabled console, Type is e.g. shellinabox or socat.
+type nodeConsoleInfo struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// gophercloudIronicClient implements IronicClient with gophercloud.
+type gophercloudIronicClient struct {
+	client      *gophercloud.ServiceClient
+	retryJitter bool
+}
+
+func (c *gophercloudIronicClient) CreateNode(opts nodes.CreateOptsBuilder) (*nodes.Node, error) {
+	return nodes.Create(c.client, opts).Extract()
+}
+
+func (c *gophercloudIronicClient) GetNode(uuid string, node interface{}) error {
+	result := nodes.Get(c.client, uuid)
+	if result.Err != nil {
+		return result.Err
+	}
+
+	// ExtractInto only decodes into structs, decoding the raw body ourselves also allows maps
+	b, err := json.Marshal(result.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, node)
+}
+
+func (c *gophercloudIronicClient) UpdateNode(uuid string, opts nodes.UpdateOpts) (*nodes.Node, error) {
+	return nodes.Update(c.client, uuid, opts).Extract()
+}
+
+func (c *gophercloudIronicClient) DeleteNode(uuid string) error {
+	return nodes.Delete(c.client, uuid).ExtractErr()
+}
+
+func (c *gophercloudIronicClient) ChangePowerState(uuid string, opts nodes.PowerStateOptsBuilder) error {
+	return nodes.ChangePowerState(c.client, uuid, opts).ExtractErr()
+}
+
+func (c *gophercloudIronicClient) ChangeProvisionState(uuid string, opts nodes.ProvisionStateOptsBuilder) error {
+	return nodes.ChangeProvisionState(c.client, uuid, opts).ExtractErr()
+}
+
+func (c *gophercloudIronicClient) SetRAIDConfig(uuid string, opts nodes.RAIDConfigOptsBuilder) error {
+	return nodes.SetRAIDConfig(c.client, uuid, opts).ExtractErr()
+}
+
+func (c *gophercloudIronicClient) ListBIOSSettings(uuid string) ([]nodes.BIOSSetting, error) {
+	var opts nodes.ListBIOSSettingsOptsBuilder
+	if actual, err := version.NewVersion(c.client.Microversion); err == nil && !actual.LessThan(biosSettingsDetailVersion) {
+		opts = nodes.ListBIOSSettingsOpts{Detail: true}
+	}
+	return nodes.ListBIOSSettings(c.client, uuid, opts).Extract()
+}
+
+func (c *gophercloudIronicClient) GetDriverDiskProperties(driver string) (map[string]interface{}, error) {
+	properties, err := drivers.GetDriverDiskProperties(c.client, driver).Extract()
+	if err != nil {
+		return nil, err
+	}
+	return *properties, nil
+}
+
+func (c *gophercloudIronicClient) GetDriver(driver string) (*drivers.Driver, error) {
+	return drivers.GetDriverDetails(c.client, driver).Extract()
+}
+
+func (c *gophercloudIronicClient) ListNodes(opts nodeListOpts) ([]extendedNode, error) {
+	if opts.Shard != "" {
+		if err := checkShardSupport(c.client.Microversion); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Retired != nil {
+		if err := checkRetiredSupport(c.client.Microversion); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []extendedNode
+	err := nodes.ListDetail(c.client, opts).EachPage(func(page pagination.Page) (bool, error) {
+		var pageNodes []extendedNode
+		if err := nodes.ExtractNodesInto(page, &pageNodes); err != nil {
+			return false, err
+		}
+		result = append(result, pageNodes...)
+		return true, nil
+	})
+	return result, err
+}
+
+func (c *gophercloudIronicClient) GetBootState(uuid string) (*nodeBootState, error) {
+	if actual, err := version.NewVersion(c.client.Microversion); err != nil || actual.LessThan(bootStateVersion) {
+		return nil, nil
+	}
+
+	// gophercloud doesn't read the node's states yet
+	var state nodeBootState
+	if _, err := c.client.Get(c.client.ServiceURL("nodes", uuid, "states"), &state, nil); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (c *gophercloudIronicClient) GetConsole(uuid string) (*nodeConsole, error) {
+	// gophercloud doesn't read the node's console yet
+	var console nodeConsole
+	if _, err := c.client.Get(c.client.ServiceURL("nodes", uuid, "states", "console"), &console, nil); err != nil {
+		return nil, err
+	}
+	return &console, nil
+}
+
+func (c *gophercloudIronicClient) ListConductors() ([]conductor, error) {
+	return listConductors(c.client)
+}
+
+func (c *gophercloudIronicClient) GetConductor(hostname string) (*conductor, error) {
+	return getConductor(c.client, hostname)
+}
+
+func (c *gophercloudIronicClient) ListNodePorts(uuid string) ([]ports.Port, error) {
+	var result []ports.Port
+	err := ports.ListDetail(c.client, ports.ListOpts{NodeUUID: uuid}).EachPage(func(page pagination.Page) (bool, error) {
+		actual, err := ports.ExtractPorts(page)
+		if err != nil {
+			return false, err
+		}
+		result = append(result, actual...)
+		return true, nil
+	})
+	return result, err
+}
+
+func (c *gophercloudIronicClient) CreatePort(opts ports.CreateOptsBuilder) (*ports.Port, error) {
+	return ports.Create(c.client, opts).Extract()
+}
+
+func (c *gophercloudIronicClient) DeletePort(uuid string) error {
+	return ports.Delete(c.client, uuid).ExtractErr()
+}
+
+func (c *gophercloudIronicClient) ListVendorPassthruMethods(uuid string) (map[string]vendorPassthruMethod, error) {
+	// gophercloud doesn't implement vendor passthru yet
+	var result map[string]vendorPassthruMethod
+	if _, err := c.client.Get(c.client.ServiceURL("nodes", uuid, "vendor_passthru", "methods"), &result, nil); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *gophercloudIronicClient) CallVendorPassthru(uuid string, method string, httpMethod string, result interface{}) error {
+	passthruURL := c.client.ServiceURL("nodes", uuid, "vendor_passthru") + "?method=" + url.QueryEscape(method)
+	var err error
+	if httpMethod == "GET" {
+		_, err = c.client.Get(passthruURL, result, nil)
+	} else {
+		_, err = c.client.Post(passthruURL, map[string]interface{}{}, result, &gophercloud.RequestOpts{OkCodes: []int{200}})
+	}
+	return err
+}
+
+func (c *gophercloudIronicClient) AddTrait(uuid string, trait string) error {
+	// gophercloud doesn't implement the node traits API yet
+	_, err := c.client.Put(c.client.ServiceURL("nodes", uuid, "traits", trait), nil, nil, &gophercloud.RequestOpts{
+		OkCodes: []int{204},
+	})
+	return err
+}
+
+func (c *gophercloudIronicClient) RemoveTrait(uuid string, trait string) error {
+	_, err := c.client.Delete(c.client.ServiceURL("nodes", uuid, "traits", trait), nil)
+	return err
+}
+
+func (c *gophercloudIronicClient) RetryJitter() bool {
+	return c.retryJitter
+}
+
+func (c *gophercloudIronicClient) WithContext(ctx context.Context) IronicClient {
+	return &gophercloudIronicClient{client: withContext(ctx, c.client), retryJitter: c.retryJitter}
+}
+
+func (c *gophercloudIronicClient) Microversion() string {
+	return c.client.Microversion
+}
+
+func (c *gophercloudIronicClient) ProjectID() string {
+	if c.client.ProviderClient == nil {
+		return ""
+	}
+	// Both the token created when authenticating and one that's fetched tell us its project
+	result, ok := c.client.ProviderClient.GetAuthResult().(interface {
+		ExtractProject() (*tokens.Project, error)
+	})
+	if !ok {
+		return ""
+	}
+	project, err := result.ExtractProject()
+	if err != nil || project == nil {
+		return ""
+	}
+	return project.ID
+}
+
+// biosSettingsDetailVersion is the first microversion which says which BIOS settings are read-only.
+var biosSettingsDetailVersion = version.Must(version.NewVersion("1.74"))
+
+// shardVersion is the first microversion which supports node shards.
+var shardVersion = version.Must(version.NewVersion("1.82"))
+
+// checkShardSupport returns an error unless the microversion supports node shards.
+func checkShardSupport(microversion string) error {
+	if actual, err := version.NewVersion(microversion); err != nil || actual.LessThan(shardVersion) {
+		return fmt.Errorf("node shards require microversion %s or later, got '%s'", shardVersion.Original(), microversion)
+	}
+	return nil
+}
+
+// retiredVersion is the first microversion which supports retiring nodes.
+var retiredVersion = version.Must(version.NewVersion("1.61"))
+
+// checkRetiredSupport returns an error unless the microversion supports filtering nodes by whether they're retired.
+func checkRetiredSupport(microversion string) error {
+	if actual, err := version.NewVersion(microversion); err != nil || actual.LessThan(retiredVersion) {
+		return fmt.Errorf("filtering nodes by retired requires microversion %s or later, got '%s'", retiredVersion.Original(), microversion)
+	}
+	return nil
+}
+
+// checkRetirementSupport returns an error unless the microversion supports retiring nodes.
+func checkRetirementSupport(microversion string) error {
+	if actual, err := version.NewVersion(microversion); err != nil || actual.LessThan(retiredVersion) {
+		return fmt.Errorf("retiring nodes requires microversion %s or later, got '%s'", retiredVersion.Original(), microversion)
+	}
+	return nil
+}
+
+// deployTemplateVersion is the first microversion which supports deploy templates.
+var deployTemplateVersion = version.Must(version.NewVersion("1.55"))
+
+// checkDeployTemplateSupport returns an error unless the microversion supports deploy templates.
+func checkDeployTemplateSupport(microversion string) error {
+	if actual, err := version.NewVersion(microversion); err != nil || actual.LessThan(deployTemplateVersion) {
+		return fmt.Errorf("deploy templates require microversion %s or later, got '%s'", deployTemplateVersion.Original(), microversion)
+	}
+	return nil
+}
+
+// bootStateVersion is the first microversion which reports the node's current boot mode and secure boot state.
+var bootStateVersion = version.Must(version.NewVersion("1.75"))
@@ -0,0 +1,183 @@
+package ironic
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// conductor represents an Ironic conductor, gophercloud doesn't yet implement the conductors API. Requires
+// microversion 1.49 or later.
+type conductor struct {
+	Hostname       string   `json:"hostname"`
+	ConductorGroup string   `json:"conductor_group"`
+	Alive          bool     `json:"alive"`
+	Drivers        []string `json:"drivers"`
+
+	// When the conductor registered, and when it last checked in, which is empty until it first does
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// conductorPage is a page of conductors, with the link to the next page if there's more.
+type conductorPage struct {
+	Conductors []conductor `json:"conductors"`
+	Next       string      `json:"next"`
+}
+
+// listConductors fetches every conductor, with details, following the pages Ironic returns.
+func listConductors(client *gophercloud.ServiceClient) ([]conductor, error) {
+	var result []conductor
+	url := client.ServiceURL("conductors") + "?detail=true"
+	for url != "" {
+		var page conductorPage
+		if _, err := client.Get(url, &page, nil); err != nil {
+			return nil, err
+		}
+		result = append(result, page.Conductors...)
+		url = page.Next
+	}
+	return result, nil
+}
+
+// groupsWithoutAliveConductor returns the conductor groups, sorted, where every conductor is dead. Nodes in those groups
+// can't be provisioned until one of the group's conductors comes back.
+func groupsWithoutAliveConductor(conductors []conductor) []string {
+	alive := make(map[string]bool)
+	for _, c := range conductors {
+		group := strings.ToLower(c.ConductorGroup)
+		alive[group] = alive[group] || c.Alive
+	}
+
+	groups := make([]string, 0)
+	for group, ok := range alive {
+		if !ok {
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// getConductor fetches a single conductor by hostname.
+func getConductor(client *gophercloud.ServiceClient, hostname string) (*conductor, error) {
+	var result conductor
+	_, err := client.Get(client.ServiceURL("conductors", hostname), &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// checkConductorAffinity returns a warning when a node in a conductor group isn't managed by a conductor in that
+// group. Such a node can't be provisioned until a conductor serving the group comes up.
+func checkConductorAffinity(api IronicClient, node *extendedNode) diag.Diagnostics {
+	if node.ConductorGroup == "" {
+		return nil
+	}
+
+	conductorGroup := ""
+	if node.Conductor != "" {
+		c, err := api.GetConductor(node.Conductor)
+		if err != nil {
+			log.Printf("[DEBUG] Could not get conductor %s of node %s: %s", node.Conductor, node.UUID, err)
+			return nil
+		}
+		conductorGroup = c.ConductorGroup
+	}
+
+	if warning := conductorAffinityWarning(node.ConductorGroup, node.Conductor, conductorGroup); warning != "" {
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Node %s %s", node.UUID, warning),
+		}}
+	}
+	return nil
+}
+
+// conductorAffinityWarning describes why a node in group isn't served by a conductor in that group, if it isn't.
+func conductorAffinityWarning(group, conductor, conductorGroup string) string {
+	switch {
+	case conductor == "":
+		return fmt.Sprintf("is in conductor group '%s' but no conductor manages it, it may be unschedulable until a conductor serving the group is running", group)
+	case !strings.EqualFold(group, conductorGroup):
+		return fmt.Sprintf("is in conductor group '%s' but is managed by conductor %s in group '%s'", group, conductor, conductorGroup)
+	default:
+		return ""
+	}
+}
+
+// waitForNodeUnlocked waits for a node to no longer be reserved by a conductor, until ctx is done.
+func waitForNodeUnlocked(ctx context.Context, api IronicClient, uuid string) error {
+	checkInterval := 5 * time.Second
+
+	for {
+		var node nodes.Node
+		if err := api.GetNode(uuid, &node); err != nil {
+			return err
+		}
+
+		if node.Reservation == "" {
+			return nil
+		}
+
+		log.Printf("[DEBUG] Node %s is locked by %s, waiting for it to be released", uuid, node.Reservation)
+		select {
+		case <-time.After(checkInterval):
+		case <-ctx.Done():
+			return fmt.Errorf("node %s is still locked by %s: %w", uuid, node.Reservation, ctx.Err())
+		}
+	}
+}
+
+// changeConductorGroup moves a node to a different conductor group, and waits for a live conductor in that group to
+// take over the node. Both waits are bounded by ctx.
+func changeConductorGroup(ctx context.Context, api IronicClient, uuid, group string) error {
+	if err := waitForNodeUnlocked(ctx, api, uuid); err != nil {
+		return err
+	}
+
+	_, err := UpdateNode(ctx, api, uuid, nodes.UpdateOpts{
+		nodes.UpdateOperation{
+			Op:    nodes.ReplaceOp,
+			Path:  "/conductor_group",
+			Value: group,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	checkInterval := 5 * time.Second
+
+	for {
+		node, err := getExtendedNode(api, uuid)
+		if err != nil {
+			return err
+		}
+
+		if node.Conductor != "" {
+			c, err := api.GetConductor(node.Conductor)
+			if err != nil {
+				return fmt.Errorf("could not get conductor %s: %s", node.Conductor, err)
+			}
+			if c.Alive && strings.EqualFold(c.ConductorGroup, group) {
+				log.Printf("[DEBUG] Node %s is now managed by conductor %s in group '%s'", uuid, c.Hostname, group)
+				return nil
+			}
+		}
+
+		select {
+		case <-time.After(checkInterval):
+		case <-ctx.Done():
+			return fmt.Errorf("no live conductor serves conductor group '%s' for node %s: %w", group, uuid, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// +build acceptance
+
+package ironic
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
+)
+
+func TestConductorAffinityWarning(t *testing.T) {
+	cases := []struct {
+		Scenario       string
+		Conductor      string
+		ConductorGroup string
+		Expected       string
+	}{
+		{
+			Scenario:       "managed by a conductor in the group",
+			Conductor:      "conductor-0",
+			ConductorGroup: "Rack-1",
+		},
+		{
+			Scenario: "no conductor",
+			Expected: "is in conductor group 'rack-1' but no conductor manages it, it may be unschedulable until a conductor serving the group is running",
+		},
+		{
+			Scenario:  "conductor in another group",
+			Conductor: "conductor-0",
+			Expected:  "is in conductor group 'rack-1' but is managed by conductor conductor-0 in group ''",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			if actual := conductorAffinityWarning("rack-1", c.Conductor, c.ConductorGroup); actual != c.Expected {
+				t.Errorf("expected warning %q, got %q", c.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestWaitForNodeUnlocked(t *testing.T) {
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+		"node-0": {"uuid": "node-0", "reservation": "conductor-1"},
+		"node-1": {"uuid": "node-1"},
+	}}
+
+	if err := waitForNodeUnlocked(context.Background(), api, "node-1"); err != nil {
+		t.Errorf("expected an unlocked node not to be waited on, got: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	err := waitForNodeUnlocked(ctx, api, "node-0")
+	th.AssertError(t, err, "node node-0 is still locked by conductor-1")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to be bounded by the context, got: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package ironic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Schema resource for a data source that lists the conductors and whether they're alive, so operators can detect
+// dead conductors and the conductor groups left without one.
+func dataSourceIronicConductors() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceIronicConductorsRead,
+		Schema: map[string]*schema.Schema{
+			"conductors": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"hostname": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"conductor_group": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"alive": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"drivers": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"updated_at": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "When the conductor last checked in, or registered if it hasn't since",
+						},
+					},
+				},
+			},
+			"groups_without_alive_conductor": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The conductor groups whose conductors are all dead, so their nodes can't be provisioned",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func dataSourceIronicConductorsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	conductors, err := api.ListConductors()
+	if err != nil {
+		return diag.Errorf("could not list conductors: %s", err)
+	}
+	sort.Slice(conductors, func(i, j int) bool { return conductors[i].Hostname < conductors[j].Hostname })
+
+	hostnames := make([]string, 0, len(conductors))
+	flattened := make([]interface{}, 0, len(conductors))
+	for _, c := range conductors {
+		hostnames = append(hostnames, c.Hostname)
+		updatedAt := c.UpdatedAt
+		if updatedAt == "" {
+			updatedAt = c.CreatedAt
+		}
+		flattened = append(flattened, map[string]interface{}{
+			"hostname":        c.Hostname,
+			"conductor_group": c.ConductorGroup,
+			"alive":           c.Alive,
+			"drivers":         c.Drivers,
+			"updated_at":      updatedAt,
+		})
+	}
+
+	err = d.Set("conductors", flattened)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("groups_without_alive_conductor", groupsWithoutAliveConductor(conductors))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(fmt.Sprintf("%d", schema.HashString(strings.Join(hostnames, ","))))
+	return nil
+}
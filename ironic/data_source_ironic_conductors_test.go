@@ -0,0 +1,49 @@
+// +build acceptance
+
+package ironic
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
+)
+
+func TestDataSourceIronicConductorsRead(t *testing.T) {
+	api := &fakeIronicClient{conductors: []conductor{
+		{Hostname: "conductor-2", ConductorGroup: "rack-2", Alive: false, CreatedAt: "2026-10-01T10:00:00+00:00"},
+		{Hostname: "conductor-1", ConductorGroup: "rack-1", Alive: false, UpdatedAt: "2026-10-13T12:00:00+00:00"},
+		{Hostname: "conductor-0", ConductorGroup: "", Alive: true, Drivers: []string{"ipmi", "redfish"}, UpdatedAt: "2026-10-14T09:00:00+00:00"},
+		{Hostname: "conductor-3", ConductorGroup: "RACK-1", Alive: true, UpdatedAt: "2026-10-14T09:00:00+00:00"},
+	}}
+
+	d := schema.TestResourceDataRaw(t, dataSourceIronicConductors().Schema, map[string]interface{}{})
+	th.AssertNoDiagErrors(t, dataSourceIronicConductorsRead(context.Background(), d, &Clients{ironicAPI: api}))
+
+	conductors := d.Get("conductors").([]interface{})
+	hostnames := make([]string, 0, len(conductors))
+	for _, c := range conductors {
+		hostnames = append(hostnames, c.(map[string]interface{})["hostname"].(string))
+	}
+	if expected := []string{"conductor-0", "conductor-1", "conductor-2", "conductor-3"}; !reflect.DeepEqual(expected, hostnames) {
+		t.Errorf("expected conductors %v, got %v", expected, hostnames)
+	}
+
+	first := conductors[0].(map[string]interface{})
+	if drivers := first["drivers"]; !reflect.DeepEqual([]interface{}{"ipmi", "redfish"}, drivers) {
+		t.Errorf("expected drivers [ipmi redfish], got %v", drivers)
+	}
+	if updatedAt := conductors[2].(map[string]interface{})["updated_at"]; updatedAt != "2026-10-01T10:00:00+00:00" {
+		t.Errorf("expected a conductor that never checked in to report when it registered, got '%s'", updatedAt)
+	}
+
+	// Conductor groups are case insensitive, rack-1 still has conductor-3
+	if groups := d.Get("groups_without_alive_conductor"); !reflect.DeepEqual([]interface{}{"rack-2"}, groups) {
+		t.Errorf("expected groups without an alive conductor [rack-2], got %v", groups)
+	}
+	if d.Id() == "" {
+		t.Errorf("expected an ID to be set")
+	}
+}
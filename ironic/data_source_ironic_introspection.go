@@ -1,17 +1,20 @@
 package ironic
 
 import (
-	"fmt"
+	"context"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gophercloud/gophercloud/openstack/baremetalintrospection/v1/introspection"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
 // Schema resource for an introspection data source, that has some selected details about the node exposed.
 func dataSourceIronicIntrospection() *schema.Resource {
 	return &schema.Resource{
-		Read: dataSourceIronicIntrospectionRead,
+		ReadContext: dataSourceIronicIntrospectionRead,
 		Schema: map[string]*schema.Schema{
 			"uuid": {
 				Type:     schema.TypeString,
@@ -63,48 +66,70 @@ func dataSourceIronicIntrospection() *schema.Resource {
 				Computed:    true,
 				Description: "Memory in megabytes",
 			},
+			"disks": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeMap,
+					Elem: &schema.Schema{
+						Type: schema.TypeString,
+					},
+				},
+				Description: "The disks in the inventory, with their type: nvme, ssd or hdd",
+			},
+			"nics": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeMap,
+					Elem: &schema.Schema{
+						Type: schema.TypeString,
+					},
+				},
+				Description: "The network interfaces in the inventory, with their vendor and product IDs",
+			},
 		},
 	}
 }
 
-func dataSourceIronicIntrospectionRead(d *schema.ResourceData, meta interface{}) error {
-	client, err := meta.(*Clients).GetInspectorClient()
+func dataSourceIronicIntrospectionRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, err := meta.(*Clients).GetInspectorClient(ctx)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	uuid := d.Get("uuid").(string)
 
 	status, err := introspection.GetIntrospectionStatus(client, uuid).Extract()
 	if err != nil {
-		return fmt.Errorf("could not get introspection status: %s", err.Error())
+		return diag.Errorf("could not get introspection status: %s", err.Error())
 	}
 
 	err = d.Set("finished", status.Finished)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("finished_at", status.FinishedAt.Format("2006-01-02T15:04:05"))
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("started_at", status.StartedAt.Format("2006-01-02T15:04:05"))
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("error", status.Error)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("state", status.State)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	if status.Finished {
 		data, err := introspection.GetIntrospectionData(client, uuid).Extract()
 		if err != nil {
-			return fmt.Errorf("could not get introspection data: %s", err.Error())
+			return diag.Errorf("could not get introspection data: %s", err.Error())
 		}
 
 		// Network interface data
@@ -118,26 +143,85 @@ func dataSourceIronicIntrospectionRead(d *schema.ResourceData, meta interface{})
 		}
 		err = d.Set("interfaces", interfaces)
 		if err != nil {
-			return err
+			return diag.FromErr(err)
 		}
 
 		// CPU data
 		err = d.Set("cpu_arch", data.CPUArch)
 		if err != nil {
-			return err
+			return diag.FromErr(err)
 		}
 		err = d.Set("cpu_count", data.CPUs)
 		if err != nil {
-			return err
+			return diag.FromErr(err)
 		}
 
 		// Memory info
 		err = d.Set("memory_mb", data.MemoryMB)
 		if err != nil {
-			return err
+			return diag.FromErr(err)
+		}
+
+		// Hardware details from the inventory, for deriving traits
+		err = d.Set("disks", flattenIntrospectionDisks(data.Inventory.Disks))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		err = d.Set("nics", flattenIntrospectionNICs(data.Inventory.Interfaces))
+		if err != nil {
+			return diag.FromErr(err)
 		}
 	}
 
 	d.SetId(time.Now().UTC().String())
 	return nil
 }
+
+// introspectionDiskType classifies a disk as nvme, ssd or hdd.
+func introspectionDiskType(disk introspection.RootDiskType) string {
+	switch {
+	case strings.HasPrefix(disk.Name, "/dev/nvme"):
+		return "nvme"
+	case disk.Rotational:
+		return "hdd"
+	default:
+		return "ssd"
+	}
+}
+
+// flattenIntrospectionDisks converts the inventory's disks to maps of strings, as Terraform maps can't mix types.
+func flattenIntrospectionDisks(disks []introspection.RootDiskType) []map[string]string {
+	var flattened []map[string]string
+	for _, disk := range disks {
+		flattened = append(flattened, map[string]string{
+			"name":       disk.Name,
+			"type":       introspectionDiskType(disk),
+			"model":      disk.Model,
+			"vendor":     disk.Vendor,
+			"serial":     disk.Serial,
+			"wwn":        disk.Wwn,
+			"hctl":       disk.Hctl,
+			"by_path":    disk.ByPath,
+			"size":       strconv.FormatInt(disk.Size, 10),
+			"rotational": strconv.FormatBool(disk.Rotational),
+		})
+	}
+	return flattened
+}
+
+// flattenIntrospectionNICs converts the inventory's network interfaces to maps of strings.
+func flattenIntrospectionNICs(nics []introspection.InterfaceType) []map[string]string {
+	var flattened []map[string]string
+	for _, nic := range nics {
+		flattened = append(flattened, map[string]string{
+			"name":         nic.Name,
+			"mac_address":  nic.MACAddress,
+			"ipv4_address": nic.IPV4Address,
+			"vendor":       nic.Vendor,
+			"product":      nic.Product,
+			"biosdevname":  nic.BIOSDevName,
+			"has_carrier":  strconv.FormatBool(nic.HasCarrier),
+		})
+	}
+	return flattened
+}
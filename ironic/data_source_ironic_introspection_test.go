@@ -10,8 +10,9 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/gophercloud/gophercloud/openstack/baremetalintrospection/v1/introspection"
 	gth "github.com/gophercloud/gophercloud/testhelper"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
 )
 
@@ -37,12 +38,34 @@ func TestAccIntrospection(t *testing.T) {
 					resource.TestCheckResourceAttr("data.ironic_introspection.test-data", "cpu_arch", "x86_64"),
 					resource.TestCheckResourceAttr("data.ironic_introspection.test-data", "cpu_count", "4"),
 					resource.TestCheckResourceAttr("data.ironic_introspection.test-data", "memory_mb", "16384"),
+					resource.TestCheckResourceAttr("data.ironic_introspection.test-data", "disks.0.type", "hdd"),
+					resource.TestCheckResourceAttr("data.ironic_introspection.test-data", "disks.0.size", "53687091200"),
+					resource.TestCheckResourceAttr("data.ironic_introspection.test-data", "nics.0.vendor", "0x1af4"),
 				),
 			},
 		},
 	})
 }
 
+func TestIntrospectionDiskType(t *testing.T) {
+	cases := []struct {
+		Disk     introspection.RootDiskType
+		Expected string
+	}{
+		{Disk: introspection.RootDiskType{Name: "/dev/nvme0n1"}, Expected: "nvme"},
+		{Disk: introspection.RootDiskType{Name: "/dev/sda", Rotational: true}, Expected: "hdd"},
+		{Disk: introspection.RootDiskType{Name: "/dev/sdb"}, Expected: "ssd"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Disk.Name, func(t *testing.T) {
+			if actual := introspectionDiskType(c.Disk); actual != c.Expected {
+				t.Errorf("expected %s, got %s", c.Expected, actual)
+			}
+		})
+	}
+}
+
 // Returns a resource declaration for a particular node name, and it's related introspection data source.
 func testAccIntrospectionResource(node string) string {
 	return fmt.Sprintf(`
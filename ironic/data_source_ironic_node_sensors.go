@@ -0,0 +1,151 @@
+package ironic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Schema resource for a data source that reads a node's sensor data, such as power draw and temperatures, from a
+// vendor passthru method of its vendor interface. Ironic itself only sends sensor data as notifications, so this
+// relies on the driver providing such a method.
+func dataSourceIronicNodeSensors() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceIronicNodeSensorsRead,
+		Schema: map[string]*schema.Schema{
+			"node_uuid": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"method": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The vendor passthru method returning the sensor data",
+			},
+			"supported": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the node's vendor interface provides the method",
+			},
+			"sensor_data": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The sensor data as JSON, as returned by the method",
+			},
+			"readings": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "Each value in the sensor data, keyed by its path, e.g. Temperature/CPU1 Temp/Sensor Reading",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func dataSourceIronicNodeSensorsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	uuid := d.Get("node_uuid").(string)
+	method := d.Get("method").(string)
+	d.SetId(uuid)
+
+	// Drivers without a vendor interface, or without the method, don't have sensor data to read
+	methods, err := api.ListVendorPassthruMethods(uuid)
+	if _, ok := err.(gophercloud.ErrDefault400); ok {
+		return append(diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Node %s doesn't support vendor passthru, it has no sensor data", uuid),
+			Detail:   err.Error(),
+		}}, diag.FromErr(setNodeSensorData(d, false, nil))...)
+	} else if err != nil {
+		return diag.Errorf("could not list the vendor passthru methods of node %s: %s", uuid, err)
+	}
+	passthru, ok := methods[method]
+	if !ok {
+		return append(diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Node %s has no vendor passthru method %s, it has no sensor data", uuid, method),
+		}}, diag.FromErr(setNodeSensorData(d, false, nil))...)
+	}
+	if passthru.Async {
+		return diag.Errorf("vendor passthru method %s of node %s is asynchronous, it doesn't return sensor data", method, uuid)
+	}
+
+	httpMethod := "POST"
+	for _, m := range passthru.HTTPMethods {
+		if m == "GET" {
+			httpMethod = m
+		}
+	}
+	var data interface{}
+	if err := api.CallVendorPassthru(uuid, method, httpMethod, &data); err != nil {
+		return diag.Errorf("could not get the sensor data of node %s: %s", uuid, err)
+	}
+	return diag.FromErr(setNodeSensorData(d, true, data))
+}
+
+// setNodeSensorData sets the data source's fields from the sensor data.
+func setNodeSensorData(d *schema.ResourceData, supported bool, data interface{}) error {
+	err := d.Set("supported", supported)
+	if err != nil {
+		return err
+	}
+
+	sensorData := ""
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		sensorData = string(b)
+	}
+	err = d.Set("sensor_data", sensorData)
+	if err != nil {
+		return err
+	}
+
+	readings := make(map[string]string)
+	flattenSensorData("", data, readings)
+	return d.Set("readings", readings)
+}
+
+// flattenSensorData collects the values in nested sensor data, e.g. Ironic's {"Temperature": {"CPU1 Temp": {...}}},
+// keyed by their path.
+func flattenSensorData(prefix string, data interface{}, readings map[string]string) {
+	switch value := data.(type) {
+	case nil:
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			flattenSensorData(sensorPath(prefix, k), value[k], readings)
+		}
+	case []interface{}:
+		for i, v := range value {
+			flattenSensorData(sensorPath(prefix, fmt.Sprint(i)), v, readings)
+		}
+	default:
+		readings[prefix] = fmt.Sprint(value)
+	}
+}
+
+// sensorPath appends key to the path of a value in the sensor data.
+func sensorPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "/" + key
+}
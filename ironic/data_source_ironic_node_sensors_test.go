@@ -0,0 +1,83 @@
+// +build acceptance
+
+package ironic
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
+)
+
+func TestDataSourceIronicNodeSensorsRead(t *testing.T) {
+	api := &fakeIronicClient{
+		nodes: map[string]map[string]interface{}{
+			"node-0": {"uuid": "node-0"},
+			"node-1": {"uuid": "node-1"},
+			"node-2": {"uuid": "node-2"},
+		},
+		vendorMethods: map[string]map[string]vendorPassthruMethod{
+			"node-0": {"get_sensors_data": {HTTPMethods: []string{"GET"}}},
+			"node-1": {"send_raw": {HTTPMethods: []string{"POST"}}},
+		},
+		vendorResults: map[string]interface{}{
+			"get_sensors_data": map[string]interface{}{
+				"Temperature": map[string]interface{}{
+					"CPU1 Temp": map[string]interface{}{"Sensor Reading": "41 degrees C"},
+				},
+				"Power": map[string]interface{}{
+					"PS1": map[string]interface{}{"Sensor Reading": 210},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		Scenario          string
+		Node              string
+		ExpectedSupported bool
+		ExpectedReadings  map[string]interface{}
+	}{
+		{
+			Scenario:          "sensor data",
+			Node:              "node-0",
+			ExpectedSupported: true,
+			ExpectedReadings: map[string]interface{}{
+				"Temperature/CPU1 Temp/Sensor Reading": "41 degrees C",
+				"Power/PS1/Sensor Reading":             "210",
+			},
+		},
+		{
+			Scenario:         "method not provided",
+			Node:             "node-1",
+			ExpectedReadings: map[string]interface{}{},
+		},
+		{
+			Scenario:         "no vendor passthru",
+			Node:             "node-2",
+			ExpectedReadings: map[string]interface{}{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, dataSourceIronicNodeSensors().Schema, map[string]interface{}{
+				"node_uuid": c.Node,
+				"method":    "get_sensors_data",
+			})
+			th.AssertNoDiagErrors(t, dataSourceIronicNodeSensorsRead(context.Background(), d, &Clients{ironicAPI: api}))
+
+			if supported := d.Get("supported"); supported != c.ExpectedSupported {
+				t.Errorf("expected supported to be %v, got %v", c.ExpectedSupported, supported)
+			}
+			if readings := d.Get("readings"); !reflect.DeepEqual(c.ExpectedReadings, readings) {
+				t.Errorf("expected readings %v, got %v", c.ExpectedReadings, readings)
+			}
+			if sensorData := d.Get("sensor_data").(string); c.ExpectedSupported == (sensorData == "") {
+				t.Errorf("unexpected sensor_data '%s'", sensorData)
+			}
+		})
+	}
+}
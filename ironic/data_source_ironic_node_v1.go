@@ -0,0 +1,136 @@
+package ironic
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Schema resource for a node data source, which exposes the details of an existing node used for scheduling, so
+// inventory automation can group and filter nodes.
+func dataSourceIronicNodeV1() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceIronicNodeV1Read,
+		Schema: map[string]*schema.Schema{
+			"uuid": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: []string{"uuid", "name"},
+			},
+			"name": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: []string{"uuid", "name"},
+			},
+			"driver": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"conductor_group": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"resource_class": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"owner": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"lessee": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"traits": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"provision_state": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"power_state": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"maintenance": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func dataSourceIronicNodeV1Read(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// Ironic looks nodes up by either their UUID or name
+	ident := d.Get("uuid").(string)
+	if ident == "" {
+		ident = d.Get("name").(string)
+	}
+
+	node, err := getExtendedNode(api, ident)
+	if err != nil {
+		return diag.Errorf("could not get node %s: %s", ident, err)
+	}
+
+	return diag.FromErr(setNodeDataSourceFields(d, node))
+}
+
+// setNodeDataSourceFields sets the data source's fields from the node.
+func setNodeDataSourceFields(d *schema.ResourceData, node *extendedNode) error {
+	d.SetId(node.UUID)
+
+	err := d.Set("uuid", node.UUID)
+	if err != nil {
+		return err
+	}
+	err = d.Set("name", node.Name)
+	if err != nil {
+		return err
+	}
+	err = d.Set("driver", node.Driver)
+	if err != nil {
+		return err
+	}
+	err = d.Set("conductor_group", node.ConductorGroup)
+	if err != nil {
+		return err
+	}
+	err = d.Set("resource_class", node.ResourceClass)
+	if err != nil {
+		return err
+	}
+	err = d.Set("owner", node.Owner)
+	if err != nil {
+		return err
+	}
+	err = d.Set("lessee", node.Lessee)
+	if err != nil {
+		return err
+	}
+	err = d.Set("traits", node.Traits)
+	if err != nil {
+		return err
+	}
+	err = d.Set("provision_state", node.ProvisionState)
+	if err != nil {
+		return err
+	}
+	err = d.Set("power_state", normalizePowerState(node.PowerState))
+	if err != nil {
+		return err
+	}
+	return d.Set("maintenance", node.Maintenance)
+}
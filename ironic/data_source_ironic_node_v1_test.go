@@ -0,0 +1,46 @@
+// +build acceptance
+
+package ironic
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
+)
+
+func TestDataSourceIronicNodeV1Read(t *testing.T) {
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+		"node-0": {
+			"uuid":            "node-0",
+			"name":            "openshift-master-0",
+			"conductor_group": "rack-1",
+			"resource_class":  "baremetal",
+			"owner":           "project-a",
+			"lessee":          "project-b",
+			"traits":          []interface{}{"CUSTOM_GPU"},
+		},
+	}}
+
+	d := schema.TestResourceDataRaw(t, dataSourceIronicNodeV1().Schema, map[string]interface{}{"uuid": "node-0"})
+	th.AssertNoDiagErrors(t, dataSourceIronicNodeV1Read(context.Background(), d, &Clients{ironicAPI: api}))
+
+	expected := map[string]interface{}{
+		"name":            "openshift-master-0",
+		"conductor_group": "rack-1",
+		"resource_class":  "baremetal",
+		"owner":           "project-a",
+		"lessee":          "project-b",
+		"traits":          []interface{}{"CUSTOM_GPU"},
+	}
+	for field, value := range expected {
+		if actual := d.Get(field); !reflect.DeepEqual(actual, value) {
+			t.Errorf("expected %s to be %v, got %v", field, value, actual)
+		}
+	}
+	if d.Id() != "node-0" {
+		t.Errorf("expected ID node-0, got %s", d.Id())
+	}
+}
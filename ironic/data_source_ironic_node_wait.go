@@ -0,0 +1,105 @@
+package ironic
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// How often the node wait data source polls the node.
+var nodeWaitInterval = 10 * time.Second
+
+// Schema resource for a data source that waits for a node, managed elsewhere, to reach a provision state.
+func dataSourceIronicNodeWait() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceIronicNodeWaitRead,
+		Schema: map[string]*schema.Schema{
+			"uuid": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"provision_state": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "available",
+			},
+			"timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      1800,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "Number of seconds to wait for the node to reach provision_state",
+			},
+			"name": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"last_error": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func dataSourceIronicNodeWaitRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	uuid := d.Get("uuid").(string)
+	timeout := time.Duration(d.Get("timeout").(int)) * time.Second
+	node, err := waitForNodeProvisionState(ctx, api, uuid, d.Get("provision_state").(string), timeout)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	err = d.Set("name", node.Name)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("last_error", node.LastError)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(uuid)
+	return nil
+}
+
+// waitForNodeProvisionState polls a node until it reaches the target provision state. It gives up when the timeout
+// passes, or the node ends up in a failed state it can't leave on its own.
+func waitForNodeProvisionState(ctx context.Context, api IronicClient, uuid, target string, timeout time.Duration) (*extendedNode, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		node, err := getExtendedNode(api, uuid)
+		if err != nil {
+			return nil, fmt.Errorf("could not get node %s: %s", uuid, err)
+		}
+
+		state := node.ProvisionState
+		if state == target {
+			return node, nil
+		}
+		if strings.HasSuffix(state, "failed") || state == "error" {
+			return nil, fmt.Errorf("node %s is '%s' rather than '%s': %s", uuid, state, target, node.LastError)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for node %s to be '%s', it's '%s'", uuid, target, state)
+		}
+
+		log.Printf("[DEBUG] Node %s is '%s', waiting for '%s'", uuid, state, target)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("interrupted while waiting for node %s to be '%s'", uuid, target)
+		case <-time.After(nodeWaitInterval):
+		}
+	}
+}
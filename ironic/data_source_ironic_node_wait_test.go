@@ -0,0 +1,50 @@
+// +build acceptance
+
+package ironic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
+)
+
+func TestWaitForNodeProvisionState(t *testing.T) {
+	cases := []struct {
+		Scenario      string
+		State         string
+		ExpectedError string
+	}{
+		{
+			Scenario: "already available",
+			State:    "available",
+		},
+		{
+			Scenario:      "cleaning failed",
+			State:         "clean failed",
+			ExpectedError: "node node-0 is 'clean failed' rather than 'available'",
+		},
+		{
+			Scenario:      "still cleaning",
+			State:         "cleaning",
+			ExpectedError: "timed out waiting for node node-0 to be 'available'",
+		},
+	}
+
+	nodeWaitInterval = time.Millisecond
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+				"node-0": {"uuid": "node-0", "provision_state": c.State},
+			}}
+
+			_, err := waitForNodeProvisionState(context.Background(), api, "node-0", "available", 10*time.Millisecond)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package ironic
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// nodeListFilters are the string filters of the nodes data source, which are also fields of each node it returns.
+var nodeListFilters = []string{
+	"provision_state",
+	"driver",
+	"conductor_group",
+	"resource_class",
+	"owner",
+	"lessee",
+	"fault",
+	"shard",
+}
+
+// Schema resource for a data source that lists the nodes matching a set of filters, for inventory queries such as
+// finding the available nodes of a conductor group or resource class.
+func dataSourceIronicNodes() *schema.Resource {
+	filters := map[string]*schema.Schema{
+		"maintenance": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "Only list nodes that are, or aren't, in maintenance. Nodes are listed either way if unset.",
+		},
+		"retired": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Description: "Only list nodes that are, or aren't, retired. Nodes are listed either way if unset.",
+		},
+		"nodes": {
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Resource{
+				Schema: nodeListSchema(),
+			},
+		},
+	}
+	for _, filter := range nodeListFilters {
+		filters[filter] = &schema.Schema{
+			Type:     schema.TypeString,
+			Optional: true,
+		}
+	}
+
+	return &schema.Resource{
+		ReadContext: dataSourceIronicNodesRead,
+		Schema:      filters,
+	}
+}
+
+// nodeListSchema returns the schema of each node listed by the nodes data source.
+func nodeListSchema() map[string]*schema.Schema {
+	fields := map[string]*schema.Schema{
+		"uuid": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"name": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"power_state": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"maintenance": {
+			Type:     schema.TypeBool,
+			Computed: true,
+		},
+		"retired": {
+			Type:     schema.TypeBool,
+			Computed: true,
+		},
+	}
+	for _, field := range nodeListFilters {
+		fields[field] = &schema.Schema{
+			Type:     schema.TypeString,
+			Computed: true,
+		}
+	}
+	return fields
+}
+
+func dataSourceIronicNodesRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	opts := nodeListOpts{
+		ProvisionState: d.Get("provision_state").(string),
+		Driver:         d.Get("driver").(string),
+		ConductorGroup: d.Get("conductor_group").(string),
+		ResourceClass:  d.Get("resource_class").(string),
+		Owner:          d.Get("owner").(string),
+		Lessee:         d.Get("lessee").(string),
+		Fault:          d.Get("fault").(string),
+		Shard:          d.Get("shard").(string),
+	}
+	if maintenance, ok := d.GetOkExists("maintenance"); ok {
+		value := maintenance.(bool)
+		opts.Maintenance = &value
+	}
+	if retired, ok := d.GetOkExists("retired"); ok {
+		value := retired.(bool)
+		opts.Retired = &value
+	}
+
+	result, err := api.ListNodes(opts)
+	if err != nil {
+		return diag.Errorf("could not list nodes: %s", err)
+	}
+
+	err = d.Set("nodes", flattenNodeList(result))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// The same filters always give the same data source
+	query, err := opts.ToNodeListQuery()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	d.SetId(fmt.Sprintf("%d", schema.HashString(query)))
+	return nil
+}
+
+// flattenNodeList converts the listed nodes to the data source's nodes.
+func flattenNodeList(list []extendedNode) []interface{} {
+	result := make([]interface{}, 0, len(list))
+	for _, node := range list {
+		result = append(result, map[string]interface{}{
+			"uuid":            node.UUID,
+			"name":            node.Name,
+			"provision_state": node.ProvisionState,
+			"power_state":     normalizePowerState(node.PowerState),
+			"maintenance":     node.Maintenance,
+			"retired":         node.Retired,
+			"driver":          node.Driver,
+			"conductor_group": node.ConductorGroup,
+			"resource_class":  node.ResourceClass,
+			"owner":           node.Owner,
+			"lessee":          node.Lessee,
+			"fault":           node.Fault,
+			"shard":           node.Shard,
+		})
+	}
+	return result
+}
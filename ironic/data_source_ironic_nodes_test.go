@@ -0,0 +1,126 @@
+// +build acceptance
+
+package ironic
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
+)
+
+func TestDataSourceIronicNodesRead(t *testing.T) {
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+		"node-0": {
+			"uuid":            "node-0",
+			"name":            "rack-1-0",
+			"provision_state": "available",
+			"maintenance":     false,
+			"conductor_group": "rack-1",
+			"resource_class":  "baremetal",
+			"owner":           "project-a",
+		},
+		"node-1": {
+			"uuid":            "node-1",
+			"name":            "rack-1-1",
+			"provision_state": "available",
+			"maintenance":     true,
+			"conductor_group": "rack-1",
+			"resource_class":  "baremetal",
+			"owner":           "project-a",
+			"fault":           "power failure",
+			"retired":         true,
+		},
+		"node-2": {
+			"uuid":            "node-2",
+			"name":            "rack-2-0",
+			"provision_state": "active",
+			"maintenance":     false,
+			"conductor_group": "rack-2",
+			"resource_class":  "gpu",
+			"lessee":          "project-b",
+			"shard":           "shard-2",
+		},
+	}}
+
+	cases := []struct {
+		Scenario string
+		Filters  map[string]interface{}
+		Expected []string
+	}{
+		{
+			Scenario: "no filters",
+			Expected: []string{"node-0", "node-1", "node-2"},
+		},
+		{
+			Scenario: "conductor group and resource class",
+			Filters:  map[string]interface{}{"conductor_group": "rack-1", "resource_class": "baremetal"},
+			Expected: []string{"node-0", "node-1"},
+		},
+		{
+			Scenario: "not in maintenance",
+			Filters:  map[string]interface{}{"maintenance": false, "provision_state": "available"},
+			Expected: []string{"node-0"},
+		},
+		{
+			Scenario: "fault",
+			Filters:  map[string]interface{}{"fault": "power failure"},
+			Expected: []string{"node-1"},
+		},
+		{
+			Scenario: "lessee",
+			Filters:  map[string]interface{}{"lessee": "project-b"},
+			Expected: []string{"node-2"},
+		},
+		{
+			Scenario: "shard",
+			Filters:  map[string]interface{}{"shard": "shard-2"},
+			Expected: []string{"node-2"},
+		},
+		{
+			Scenario: "retired",
+			Filters:  map[string]interface{}{"retired": true},
+			Expected: []string{"node-1"},
+		},
+		{
+			Scenario: "owner without matches",
+			Filters:  map[string]interface{}{"owner": "project-c"},
+			Expected: []string{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, dataSourceIronicNodes().Schema, c.Filters)
+			th.AssertNoDiagErrors(t, dataSourceIronicNodesRead(context.Background(), d, &Clients{ironicAPI: api}))
+
+			uuids := make([]string, 0)
+			for _, node := range d.Get("nodes").([]interface{}) {
+				uuids = append(uuids, node.(map[string]interface{})["uuid"].(string))
+			}
+			if !reflect.DeepEqual(c.Expected, uuids) {
+				t.Errorf("expected nodes %v, got %v", c.Expected, uuids)
+			}
+			if d.Id() == "" {
+				t.Errorf("expected an ID to be set")
+			}
+		})
+	}
+}
+
+func TestCheckShardSupport(t *testing.T) {
+	th.AssertNoError(t, checkShardSupport("1.82"))
+	th.AssertNoError(t, checkShardSupport("1.87"))
+	th.AssertError(t, checkShardSupport("1.81"), "node shards require microversion 1.82 or later, got '1.81'")
+	th.AssertError(t, checkShardSupport(""), "node shards require microversion 1.82 or later, got ''")
+}
+
+func TestCheckRetiredSupport(t *testing.T) {
+	th.AssertNoError(t, checkRetiredSupport("1.61"))
+	th.AssertNoError(t, checkRetiredSupport("1.82"))
+	th.AssertError(t, checkRetiredSupport("1.60"), "filtering nodes by retired requires microversion 1.61 or later, got '1.60'")
+	th.AssertNoError(t, checkRetirementSupport("1.61"))
+	th.AssertError(t, checkRetirementSupport("1.52"), "retiring nodes requires microversion 1.61 or later, got '1.52'")
+}
@@ -0,0 +1,128 @@
+package ironic
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// raidLevelMinDisks is the minimum number of physical disks Ironic requires for each RAID level.
+var raidLevelMinDisks = map[string]int{
+	"0":   1,
+	"1":   2,
+	"2":   3,
+	"5":   3,
+	"6":   4,
+	"1+0": 4,
+	"5+0": 6,
+	"6+0": 8,
+}
+
+// raidLevelPattern matches the quoted RAID levels in the description of the raid_level property.
+var raidLevelPattern = regexp.MustCompile(`'([0-9+]+)'`)
+
+// Schema resource for a data source that describes the logical disks a node's driver can build, so a raid_config
+// can be checked against what the controller supports before cleaning the node.
+func dataSourceIronicRAIDProperties() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceIronicRAIDPropertiesRead,
+		Schema: map[string]*schema.Schema{
+			"node_uuid": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"driver": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"properties": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "The logical disk properties supported by the driver, and a description of each",
+			},
+			"raid_levels": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"min_physical_disks": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "The minimum number of physical disks for each supported RAID level",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+		},
+	}
+}
+
+func dataSourceIronicRAIDPropertiesRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	uuid := d.Get("node_uuid").(string)
+	node, err := getExtendedNode(api, uuid)
+	if err != nil {
+		return diag.Errorf("could not get node %s: %s", uuid, err)
+	}
+
+	// Ironic describes the logical disk properties of the driver's default RAID interface
+	properties, err := api.GetDriverDiskProperties(node.Driver)
+	if err != nil {
+		return diag.Errorf("could not get the logical disk properties of driver %s: %s", node.Driver, err)
+	}
+
+	descriptions := make(map[string]string)
+	for name, description := range properties {
+		descriptions[name] = fmt.Sprint(description)
+	}
+	raidLevels := supportedRAIDLevels(descriptions["raid_level"])
+	minDisks := make(map[string]int)
+	for _, level := range raidLevels {
+		if disks, ok := raidLevelMinDisks[level]; ok {
+			minDisks[level] = disks
+		}
+	}
+
+	err = d.Set("driver", node.Driver)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("properties", descriptions)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("raid_levels", raidLevels)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("min_physical_disks", minDisks)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(uuid)
+	return nil
+}
+
+// supportedRAIDLevels returns the RAID levels listed in the description of the raid_level property, e.g. "Valid
+// values are '0', '1', '2', '5', '6', '1+0', '5+0' and '6+0'".
+func supportedRAIDLevels(description string) []string {
+	levels := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, match := range raidLevelPattern.FindAllStringSubmatch(description, -1) {
+		if level := match[1]; !seen[level] {
+			seen[level] = true
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
@@ -0,0 +1,44 @@
+// +build acceptance
+
+package ironic
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
+)
+
+func TestDataSourceIronicRAIDPropertiesRead(t *testing.T) {
+	api := &fakeIronicClient{
+		nodes: map[string]map[string]interface{}{
+			"node-0": {"uuid": "node-0", "driver": "idrac"},
+		},
+		diskProperties: map[string]map[string]interface{}{
+			"idrac": {
+				"raid_level":               "RAID level for the logical disk. Valid values are '0', '1', '5', '1+0' and '5+0'. Required.",
+				"size_gb":                  "Size in GiB (Integer) for the logical disk. Use 'MAX' as size_gb if this logical disk is supposed to use the rest of the space available. Required.",
+				"number_of_physical_disks": "Number of physical disks to use for this logical disk. Optional.",
+			},
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceIronicRAIDProperties().Schema, map[string]interface{}{"node_uuid": "node-0"})
+	th.AssertNoDiagErrors(t, dataSourceIronicRAIDPropertiesRead(context.Background(), d, &Clients{ironicAPI: api}))
+
+	expected := map[string]interface{}{
+		"driver":             "idrac",
+		"raid_levels":        []interface{}{"0", "1", "5", "1+0", "5+0"},
+		"min_physical_disks": map[string]interface{}{"0": 1, "1": 2, "5": 3, "1+0": 4, "5+0": 6},
+	}
+	for field, value := range expected {
+		if actual := d.Get(field); !reflect.DeepEqual(actual, value) {
+			t.Errorf("expected %s to be %v, got %v", field, value, actual)
+		}
+	}
+	if properties := d.Get("properties").(map[string]interface{}); len(properties) != 3 {
+		t.Errorf("expected 3 properties, got %v", properties)
+	}
+}
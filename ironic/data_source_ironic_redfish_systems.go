@@ -0,0 +1,181 @@
+package ironic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// redfishRequestTimeout bounds each request to the BMC, which may be slow but shouldn't hang the plan.
+const redfishRequestTimeout = 30 * time.Second
+
+// redfishSystem is the part of a Redfish ComputerSystem that helps tell systems apart.
+type redfishSystem struct {
+	ID           string `json:"@odata.id"`
+	Name         string `json:"Name"`
+	Manufacturer string `json:"Manufacturer"`
+	Model        string `json:"Model"`
+	SerialNumber string `json:"SerialNumber"`
+}
+
+// Schema resource for a data source that lists the systems a Redfish BMC manages, so the right one can be set as a
+// node's driver_info.redfish_system_id. It talks to the BMC directly, rather than through Ironic.
+func dataSourceIronicRedfishSystems() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceIronicRedfishSystemsRead,
+		Schema: map[string]*schema.Schema{
+			"redfish_address": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The address of the BMC, as in driver_info. HTTPS is assumed if the scheme is missing.",
+			},
+			"redfish_username": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"redfish_password": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			"ca_cert": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "PEM-encoded CA certificate, or a path to one, to verify the BMC's certificate",
+			},
+			"insecure": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Don't verify the BMC's certificate",
+			},
+			"system_ids": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"systems": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"system_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"manufacturer": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"model": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"serial_number": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceIronicRedfishSystemsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	address := redfishBaseURL(d.Get("redfish_address").(string))
+	tlsConfig, err := buildTLSConfig(d.Get("insecure").(bool), d.Get("ca_cert").(string), "", "")
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{Transport: transport, Timeout: redfishRequestTimeout}
+	get := func(path string, result interface{}) error {
+		return redfishGet(client, address+path, d.Get("redfish_username").(string), d.Get("redfish_password").(string), result)
+	}
+
+	var collection struct {
+		Members []struct {
+			ID string `json:"@odata.id"`
+		} `json:"Members"`
+	}
+	if err := get("/redfish/v1/Systems", &collection); err != nil {
+		return diag.Errorf("could not list the systems of %s: %s", address, err)
+	}
+
+	ids := make([]string, 0, len(collection.Members))
+	systems := make([]interface{}, 0, len(collection.Members))
+	for _, member := range collection.Members {
+		system := redfishSystem{ID: member.ID}
+		if err := get(member.ID, &system); err != nil {
+			return diag.Errorf("could not get system %s of %s: %s", member.ID, address, err)
+		}
+		ids = append(ids, member.ID)
+		systems = append(systems, map[string]interface{}{
+			"system_id":     member.ID,
+			"name":          system.Name,
+			"manufacturer":  system.Manufacturer,
+			"model":         system.Model,
+			"serial_number": system.SerialNumber,
+		})
+	}
+
+	err = d.Set("system_ids", ids)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("systems", systems)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(address)
+	return nil
+}
+
+// redfishBaseURL returns the scheme and authority of a redfish_address, assuming HTTPS like Ironic does when the scheme
+// is missing.
+func redfishBaseURL(address string) string {
+	if !strings.Contains(address, "://") {
+		address = "https://" + address
+	}
+	if u, err := url.Parse(address); err == nil && u.Host != "" {
+		return u.Scheme + "://" + u.Host
+	}
+	return strings.TrimSuffix(address, "/")
+}
+
+// redfishGet fetches a Redfish resource, with basic authentication if a username is set.
+func redfishGet(client *http.Client, resourceURL, username, password string, result interface{}) error {
+	req, err := http.NewRequest("GET", resourceURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("the BMC returned %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
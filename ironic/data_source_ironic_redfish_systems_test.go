@@ -0,0 +1,101 @@
+// +build acceptance
+
+package ironic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
+)
+
+func TestDataSourceIronicRedfishSystemsRead(t *testing.T) {
+	resources := map[string]interface{}{
+		"/redfish/v1/Systems": map[string]interface{}{
+			"Members": []interface{}{
+				map[string]interface{}{"@odata.id": "/redfish/v1/Systems/1"},
+				map[string]interface{}{"@odata.id": "/redfish/v1/Systems/2"},
+			},
+		},
+		"/redfish/v1/Systems/1": map[string]interface{}{"Name": "blade-1", "Manufacturer": "Dell Inc.", "SerialNumber": "ABC123"},
+		"/redfish/v1/Systems/2": map[string]interface{}{"Name": "blade-2", "Model": "PowerEdge R640"},
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "admin" || password != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		resource, ok := resources[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(resource)
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		Scenario      string
+		Password      string
+		ExpectedError string
+	}{
+		{
+			Scenario: "systems",
+			Password: "password",
+		},
+		{
+			Scenario:      "wrong password",
+			Password:      "wrong",
+			ExpectedError: "the BMC returned 401 Unauthorized",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, dataSourceIronicRedfishSystems().Schema, map[string]interface{}{
+				// Without the scheme, as often given in driver_info
+				"redfish_address":  strings.TrimPrefix(server.URL, "https://"),
+				"redfish_username": "admin",
+				"redfish_password": c.Password,
+				"insecure":         true,
+			})
+			diags := dataSourceIronicRedfishSystemsRead(context.Background(), d, &Clients{})
+			if c.ExpectedError != "" {
+				th.AssertDiagError(t, diags, c.ExpectedError)
+				return
+			}
+			th.AssertNoDiagErrors(t, diags)
+
+			expected := []interface{}{"/redfish/v1/Systems/1", "/redfish/v1/Systems/2"}
+			if ids := d.Get("system_ids"); !reflect.DeepEqual(expected, ids) {
+				t.Errorf("expected system_ids %v, got %v", expected, ids)
+			}
+			systems := d.Get("systems").([]interface{})
+			if name := systems[0].(map[string]interface{})["name"]; name != "blade-1" {
+				t.Errorf("expected the first system to be 'blade-1', got '%s'", name)
+			}
+			if model := systems[1].(map[string]interface{})["model"]; model != "PowerEdge R640" {
+				t.Errorf("expected the second system to be a 'PowerEdge R640', got '%s'", model)
+			}
+		})
+	}
+}
+
+func TestRedfishBaseURL(t *testing.T) {
+	cases := map[string]string{
+		"192.0.2.10":                          "https://192.0.2.10",
+		"http://192.0.2.10:8000/":             "http://192.0.2.10:8000",
+		"https://bmc.example.com/redfish/v1/": "https://bmc.example.com",
+	}
+	for address, expected := range cases {
+		if actual := redfishBaseURL(address); actual != expected {
+			t.Errorf("expected %s to be %s, got %s", address, expected, actual)
+		}
+	}
+}
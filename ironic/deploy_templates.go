@@ -0,0 +1,70 @@
+package ironic
+
+import (
+	"github.com/gophercloud/gophercloud"
+)
+
+// deployTemplate represents an Ironic deploy template, which gophercloud doesn't yet implement. Nodes with a trait
+// matching the template's name run its steps when they're deployed with that trait. Requires microversion 1.55 or
+// later.
+type deployTemplate struct {
+	UUID  string                 `json:"uuid,omitempty"`
+	Name  string                 `json:"name"`
+	Steps []deployTemplateStep   `json:"steps"`
+	Extra map[string]interface{} `json:"extra,omitempty"`
+}
+
+// deployTemplateStep is one of the deploy steps in a deploy template.
+type deployTemplateStep struct {
+	Interface string                 `json:"interface"`
+	Step      string                 `json:"step"`
+	Args      map[string]interface{} `json:"args"`
+	Priority  int                    `json:"priority"`
+}
+
+// deployTemplatePatch is a JSON patch operation on a deploy template.
+type deployTemplatePatch struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// createDeployTemplate creates a deploy template.
+func createDeployTemplate(client *gophercloud.ServiceClient, template deployTemplate) (*deployTemplate, error) {
+	var result deployTemplate
+	_, err := client.Post(client.ServiceURL("deploy_templates"), template, &result, &gophercloud.RequestOpts{
+		OkCodes: []int{201},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// getDeployTemplate fetches a deploy template by UUID or name.
+func getDeployTemplate(client *gophercloud.ServiceClient, ident string) (*deployTemplate, error) {
+	var result deployTemplate
+	_, err := client.Get(client.ServiceURL("deploy_templates", ident), &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// updateDeployTemplate applies a JSON patch to a deploy template.
+func updateDeployTemplate(client *gophercloud.ServiceClient, uuid string, patch []deployTemplatePatch) (*deployTemplate, error) {
+	var result deployTemplate
+	_, err := client.Patch(client.ServiceURL("deploy_templates", uuid), patch, &result, &gophercloud.RequestOpts{
+		OkCodes: []int{200},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// deleteDeployTemplate deletes a deploy template.
+func deleteDeployTemplate(client *gophercloud.ServiceClient, uuid string) error {
+	_, err := client.Delete(client.ServiceURL("deploy_templates", uuid), nil)
+	return err
+}
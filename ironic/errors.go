@@ -0,0 +1,78 @@
+package ironic
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+)
+
+// Errors for the common ways operations on a node fail, check for them with errors.Is. A locked node is worth
+// retrying later, the others need someone to look at the node first.
+var (
+	// ErrNodeLocked means Ironic kept rejecting the request because a conductor holds the node's lock.
+	ErrNodeLocked = errors.New("node is locked")
+
+	// ErrProvisionFailed means the node ended up in a failed provision state, e.g. 'clean failed'.
+	ErrProvisionFailed = errors.New("provisioning failed")
+
+	// ErrCredentialsInvalid means Ironic rejected the provider's credentials, or the node's BMC rejected the ones in
+	// driver_info.
+	ErrCredentialsInvalid = errors.New("credentials are invalid")
+)
+
+// NodeError is a failed operation on a node. Reason is one of the errors above, if the failure could be classified,
+// and LastError is the node's last_error from Ironic at the time.
+type NodeError struct {
+	UUID      string
+	Reason    error
+	LastError string
+	Err       error
+}
+
+func (e *NodeError) Error() string {
+	if e.LastError == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s , last error was '%s'", e.Err, e.LastError)
+}
+
+// Is matches the sentinel error the failure was classified as.
+func (e *NodeError) Is(target error) bool {
+	return e.Reason != nil && e.Reason == target
+}
+
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+// credentialsErrorPattern matches a node's last_error when its BMC refused the credentials in driver_info. The status
+// code is only matched as a word, so UUIDs and addresses containing 401 don't match.
+var credentialsErrorPattern = regexp.MustCompile(`authentication|unauthorized|invalid credentials|incorrect password|\b401\b`)
+
+// newNodeError wraps an error from an operation on a node, classifying it from the API's response and the state the
+// node was left in. provisionState and lastError may be empty if the node couldn't be read.
+func newNodeError(uuid string, err error, provisionState, lastError string) error {
+	if err == nil {
+		return nil
+	}
+
+	nodeErr := &NodeError{UUID: uuid, LastError: lastError, Err: err}
+
+	var conflict gophercloud.ErrDefault409
+	var unauthorized gophercloud.ErrDefault401
+	switch {
+	case errors.As(err, &conflict):
+		nodeErr.Reason = ErrNodeLocked
+	case errors.As(err, &unauthorized):
+		nodeErr.Reason = ErrCredentialsInvalid
+	case credentialsErrorPattern.MatchString(strings.ToLower(lastError)):
+		nodeErr.Reason = ErrCredentialsInvalid
+	case strings.HasSuffix(provisionState, "failed") || provisionState == "error":
+		nodeErr.Reason = ErrProvisionFailed
+	}
+
+	return nodeErr
+}
@@ -0,0 +1,92 @@
+// +build acceptance
+
+package ironic
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+)
+
+func TestNewNodeError(t *testing.T) {
+	cases := []struct {
+		Scenario       string
+		Err            error
+		ProvisionState string
+		LastError      string
+		ExpectedReason error
+		ExpectedError  string
+	}{
+		{
+			Scenario:       "locked",
+			Err:            gophercloud.ErrDefault409{},
+			ExpectedReason: ErrNodeLocked,
+		},
+		{
+			Scenario:       "unauthorized",
+			Err:            fmt.Errorf("could not update: %w", gophercloud.ErrDefault401{}),
+			ExpectedReason: ErrCredentialsInvalid,
+		},
+		{
+			Scenario:       "bmc rejected credentials",
+			Err:            errors.New("could not clean node, node is currently 'clean failed'"),
+			ProvisionState: "clean failed",
+			LastError:      "IPMI call failed: Authentication type NONE not supported",
+			ExpectedReason: ErrCredentialsInvalid,
+			ExpectedError:  "could not clean node, node is currently 'clean failed' , last error was 'IPMI call failed: Authentication type NONE not supported'",
+		},
+		{
+			Scenario:       "bmc returned 401",
+			Err:            errors.New("could not inspect"),
+			ProvisionState: "inspect failed",
+			LastError:      "Redfish exception occurred. Error: HTTP GET https://10.0.0.5/redfish/v1/Systems returned code 401.",
+			ExpectedReason: ErrCredentialsInvalid,
+		},
+		{
+			Scenario:       "node UUID containing 401",
+			Err:            errors.New("could not deploy"),
+			ProvisionState: "deploy failed",
+			LastError:      "Image 4a1d9f2e-401b-4c2d-9e6f-0a1b2c3d4e5f could not be downloaded for node 7c0e4011-2401-4d3b-8a11-c401d2e3f4a5",
+			ExpectedReason: ErrProvisionFailed,
+		},
+		{
+			Scenario:       "deploy failed",
+			Err:            errors.New("could not deploy"),
+			ProvisionState: "deploy failed",
+			LastError:      "Image download failed",
+			ExpectedReason: ErrProvisionFailed,
+			ExpectedError:  "could not deploy , last error was 'Image download failed'",
+		},
+		{
+			Scenario:      "unclassified",
+			Err:           errors.New("cannot delete node in state 'adopting'"),
+			ExpectedError: "cannot delete node in state 'adopting'",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			err := newNodeError("node-0", c.Err, c.ProvisionState, c.LastError)
+
+			for _, reason := range []error{ErrNodeLocked, ErrProvisionFailed, ErrCredentialsInvalid} {
+				if errors.Is(err, reason) != (reason == c.ExpectedReason) {
+					t.Errorf("expected errors.Is(err, %q) to be %t", reason, reason == c.ExpectedReason)
+				}
+			}
+			var nodeErr *NodeError
+			if !errors.As(err, &nodeErr) || !reflect.DeepEqual(nodeErr.Err, c.Err) {
+				t.Error("expected the original error to be wrapped")
+			}
+			if c.ExpectedError != "" && err.Error() != c.ExpectedError {
+				t.Errorf("expected %q, got %q", c.ExpectedError, err.Error())
+			}
+		})
+	}
+
+	if err := newNodeError("node-0", nil, "", ""); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
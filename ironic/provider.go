@@ -2,9 +2,15 @@ package ironic
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -16,9 +22,9 @@ import (
 	httpbasicintrospection "github.com/gophercloud/gophercloud/openstack/baremetalintrospection/httpbasic"
 	noauthintrospection "github.com/gophercloud/gophercloud/openstack/baremetalintrospection/noauth"
 	"github.com/gophercloud/gophercloud/pagination"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
-	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 // Clients stores the client connection information for Ironic and Inspector
@@ -26,6 +32,9 @@ type Clients struct {
 	ironic    *gophercloud.ServiceClient
 	inspector *gophercloud.ServiceClient
 
+	// Node operations against Ironic, tests may replace this with a fake. When unset, ironic is used.
+	ironicAPI IronicClient
+
 	// Boolean that determines if Ironic API was previously determined to be available, we don't need to try every time.
 	ironicUp bool
 
@@ -47,10 +56,38 @@ type Clients struct {
 	inspectorMux sync.Mutex
 
 	timeout int
+
+	// Values for node fields that a node resource leaves unset, from the provider's node_defaults block
+	nodeDefaults map[string]string
+
+	// Whether to randomize the backoff when retrying requests Ironic rejected as busy. Without jitter, many nodes being
+	// applied at once keep retrying in lockstep, and colliding on the conductor.
+	retryJitter bool
+
+	// Canceled when Terraform is interrupted. The plugin SDK only gives it to the provider's configuration, the
+	// contexts of resource operations aren't canceled.
+	stopContext context.Context
+}
+
+// withStopContext returns a context that's canceled along with ctx, or when Terraform is interrupted.
+func (c *Clients) withStopContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	merged, cancel := context.WithCancel(ctx)
+	if c.stopContext == nil {
+		return merged, cancel
+	}
+	go func() {
+		select {
+		case <-c.stopContext.Done():
+			cancel()
+		case <-merged.Done():
+		}
+	}()
+	return merged, cancel
 }
 
-// GetIronicClient returns the API client for Ironic, optionally retrying to reach the API if timeout is set.
-func (c *Clients) GetIronicClient() (*gophercloud.ServiceClient, error) {
+// GetIronicClient returns the API client for Ironic, optionally retrying to reach the API if timeout is set. Its
+// requests are made with ctx, so they're canceled along with the operation making them.
+func (c *Clients) GetIronicClient(ctx context.Context) (*gophercloud.ServiceClient, error) {
 	// Terraform concurrently creates some resources which means multiple callers can request an Ironic client. We
 	// only need to check if the API is available once, so we use a mux to restrict one caller to polling the API.
 	// When the mux is released, the other callers will fall through to the check for ironicUp.
@@ -59,7 +96,7 @@ func (c *Clients) GetIronicClient() (*gophercloud.ServiceClient, error) {
 
 	// Ironic is UP, or user didn't ask us to check
 	if c.ironicUp || c.timeout == 0 {
-		return c.ironic, nil
+		return withContext(ctx, c.ironic), nil
 	}
 
 	// We previously tried and it failed.
@@ -69,22 +106,22 @@ func (c *Clients) GetIronicClient() (*gophercloud.ServiceClient, error) {
 
 	// Let's poll the API until it's up, or times out.
 	duration := time.Duration(c.timeout) * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	waitCtx, cancel := context.WithTimeout(ctx, duration)
 	defer cancel()
 
 	done := make(chan struct{})
 	go func() {
 		log.Printf("[INFO] Waiting for Ironic API...")
-		waitForAPI(ctx, c.ironic)
+		waitForAPI(waitCtx, c.ironic)
 		log.Printf("[INFO] API successfully connected, waiting for conductor...")
-		waitForConductor(ctx, c.ironic)
+		waitForConductor(waitCtx, c.ironic)
 		close(done)
 	}()
 
 	// Wait for done or time out
 	select {
-	case <-ctx.Done():
-		if err := ctx.Err(); err != nil {
+	case <-waitCtx.Done():
+		if err := waitCtx.Err(); err != nil {
 			c.ironicFailed = true
 			return nil, fmt.Errorf("could not contact Ironic API: %w", err)
 		}
@@ -92,11 +129,25 @@ func (c *Clients) GetIronicClient() (*gophercloud.ServiceClient, error) {
 	}
 
 	c.ironicUp = true
-	return c.ironic, ctx.Err()
+	return withContext(ctx, c.ironic), waitCtx.Err()
 }
 
-// GetInspectorClient returns the API client for Ironic, optionally retrying to reach the API if timeout is set.
-func (c *Clients) GetInspectorClient() (*gophercloud.ServiceClient, error) {
+// GetIronicAPI returns the IronicClient used for node operations, waiting for the API like GetIronicClient.
+func (c *Clients) GetIronicAPI(ctx context.Context) (IronicClient, error) {
+	client, err := c.GetIronicClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ironicAPI != nil {
+		return c.ironicAPI, nil
+	}
+	return &gophercloudIronicClient{client: client, retryJitter: c.retryJitter}, nil
+}
+
+// GetInspectorClient returns the API client for Ironic, optionally retrying to reach the API if timeout is set. Like
+// GetIronicClient, its requests are made with ctx.
+func (c *Clients) GetInspectorClient(ctx context.Context) (*gophercloud.ServiceClient, error) {
 	// Terraform concurrently creates some resources which means multiple callers can request an Inspector client. We
 	// only need to check if the API is available once, so we use a mux to restrict one caller to polling the API.
 	// When the mux is released, the other callers will fall through to the check for inspectorUp.
@@ -106,45 +157,115 @@ func (c *Clients) GetInspectorClient() (*gophercloud.ServiceClient, error) {
 	if c.inspector == nil {
 		return nil, fmt.Errorf("no inspector endpoint was specified")
 	} else if c.inspectorUp || c.timeout == 0 {
-		return c.inspector, nil
+		return withContext(ctx, c.inspector), nil
 	} else if c.inspectorFailed {
 		return nil, fmt.Errorf("could not contact Inspector API: timeout reached")
 	}
 
 	// Let's poll the API until it's up, or times out.
 	duration := time.Duration(c.timeout) * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	waitCtx, cancel := context.WithTimeout(ctx, duration)
 	defer cancel()
 
 	done := make(chan struct{})
 	go func() {
 		log.Printf("[INFO] Waiting for Inspector API...")
-		waitForAPI(ctx, c.inspector)
+		waitForAPI(waitCtx, c.inspector)
 		close(done)
 	}()
 
 	// Wait for done or time out
 	select {
-	case <-ctx.Done():
-		if err := ctx.Err(); err != nil {
+	case <-waitCtx.Done():
+		if err := waitCtx.Err(); err != nil {
 			c.ironicFailed = true
 			return nil, err
 		}
 	case <-done:
 	}
 
-	if err := ctx.Err(); err != nil {
+	if err := waitCtx.Err(); err != nil {
 		c.inspectorFailed = true
 		return nil, err
 	}
 
 	c.inspectorUp = true
-	return c.inspector, ctx.Err()
+	return withContext(ctx, c.inspector), waitCtx.Err()
+}
+
+// withContext returns a copy of client whose requests are made with ctx. The copy shares the client's connections and
+// authentication.
+func withContext(ctx context.Context, client *gophercloud.ServiceClient) *gophercloud.ServiceClient {
+	if client == nil {
+		return nil
+	}
+	provider := *client.ProviderClient
+	provider.Context = ctx
+	bound := *client
+	bound.ProviderClient = &provider
+	return &bound
+}
+
+// nodeDefaultFields are the node fields the provider's node_defaults block may set.
+var nodeDefaultFields = []string{
+	"boot_interface",
+	"console_interface",
+	"deploy_interface",
+	"inspect_interface",
+	"management_interface",
+	"network_interface",
+	"power_interface",
+	"raid_interface",
+	"rescue_interface",
+	"resource_class",
+	"storage_interface",
+	"vendor_interface",
+}
+
+// nodeDefaultNetworkFields are the neutron networks the provider's node_defaults block may set. They're stored in the
+// driver_info of nodes using the neutron network interface, under the same keys.
+var nodeDefaultNetworkFields = []string{
+	"provisioning_network",
+	"cleaning_network",
+	"rescuing_network",
+}
+
+// nodeDefaultsSchema returns the schema of the node_defaults block.
+func nodeDefaultsSchema() map[string]*schema.Schema {
+	fields := make(map[string]*schema.Schema)
+	for _, field := range nodeDefaultFields {
+		fields[field] = &schema.Schema{
+			Type:     schema.TypeString,
+			Optional: true,
+		}
+	}
+	for _, field := range nodeDefaultNetworkFields {
+		fields[field] = &schema.Schema{
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.IsUUID,
+		}
+	}
+	return fields
+}
+
+// expandNodeDefaults returns the values set in the node_defaults block.
+func expandNodeDefaults(list []interface{}) map[string]string {
+	defaults := make(map[string]string)
+	if len(list) == 0 || list[0] == nil {
+		return defaults
+	}
+	for field, value := range list[0].(map[string]interface{}) {
+		if value := value.(string); value != "" {
+			defaults[field] = value
+		}
+	}
+	return defaults
 }
 
 // Provider Ironic
-func Provider() terraform.ResourceProvider {
-	return &schema.Provider{
+func Provider() *schema.Provider {
+	provider := &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			"url": {
 				Type:        schema.TypeString,
@@ -170,6 +291,54 @@ func Provider() terraform.ResourceProvider {
 				Description: descriptions["timeout"],
 				Default:     0,
 			},
+			"insecure": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("IRONIC_INSECURE", false),
+				Description: descriptions["insecure"],
+			},
+			"ca_cert": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("IRONIC_CA_CERT", ""),
+				Description: descriptions["ca_cert"],
+			},
+			"ironic_ca_cert": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("IRONIC_API_CA_CERT", ""),
+				Description: descriptions["ironic_ca_cert"],
+			},
+			"client_cert": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("IRONIC_CLIENT_CERT", ""),
+				Description: descriptions["client_cert"],
+			},
+			"client_key": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.EnvDefaultFunc("IRONIC_CLIENT_KEY", ""),
+				Description: descriptions["client_key"],
+			},
+			"proxy": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  descriptions["proxy"],
+				ValidateFunc: validation.IsURLWithScheme([]string{"http", "https", "socks5"}),
+			},
+			"no_proxy": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: descriptions["no_proxy"],
+			},
+			"retry_jitter": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: descriptions["retry_jitter"],
+			},
 			"auth_strategy": {
 				Type:        schema.TypeString,
 				Optional:    true,
@@ -203,20 +372,40 @@ func Provider() terraform.ResourceProvider {
 				Optional:    true,
 				Sensitive:   true,
 				DefaultFunc: schema.EnvDefaultFunc("INSPECTOR_HTTP_BASIC_PASSWORD", ""),
-				Description: descriptions["inspector_username"],
+				Description: descriptions["inspector_password"],
+			},
+			"node_defaults": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: descriptions["node_defaults"],
+				Elem: &schema.Resource{
+					Schema: nodeDefaultsSchema(),
+				},
 			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
-			"ironic_node_v1":       resourceNodeV1(),
-			"ironic_port_v1":       resourcePortV1(),
-			"ironic_allocation_v1": resourceAllocationV1(),
-			"ironic_deployment":    resourceDeployment(),
+			"ironic_node_v1":            stopOnInterrupt(resourceNodeV1()),
+			"ironic_port_v1":            stopOnInterrupt(resourcePortV1()),
+			"ironic_allocation_v1":      stopOnInterrupt(resourceAllocationV1()),
+			"ironic_deployment":         stopOnInterrupt(resourceDeployment()),
+			"ironic_deploy_template_v1": stopOnInterrupt(resourceDeployTemplateV1()),
+			"ironic_node_power_v1":      stopOnInterrupt(resourceNodePowerV1()),
 		},
 		DataSourcesMap: map[string]*schema.Resource{
-			"ironic_introspection": dataSourceIronicIntrospection(),
+			"ironic_conductors":      stopOnInterrupt(dataSourceIronicConductors()),
+			"ironic_introspection":   stopOnInterrupt(dataSourceIronicIntrospection()),
+			"ironic_node_wait":       stopOnInterrupt(dataSourceIronicNodeWait()),
+			"ironic_node_v1":         stopOnInterrupt(dataSourceIronicNodeV1()),
+			"ironic_node_sensors":    stopOnInterrupt(dataSourceIronicNodeSensors()),
+			"ironic_nodes":           stopOnInterrupt(dataSourceIronicNodes()),
+			"ironic_raid_properties": stopOnInterrupt(dataSourceIronicRAIDProperties()),
+			"ironic_redfish_systems": stopOnInterrupt(dataSourceIronicRedfishSystems()),
 		},
-		ConfigureFunc: configureProvider,
+		ConfigureContextFunc: configureProvider,
 	}
+
+	return provider
 }
 
 var descriptions map[string]string
@@ -227,16 +416,58 @@ func init() {
 		"inspector":          "The endpoint for Ironic inspector",
 		"microversion":       "The microversion to use for Ironic",
 		"timeout":            "Wait at least the specified number of seconds for the API to become available",
+		"insecure":           "Skip verification of the TLS certificates presented by Ironic and Inspector",
+		"ca_cert":            "A PEM encoded CA certificate bundle, or the path to one, used to verify the TLS certificates presented by Ironic and Inspector",
+		"ironic_ca_cert":     "A PEM encoded CA certificate bundle, or the path to one, used to verify the TLS certificate presented by Ironic only, in place of ca_cert",
+		"client_cert":        "A PEM encoded client certificate, or the path to one, used for mutual TLS with Ironic and Inspector",
+		"client_key":         "A PEM encoded private key for client_cert, or the path to one",
+		"proxy":              "The proxy used to reach Ironic and Inspector. Defaults to the HTTP_PROXY and HTTPS_PROXY environment variables.",
+		"no_proxy":           "Comma-separated hosts, domains and CIDRs that are reached without the proxy, or * for none. Defaults to the NO_PROXY environment variable.",
+		"retry_jitter":       "Randomize the backoff when retrying requests Ironic rejected as busy, so concurrent operations don't retry in lockstep. Defaults to true.",
 		"auth_strategy":      "Determine the strategy to use for authentication with Ironic services, Possible values: noauth, http_basic. Defaults to noauth.",
 		"ironic_username":    "Username to be used by Ironic when using `http_basic` authentication",
 		"ironic_password":    "Password to be used by Ironic when using `http_basic` authentication",
 		"inspector_username": "Username to be used by Ironic Inspector when using `http_basic` authentication",
 		"inspector_password": "Password to be used by Ironic Inspector when using `http_basic` authentication",
+		"node_defaults":      "Resource class, interfaces and neutron networks used for nodes that don't set them. Values set on a node take precedence.",
+	}
+}
+
+// configureProvider creates the Ironic and Inspector clients.
+func configureProvider(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+	clients, err := newClients(d)
+	if err != nil {
+		return nil, diag.FromErr(err)
+	}
+	clients.stopContext, _ = ctx.Value(schema.StopContextKey).(context.Context)
+	return clients, nil
+}
+
+// crudFunc is the signature shared by the context-aware CRUD functions of a resource.
+type crudFunc = func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics
+
+// stopOnInterrupt makes the resource's operations stop when Terraform is interrupted, by giving them a context that's
+// also canceled by the provider's stop context.
+func stopOnInterrupt(r *schema.Resource) *schema.Resource {
+	wrap := func(fn crudFunc) crudFunc {
+		if fn == nil {
+			return nil
+		}
+		return func(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+			ctx, cancel := meta.(*Clients).withStopContext(ctx)
+			defer cancel()
+			return fn(ctx, d, meta)
+		}
 	}
+	r.CreateContext = wrap(r.CreateContext)
+	r.ReadContext = wrap(r.ReadContext)
+	r.UpdateContext = wrap(r.UpdateContext)
+	r.DeleteContext = wrap(r.DeleteContext)
+	return r
 }
 
 // Creates a noauth Ironic client
-func configureProvider(schema *schema.ResourceData) (interface{}, error) {
+func newClients(schema *schema.ResourceData) (*Clients, error) {
 	var clients Clients
 
 	url := schema.Get("url").(string)
@@ -248,10 +479,14 @@ func configureProvider(schema *schema.ResourceData) (interface{}, error) {
 	authStrategy := schema.Get("auth_strategy").(string)
 
 	if authStrategy == "http_basic" {
-		log.Printf("[DEBUG] Using http_basic auth_strategy")
-
 		ironicUser := schema.Get("ironic_username").(string)
 		ironicPassword := schema.Get("ironic_password").(string)
+		if ironicUser == "" || ironicPassword == "" {
+			return nil, fmt.Errorf("ironic_username and ironic_password are required when auth_strategy is http_basic")
+		}
+		// Never log the password
+		log.Printf("[DEBUG] Using http_basic auth_strategy as user %s", ironicUser)
+
 		ironic, err := httpbasic.NewBareMetalHTTPBasic(httpbasic.EndpointOpts{
 			IronicEndpoint:     url,
 			IronicUser:         ironicUser,
@@ -269,7 +504,10 @@ func configureProvider(schema *schema.ResourceData) (interface{}, error) {
 		if inspectorURL != "" {
 			inspectorUser := schema.Get("inspector_username").(string)
 			inspectorPassword := schema.Get("inspector_password").(string)
-			log.Printf("[DEBUG] Inspector endpoint is %s", inspectorURL)
+			if inspectorUser == "" || inspectorPassword == "" {
+				return nil, fmt.Errorf("inspector_username and inspector_password are required when auth_strategy is http_basic")
+			}
+			log.Printf("[DEBUG] Inspector endpoint is %s, using http_basic as user %s", inspectorURL, inspectorUser)
 
 			inspector, err := httpbasicintrospection.NewBareMetalIntrospectionHTTPBasic(httpbasicintrospection.EndpointOpts{
 				IronicInspectorEndpoint:     inspectorURL,
@@ -308,15 +546,200 @@ func configureProvider(schema *schema.ResourceData) (interface{}, error) {
 
 	}
 
+	tlsConfig, err := buildTLSConfig(schema.Get("insecure").(bool), schema.Get("ca_cert").(string),
+		schema.Get("client_cert").(string), schema.Get("client_key").(string))
+	if err != nil {
+		return nil, err
+	}
+	// Ironic may use another CA than the other endpoints, e.g. in mixed environments
+	ironicTLSConfig, ironicCAOption := tlsConfig, "ca_cert"
+	if ironicCACert := schema.Get("ironic_ca_cert").(string); ironicCACert != "" {
+		caCertPool, err := buildCACertPool("ironic_ca_cert", ironicCACert)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig != nil {
+			ironicTLSConfig = tlsConfig.Clone()
+		} else {
+			ironicTLSConfig = &tls.Config{}
+		}
+		ironicTLSConfig.RootCAs = caCertPool
+		ironicCAOption = "ironic_ca_cert"
+	}
+	proxy, noProxy := schema.Get("proxy").(string), schema.Get("no_proxy").(string)
+	if ironicTLSConfig != nil || proxy != "" || noProxy != "" {
+		proxyFunc, err := buildProxyFunc(proxy, noProxy)
+		if err != nil {
+			return nil, err
+		}
+		clients.ironic.HTTPClient.Transport = &tlsErrorTransport{
+			transport: &http.Transport{
+				Proxy:           proxyFunc,
+				TLSClientConfig: ironicTLSConfig,
+			},
+			caOption: ironicCAOption,
+		}
+		if clients.inspector != nil {
+			clients.inspector.HTTPClient.Transport = &tlsErrorTransport{
+				transport: &http.Transport{
+					Proxy:           proxyFunc,
+					TLSClientConfig: tlsConfig,
+				},
+				caOption: "ca_cert",
+			}
+		}
+	}
+
 	clients.timeout = schema.Get("timeout").(int)
+	clients.nodeDefaults = expandNodeDefaults(schema.Get("node_defaults").([]interface{}))
+	clients.retryJitter = schema.Get("retry_jitter").(bool)
 
 	return &clients, nil
 }
 
+// buildTLSConfig returns the TLS configuration for the API clients, or nil if the defaults should be used. The
+// certificates and key may either be PEM data, or a path to a file containing it.
+func buildTLSConfig(insecure bool, caCert, clientCert, clientKey string) (*tls.Config, error) {
+	if !insecure && caCert == "" && clientCert == "" && clientKey == "" {
+		return nil, nil
+	}
+
+	if (clientCert == "") != (clientKey == "") {
+		return nil, fmt.Errorf("client_cert and client_key must be specified together")
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: insecure,
+	}
+
+	if caCert != "" {
+		caCertPool, err := buildCACertPool("ca_cert", caCert)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	if clientCert != "" {
+		certPEM, err := readPEM(clientCert)
+		if err != nil {
+			return nil, fmt.Errorf("could not read client_cert: %s", err)
+		}
+		keyPEM, err := readPEM(clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not read client_key: %s", err)
+		}
+
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client_cert and client_key: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// buildCACertPool returns the pool of the CA certificates in caCert, which is either PEM data or a path to a file
+// containing it. option is the provider option caCert comes from, for errors.
+func buildCACertPool(option, caCert string) (*x509.CertPool, error) {
+	pem, err := readPEM(caCert)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %s", option, err)
+	}
+
+	caCertPool := x509.NewCertPool()
+	if !caCertPool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s does not contain any valid PEM encoded certificates", option)
+	}
+	return caCertPool, nil
+}
+
+// buildProxyFunc returns the proxy function for the API clients. Hosts matching noProxy are reached directly, and the
+// others through proxy, or the proxy from the environment if it's empty.
+func buildProxyFunc(proxy, noProxy string) (func(*http.Request) (*url.URL, error), error) {
+	var proxyURL *url.URL
+	if proxy != "" {
+		var err error
+		proxyURL, err = url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse proxy: %s", err)
+		}
+	}
+
+	var bypass []string
+	for _, entry := range strings.Split(noProxy, ",") {
+		if entry = strings.ToLower(strings.TrimSpace(entry)); entry != "" {
+			bypass = append(bypass, entry)
+		}
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassesProxy(req.URL.Hostname(), bypass) {
+			return nil, nil
+		}
+		if proxyURL == nil {
+			return http.ProxyFromEnvironment(req)
+		}
+		return proxyURL, nil
+	}, nil
+}
+
+// bypassesProxy determines if host matches one of the no_proxy entries: *, a host name, a domain and its subdomains,
+// an IP address or a CIDR.
+func bypassesProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, entry := range noProxy {
+		if entry == "*" || entry == host {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if ip == nil && strings.HasSuffix(host, "."+strings.TrimPrefix(entry, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// readPEM returns the given value if it's PEM data, otherwise it reads the file it points to.
+func readPEM(value string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return ioutil.ReadFile(value)
+}
+
+// tlsErrorTransport makes TLS failures distinguishable from other connection and authentication errors. caOption is
+// the provider option with the CA certificates used to verify the endpoint.
+type tlsErrorTransport struct {
+	transport http.RoundTripper
+	caOption  string
+}
+
+func (t *tlsErrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	var unknownAuthority x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthority) {
+		return nil, fmt.Errorf("the TLS certificate of %s isn't signed by a trusted CA, check the %s provider option, "+
+			"this isn't an authentication error: %w", req.URL.Host, t.caOption, err)
+	}
+	if err != nil && (strings.Contains(err.Error(), "tls:") || strings.Contains(err.Error(), "x509:")) {
+		return nil, fmt.Errorf("TLS handshake with %s failed, check the %s, client_cert and client_key provider options: %w", req.URL.Host, t.caOption, err)
+	}
+	return resp, err
+}
+
 // Retries an API forever until it responds.
 func waitForAPI(ctx context.Context, client *gophercloud.ServiceClient) {
 	httpClient := &http.Client{
-		Timeout: 5 * time.Second,
+		Timeout:   5 * time.Second,
+		Transport: client.HTTPClient.Transport,
 	}
 
 	// NOTE: Some versions of Ironic inspector returns 404 for /v1/ but 200 for /v1,
@@ -325,22 +748,21 @@ func waitForAPI(ctx context.Context, client *gophercloud.ServiceClient) {
 	endpoint := strings.TrimSuffix(client.Endpoint, "/")
 
 	for {
+		log.Printf("[DEBUG] Waiting for API to become available...")
+
+		r, err := httpClient.Get(endpoint)
+		if err == nil {
+			statusCode := r.StatusCode
+			r.Body.Close()
+			if statusCode == http.StatusOK {
+				return
+			}
+		}
+
 		select {
+		case <-time.After(5 * time.Second):
 		case <-ctx.Done():
 			return
-		default:
-			log.Printf("[DEBUG] Waiting for API to become available...")
-
-			r, err := httpClient.Get(endpoint)
-			if err == nil {
-				statusCode := r.StatusCode
-				r.Body.Close()
-				if statusCode == http.StatusOK {
-					return
-				}
-			}
-
-			time.Sleep(5 * time.Second)
 		}
 	}
 }
@@ -348,29 +770,28 @@ func waitForAPI(ctx context.Context, client *gophercloud.ServiceClient) {
 // Ironic conductor can be considered up when the driver count returns non-zero.
 func waitForConductor(ctx context.Context, client *gophercloud.ServiceClient) {
 	for {
+		log.Printf("[DEBUG] Waiting for conductor API to become available...")
+		driverCount := 0
+
+		err := drivers.ListDrivers(client, drivers.ListDriversOpts{
+			Detail: false,
+		}).EachPage(func(page pagination.Page) (bool, error) {
+			actual, err := drivers.ExtractDrivers(page)
+			if err != nil {
+				return false, err
+			}
+			driverCount += len(actual)
+			return true, nil
+		})
+		// If we have any drivers, conductor is up.
+		if err == nil && driverCount > 0 {
+			return
+		}
+
 		select {
+		case <-time.After(5 * time.Second):
 		case <-ctx.Done():
 			return
-		default:
-			log.Printf("[DEBUG] Waiting for conductor API to become available...")
-			driverCount := 0
-
-			err := drivers.ListDrivers(client, drivers.ListDriversOpts{
-				Detail: false,
-			}).EachPage(func(page pagination.Page) (bool, error) {
-				actual, err := drivers.ExtractDrivers(page)
-				if err != nil {
-					return false, err
-				}
-				driverCount += len(actual)
-				return true, nil
-			})
-			// If we have any drivers, conductor is up.
-			if err == nil && driverCount > 0 {
-				return
-			}
-
-			time.Sleep(5 * time.Second)
 		}
 	}
 }
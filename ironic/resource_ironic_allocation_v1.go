@@ -1,21 +1,27 @@
 package ironic
 
 import (
-	"fmt"
+	"context"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/allocations"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
 // Schema resource definition for an Ironic allocation.
 func resourceAllocationV1() *schema.Resource {
 	return &schema.Resource{
-		Create: resourceAllocationV1Create,
-		Read:   resourceAllocationV1Read,
-		Delete: resourceAllocationV1Delete,
+		CreateContext: resourceAllocationV1Create,
+		ReadContext:   resourceAllocationV1Read,
+		DeleteContext: resourceAllocationV1Delete,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(1 * time.Minute),
+		},
 
 		Schema: map[string]*schema.Schema{
 			"name": {
@@ -67,97 +73,131 @@ func resourceAllocationV1() *schema.Resource {
 }
 
 // Create an allocation, including driving Ironic's state machine
-func resourceAllocationV1Create(d *schema.ResourceData, meta interface{}) error {
-	client, err := meta.(*Clients).GetIronicClient()
+func resourceAllocationV1Create(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, err := meta.(*Clients).GetIronicClient(ctx)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	result, err := allocations.Create(client, allocationSchemaToCreateOpts(d)).Extract()
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	d.SetId(result.UUID)
 
 	// Wait for state to change from allocating
-	timeout := 1 * time.Minute
+	timeout := d.Timeout(schema.TimeoutCreate)
 	checkInterval := 2 * time.Second
 
 	for {
-		err = resourceAllocationV1Read(d, meta)
-		if err != nil {
-			return err
-		}
-		state := d.Get("state").(string)
-		log.Printf("[DEBUG] Requested allocation %s; current state is '%s'\n", d.Id(), state)
-		switch state {
-		case "allocating":
-			time.Sleep(checkInterval)
-			checkInterval += 2
-			timeout -= checkInterval
-			if timeout < 0 {
-				return fmt.Errorf("timed out waiting for allocation")
+		result, err = allocations.Get(client, d.Id()).Extract()
+		switch err.(type) {
+		case nil:
+			log.Printf("[DEBUG] Requested allocation %s; current state is '%s'\n", d.Id(), result.State)
+			switch result.State {
+			case "allocating":
+			case "error":
+				_ = resourceAllocationV1Delete(ctx, d, meta)
+				d.SetId("")
+				if isNoMatchingNodeError(result.LastError) {
+					return diag.Errorf("no node matches the allocation request: %s", result.LastError)
+				}
+				return diag.Errorf("error creating resource: %s", result.LastError)
+			default:
+				return resourceAllocationV1Read(ctx, d, meta)
 			}
-		case "error":
-			err := d.Get("last_error").(string)
-			_ = resourceAllocationV1Delete(d, meta)
-			d.SetId("")
-			return fmt.Errorf("error creating resource: %s", err)
+		case gophercloud.ErrDefault403, gophercloud.ErrDefault404:
+			// Waiting won't let us see an allocation we may not read, or bring back one that's gone
+			return diag.Errorf("could not read allocation %s: %s", d.Id(), err)
 		default:
-			return nil
+			// Other failed reads are likely transient, keep trying until we time out
+			log.Printf("[DEBUG] Could not read allocation %s, will retry: %s", d.Id(), err)
+		}
+
+		select {
+		case <-time.After(checkInterval):
+		case <-ctx.Done():
+			return diag.Errorf("interrupted while waiting for allocation %s", d.Id())
+		}
+		timeout -= checkInterval
+		if timeout < 0 {
+			if err != nil {
+				return diag.Errorf("timed out waiting for allocation: %s", err)
+			}
+			return diag.Errorf("timed out waiting for allocation")
+		}
+	}
+}
+
+// noMatchingNodeErrors are the starts of the errors Ironic records when no node matches an allocation's resource
+// class, traits, or candidate nodes, in lower case as they're matched regardless of case.
+var noMatchingNodeErrors = []string{
+	"no available nodes",
+	"no suitable nodes",
+	"none of the requested nodes",
+}
+
+// isNoMatchingNodeError determines if an allocation failed because there was no suitable node, rather than due to
+// an error in Ironic.
+func isNoMatchingNodeError(lastError string) bool {
+	lastError = strings.ToLower(lastError)
+	for _, message := range noMatchingNodeErrors {
+		if strings.Contains(lastError, message) {
+			return true
 		}
 	}
+	return false
 }
 
 // Read the allocation's data from Ironic
-func resourceAllocationV1Read(d *schema.ResourceData, meta interface{}) error {
-	client, err := meta.(*Clients).GetIronicClient()
+func resourceAllocationV1Read(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, err := meta.(*Clients).GetIronicClient(ctx)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	result, err := allocations.Get(client, d.Id()).Extract()
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	err = d.Set("name", result.Name)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("resource_class", result.ResourceClass)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("candidate_nodes", result.CandidateNodes)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("traits", result.Traits)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("extra", result.Extra)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("node_uuid", result.NodeUUID)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("state", result.State)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	return d.Set("last_error", result.LastError)
+	return diag.FromErr(d.Set("last_error", result.LastError))
 }
 
 // Delete an allocation from Ironic if it exists
-func resourceAllocationV1Delete(d *schema.ResourceData, meta interface{}) error {
-	client, err := meta.(*Clients).GetIronicClient()
+func resourceAllocationV1Delete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, err := meta.(*Clients).GetIronicClient(ctx)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	_, err = allocations.Get(client, d.Id()).Extract()
@@ -165,7 +205,7 @@ func resourceAllocationV1Delete(d *schema.ResourceData, meta interface{}) error
 		return nil
 	}
 
-	return allocations.Delete(client, d.Id()).ExtractErr()
+	return diag.FromErr(allocations.Delete(client, d.Id()).ExtractErr())
 }
 
 func allocationSchemaToCreateOpts(d *schema.ResourceData) *allocations.CreateOpts {
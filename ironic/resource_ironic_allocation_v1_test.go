@@ -3,14 +3,18 @@
 package ironic
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"testing"
 
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/allocations"
 	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
-	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	gth "github.com/gophercloud/gophercloud/testhelper"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
 )
 
@@ -55,7 +59,7 @@ func TestAccIronicAllocation(t *testing.T) {
 // Calls gophercloud directly to ensure the allocation exists
 func testAccCheckAllocationExists(name string, allocation *allocations.Allocation) resource.TestCheckFunc {
 	return func(state *terraform.State) error {
-		client, err := testAccProvider.Meta().(*Clients).GetIronicClient()
+		client, err := testAccProvider.Meta().(*Clients).GetIronicClient(context.Background())
 		if err != nil {
 			return err
 		}
@@ -82,7 +86,7 @@ func testAccCheckAllocationExists(name string, allocation *allocations.Allocatio
 
 // Calls gophercloud to ensure the allocation was destroyed
 func testAccAllocationDestroy(state *terraform.State) error {
-	client, err := testAccProvider.Meta().(*Clients).GetIronicClient()
+	client, err := testAccProvider.Meta().(*Clients).GetIronicClient(context.Background())
 	if err != nil {
 		return err
 	}
@@ -126,3 +130,64 @@ func testAccAllocationResource(node, resourceClass, allocation string) string {
 			]
 		}`, node, node, resourceClass, allocation, allocation, resourceClass, node)
 }
+
+func TestIsNoMatchingNodeError(t *testing.T) {
+	cases := []struct {
+		Scenario  string
+		LastError string
+		Expected  bool
+	}{
+		{Scenario: "no node of the resource class", LastError: "Failed to process allocation 6b3e1ea7-2d26-4a97-a2c8-2e9c4b6b3a1e: No available nodes match the resource class baremetal.", Expected: true},
+		{Scenario: "no candidate node of the resource class", LastError: "Failed to process allocation 6b3e1ea7-2d26-4a97-a2c8-2e9c4b6b3a1e: None of the requested nodes are available and match the resource class baremetal.", Expected: true},
+		{Scenario: "no node with the traits", LastError: "Failed to process allocation 6b3e1ea7-2d26-4a97-a2c8-2e9c4b6b3a1e: No suitable nodes match the requested traits CUSTOM_GPU.", Expected: true},
+		{Scenario: "no candidate node with the traits", LastError: "Failed to process allocation 6b3e1ea7-2d26-4a97-a2c8-2e9c4b6b3a1e: None of the requested nodes match the requested traits CUSTOM_GPU.", Expected: true},
+		{Scenario: "lower case", LastError: "Failed to process allocation 6b3e1ea7-2d26-4a97-a2c8-2e9c4b6b3a1e: no available nodes match the resource class baremetal.", Expected: true},
+		{Scenario: "nodes locked", LastError: "Failed to process allocation 6b3e1ea7-2d26-4a97-a2c8-2e9c4b6b3a1e: All nodes that matched the resource class baremetal and the traits CUSTOM_GPU are currently locked.", Expected: false},
+		{Scenario: "database error", LastError: "Failed to process allocation 6b3e1ea7-2d26-4a97-a2c8-2e9c4b6b3a1e: Database connection lost.", Expected: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			if actual := isNoMatchingNodeError(c.LastError); actual != c.Expected {
+				t.Errorf("expected %t, got %t", c.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestCreateAllocationNotRetried(t *testing.T) {
+	cases := []struct {
+		Scenario      string
+		Status        int
+		ExpectedError string
+	}{
+		{Scenario: "forbidden", Status: http.StatusForbidden, ExpectedError: "could not read allocation allocation-0"},
+		{Scenario: "deleted", Status: http.StatusNotFound, ExpectedError: "could not read allocation allocation-0"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			gth.SetupHTTP()
+			defer gth.TeardownHTTP()
+
+			gth.Mux.HandleFunc("/allocations", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprint(w, `{"uuid": "allocation-0", "state": "allocating"}`)
+			})
+			reads := 0
+			gth.Mux.HandleFunc("/allocations/allocation-0", func(w http.ResponseWriter, r *http.Request) {
+				reads++
+				w.WriteHeader(c.Status)
+			})
+
+			client := &gophercloud.ServiceClient{ProviderClient: &gophercloud.ProviderClient{}, Endpoint: gth.Endpoint()}
+			d := schema.TestResourceDataRaw(t, resourceAllocationV1().Schema, map[string]interface{}{"resource_class": "baremetal"})
+			diags := resourceAllocationV1Create(context.Background(), d, &Clients{ironic: client})
+			th.AssertDiagError(t, diags, c.ExpectedError)
+			if reads != 1 {
+				t.Errorf("expected the allocation to be read once, got %d reads", reads)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package ironic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/structure"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// traitPattern matches the names Ironic accepts for a trait, and so for a deploy template.
+var traitPattern = regexp.MustCompile(`^[A-Z0-9_]{1,255}$`)
+
+// Schema resource definition for an Ironic deploy template, which maps a trait to the deploy steps run on nodes
+// deployed with it.
+func resourceDeployTemplateV1() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceDeployTemplateV1Create,
+		ReadContext:   resourceDeployTemplateV1Read,
+		UpdateContext: resourceDeployTemplateV1Update,
+		DeleteContext: resourceDeployTemplateV1Delete,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceDeployTemplateV1Import,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringMatch(traitPattern, "must be a trait, e.g. CUSTOM_HYPERTHREADING_ON"),
+			},
+			"steps": {
+				Type:     schema.TypeList,
+				Required: true,
+				MinItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"interface": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"step": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"args": {
+							Type:             schema.TypeString,
+							Optional:         true,
+							Default:          "{}",
+							ValidateFunc:     validation.StringIsJSON,
+							DiffSuppressFunc: jsonEquivalent,
+							Description:      "JSON object of the step's arguments",
+						},
+						"priority": {
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validation.IntAtLeast(0),
+						},
+					},
+				},
+			},
+			"extra": {
+				Type:     schema.TypeMap,
+				Optional: true,
+			},
+		},
+	}
+}
+
+func resourceDeployTemplateV1Create(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, err := meta.(*Clients).GetIronicClient(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := checkDeployTemplateSupport(client.Microversion); err != nil {
+		return diag.FromErr(err)
+	}
+
+	steps, err := expandDeployTemplateSteps(d.Get("steps").([]interface{}))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	result, err := createDeployTemplate(client, deployTemplate{
+		Name:  d.Get("name").(string),
+		Steps: steps,
+		Extra: d.Get("extra").(map[string]interface{}),
+	})
+	if err != nil {
+		return diag.Errorf("could not create deploy template: %s", err)
+	}
+	d.SetId(result.UUID)
+
+	return resourceDeployTemplateV1Read(ctx, d, meta)
+}
+
+func resourceDeployTemplateV1Read(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, err := meta.(*Clients).GetIronicClient(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := checkDeployTemplateSupport(client.Microversion); err != nil {
+		return diag.FromErr(err)
+	}
+
+	template, err := getDeployTemplate(client, d.Id())
+	if err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); ok {
+			d.SetId("")
+			return nil
+		}
+		return diag.FromErr(err)
+	}
+
+	err = d.Set("name", template.Name)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	steps, err := flattenDeployTemplateSteps(template.Steps)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("steps", steps)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	return diag.FromErr(d.Set("extra", template.Extra))
+}
+
+func resourceDeployTemplateV1Update(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, err := meta.(*Clients).GetIronicClient(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := checkDeployTemplateSupport(client.Microversion); err != nil {
+		return diag.FromErr(err)
+	}
+
+	var patch []deployTemplatePatch
+	if d.HasChange("name") {
+		patch = append(patch, deployTemplatePatch{Op: "replace", Path: "/name", Value: d.Get("name").(string)})
+	}
+	if d.HasChange("steps") {
+		steps, err := expandDeployTemplateSteps(d.Get("steps").([]interface{}))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		patch = append(patch, deployTemplatePatch{Op: "replace", Path: "/steps", Value: steps})
+	}
+	if d.HasChange("extra") {
+		patch = append(patch, deployTemplatePatch{Op: "add", Path: "/extra", Value: d.Get("extra").(map[string]interface{})})
+	}
+
+	if len(patch) > 0 {
+		if _, err := updateDeployTemplate(client, d.Id(), patch); err != nil {
+			return diag.Errorf("could not update deploy template %s: %s", d.Id(), err)
+		}
+	}
+
+	return resourceDeployTemplateV1Read(ctx, d, meta)
+}
+
+func resourceDeployTemplateV1Delete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, err := meta.(*Clients).GetIronicClient(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := checkDeployTemplateSupport(client.Microversion); err != nil {
+		return diag.FromErr(err)
+	}
+
+	err = deleteDeployTemplate(client, d.Id())
+	if _, ok := err.(gophercloud.ErrDefault404); ok {
+		return nil
+	}
+	return diag.FromErr(err)
+}
+
+// resourceDeployTemplateV1Import imports a deploy template by its UUID or name.
+func resourceDeployTemplateV1Import(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	client, err := meta.(*Clients).GetIronicClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDeployTemplateSupport(client.Microversion); err != nil {
+		return nil, err
+	}
+
+	template, err := getDeployTemplate(client, d.Id())
+	if err != nil {
+		return nil, fmt.Errorf("could not get deploy template %s: %s", d.Id(), err)
+	}
+	d.SetId(template.UUID)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// expandDeployTemplateSteps converts the steps in the schema to deploy template steps.
+func expandDeployTemplateSteps(raw []interface{}) ([]deployTemplateStep, error) {
+	steps := make([]deployTemplateStep, 0, len(raw))
+	for i, r := range raw {
+		step := r.(map[string]interface{})
+		args := make(map[string]interface{})
+		if a := step["args"].(string); a != "" {
+			var err error
+			args, err = structure.ExpandJsonFromString(a)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse the args of step %d: %s", i, err)
+			}
+		}
+		steps = append(steps, deployTemplateStep{
+			Interface: step["interface"].(string),
+			Step:      step["step"].(string),
+			Args:      args,
+			Priority:  step["priority"].(int),
+		})
+	}
+	return steps, nil
+}
+
+// flattenDeployTemplateSteps converts deploy template steps to the schema's steps.
+func flattenDeployTemplateSteps(steps []deployTemplateStep) ([]map[string]interface{}, error) {
+	flattened := make([]map[string]interface{}, 0, len(steps))
+	for _, step := range steps {
+		args := step.Args
+		if args == nil {
+			args = make(map[string]interface{})
+		}
+		b, err := json.Marshal(args)
+		if err != nil {
+			return nil, err
+		}
+		flattened = append(flattened, map[string]interface{}{
+			"interface": step.Interface,
+			"step":      step.Step,
+			"args":      string(b),
+			"priority":  step.Priority,
+		})
+	}
+	return flattened, nil
+}
+
+// jsonEquivalent suppresses diffs between JSON documents that only differ in formatting.
+func jsonEquivalent(_, old, new string, _ *schema.ResourceData) bool {
+	var oldValue, newValue interface{}
+	if json.Unmarshal([]byte(old), &oldValue) != nil || json.Unmarshal([]byte(new), &newValue) != nil {
+		return false
+	}
+	return reflect.DeepEqual(oldValue, newValue)
+}
@@ -0,0 +1,108 @@
+// +build acceptance
+
+package ironic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+	gth "github.com/gophercloud/gophercloud/testhelper"
+	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
+)
+
+func TestDeployTemplateSteps(t *testing.T) {
+	raw := []interface{}{
+		map[string]interface{}{
+			"interface": "bios",
+			"step":      "apply_configuration",
+			"args":      `{"settings": [{"name": "LogicalProc", "value": "Enabled"}]}`,
+			"priority":  150,
+		},
+		map[string]interface{}{
+			"interface": "deploy",
+			"step":      "write_image",
+			"args":      "{}",
+			"priority":  0,
+		},
+	}
+
+	steps, err := expandDeployTemplateSteps(raw)
+	th.AssertNoError(t, err)
+	expected := []deployTemplateStep{
+		{
+			Interface: "bios",
+			Step:      "apply_configuration",
+			Args: map[string]interface{}{
+				"settings": []interface{}{map[string]interface{}{"name": "LogicalProc", "value": "Enabled"}},
+			},
+			Priority: 150,
+		},
+		{Interface: "deploy", Step: "write_image", Args: map[string]interface{}{}},
+	}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Fatalf("expected steps %v, got %v", expected, steps)
+	}
+
+	flattened, err := flattenDeployTemplateSteps(steps)
+	th.AssertNoError(t, err)
+	for i, step := range flattened {
+		original := raw[i].(map[string]interface{})
+		if !jsonEquivalent("", original["args"].(string), step["args"].(string), nil) {
+			t.Errorf("expected args %s, got %s", original["args"], step["args"])
+		}
+		if step["priority"] != original["priority"] {
+			t.Errorf("expected priority %v, got %v", original["priority"], step["priority"])
+		}
+	}
+}
+
+func TestDeployTemplateNameValidation(t *testing.T) {
+	validate := resourceDeployTemplateV1().Schema["name"].ValidateFunc
+
+	if _, errs := validate("CUSTOM_HYPERTHREADING_ON", "name"); len(errs) != 0 {
+		t.Errorf("expected a valid trait, got %v", errs)
+	}
+	if _, errs := validate("hyperthreading-on", "name"); len(errs) == 0 {
+		t.Error("expected an error for a name that isn't a trait")
+	}
+}
+
+func TestCheckDeployTemplateSupport(t *testing.T) {
+	th.AssertNoError(t, checkDeployTemplateSupport("1.55"))
+	th.AssertNoError(t, checkDeployTemplateSupport("1.82"))
+	th.AssertError(t, checkDeployTemplateSupport("1.52"), "deploy templates require microversion 1.55 or later, got '1.52'")
+
+	// The provider's default microversion is rejected before any request is made
+	meta := &Clients{ironic: &gophercloud.ServiceClient{ProviderClient: &gophercloud.ProviderClient{}, Microversion: "1.52"}}
+	d := resourceDeployTemplateV1().TestResourceData()
+	d.SetId("template-0")
+	th.AssertDiagError(t, resourceDeployTemplateV1Read(context.Background(), d, meta), "deploy templates require microversion 1.55 or later, got '1.52'")
+}
+
+func TestCreateDeployTemplate(t *testing.T) {
+	gth.SetupHTTP()
+	defer gth.TeardownHTTP()
+
+	gth.Mux.HandleFunc("/deploy_templates", func(w http.ResponseWriter, r *http.Request) {
+		gth.TestMethod(t, r, "POST")
+		gth.TestJSONRequest(t, r, `{"name": "CUSTOM_RAID1", "steps": [{"interface": "raid", "step": "delete_configuration", "args": {}, "priority": 200}]}`)
+
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"uuid": "template-0", "name": "CUSTOM_RAID1", "steps": [{"interface": "raid", "step": "delete_configuration", "args": {}, "priority": 200}]}`)
+	})
+
+	client := &gophercloud.ServiceClient{ProviderClient: &gophercloud.ProviderClient{}, Endpoint: gth.Endpoint()}
+	result, err := createDeployTemplate(client, deployTemplate{
+		Name:  "CUSTOM_RAID1",
+		Steps: []deployTemplateStep{{Interface: "raid", Step: "delete_configuration", Args: map[string]interface{}{}, Priority: 200}},
+	})
+	th.AssertNoError(t, err)
+	if result.UUID != "template-0" {
+		t.Errorf("expected UUID template-0, got %s", result.UUID)
+	}
+}
@@ -1,6 +1,7 @@
 package ironic
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
@@ -9,21 +10,34 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
 	utils "github.com/gophercloud/utils/openstack/baremetal/v1/nodes"
 	retryablehttp "github.com/hashicorp/go-retryablehttp"
 	"github.com/hashicorp/go-version"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 // Schema resource definition for an Ironic deployment.
 func resourceDeployment() *schema.Resource {
 	return &schema.Resource{
-		Create: resourceDeploymentCreate,
-		Read:   resourceDeploymentRead,
-		Delete: resourceDeploymentDelete,
+		CreateContext: resourceDeploymentCreate,
+		ReadContext:   resourceDeploymentRead,
+		UpdateContext: resourceDeploymentUpdate,
+		DeleteContext: resourceDeploymentDelete,
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Minute),
+			Delete: schema.DefaultTimeout(60 * time.Minute),
+		},
+
+		CustomizeDiff: resourceDeploymentCustomizeDiff,
 
 		Schema: map[string]*schema.Schema{
 			"name": {
@@ -40,6 +54,44 @@ func resourceDeployment() *schema.Resource {
 				Type:     schema.TypeMap,
 				Required: true,
 				ForceNew: true,
+				DiffSuppressFunc: func(_, old, _ string, _ *schema.ResourceData) bool {
+					// Ironic masks secrets such as the configdrive, they can't be compared
+					return old == "******"
+				},
+
+				// instance_info could contain a configdrive or credentials
+				Sensitive: true,
+			},
+			"deploy_capabilities": {
+				Type:         schema.TypeMap,
+				Optional:     true,
+				ForceNew:     true,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				ValidateFunc: validateDeployCapabilities,
+				Description:  "Capabilities merged into instance_info.capabilities, taking precedence over it",
+			},
+			"disk_label": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice(deployCapabilityValues["disk_label"], false),
+				Description:  "The disk label of a partition image, stored in instance_info.capabilities",
+			},
+			"image_type": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+				ValidateFunc: validation.StringInSlice([]string{
+					"whole-disk", "partition",
+				}, false),
+			},
+			"traits": {
+				Type: schema.TypeList,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+				Optional: true,
+				ForceNew: true,
 			},
 			"deploy_steps": {
 				Type:     schema.TypeString,
@@ -66,6 +118,13 @@ func resourceDeployment() *schema.Resource {
 				Optional: true,
 				ForceNew: true,
 			},
+			"config_drive_url": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ForceNew:      true,
+				ValidateFunc:  validation.IsURLWithScheme([]string{"http", "https"}),
+				ConflictsWith: []string{"user_data", "user_data_url", "network_data", "metadata"},
+			},
 			"network_data": {
 				Type:     schema.TypeMap,
 				Optional: true,
@@ -76,10 +135,26 @@ func resourceDeployment() *schema.Resource {
 				Optional: true,
 				ForceNew: true,
 			},
+			// Only used while deploying, so it can be changed without redeploying
+			"deploy_retries": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "How many times to redeploy the node if the deployment fails, e.g. on a transient image download error",
+			},
 			"provision_state": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"instance_uuid": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"last_step": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
 			"last_error": {
 				Type:     schema.TypeString,
 				Computed: true,
@@ -89,33 +164,78 @@ func resourceDeployment() *schema.Resource {
 }
 
 // Create an deployment, including driving Ironic's state machine
-func resourceDeploymentCreate(d *schema.ResourceData, meta interface{}) error {
-	client, err := meta.(*Clients).GetIronicClient()
+func resourceDeploymentCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, err := meta.(*Clients).GetIronicClient(ctx)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
+	}
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return diag.FromErr(err)
 	}
+	deployCtx, cancel := context.WithTimeout(ctx, d.Timeout(schema.TimeoutCreate))
+	defer cancel()
 
 	// Reload the resource before returning
-	defer func() { _ = resourceDeploymentRead(d, meta) }()
+	defer func() { _ = resourceDeploymentRead(ctx, d, meta) }()
 
 	nodeUUID := d.Get("node_uuid").(string)
 	// Set instance info
 	instanceInfo := d.Get("instance_info").(map[string]interface{})
+	if imageType := d.Get("image_type").(string); imageType != "" {
+		if err := validateImageType(imageType, instanceInfo); err != nil {
+			return diag.FromErr(err)
+		}
+		instanceInfo["image_type"] = imageType
+	}
+
+	// Requested traits must be a subset of the node's traits, check before deploying rather than letting it fail
+	if requested := d.Get("traits").([]interface{}); len(requested) > 0 {
+		node, err := getExtendedNode(api, nodeUUID)
+		if err != nil {
+			return diag.Errorf("could not get node %s: %s", nodeUUID, err)
+		}
+		traits := make([]string, len(requested))
+		for i := range requested {
+			traits[i] = requested[i].(string)
+		}
+		if missing := missingTraits(traits, node.Traits); len(missing) > 0 {
+			return diag.Errorf("node %s doesn't have the requested traits: %s", nodeUUID, strings.Join(missing, ", "))
+		}
+		if instanceInfo == nil {
+			instanceInfo = make(map[string]interface{})
+		}
+		instanceInfo["traits"] = traits
+	}
 	if instanceInfo != nil {
 		instanceInfoCapabilities, found := instanceInfo["capabilities"]
-		capabilities := make(map[string]string)
+		capabilities := make(map[string]interface{})
 		if found {
-			for _, e := range strings.Split(instanceInfoCapabilities.(string), ",") {
-				parts := strings.Split(e, ":")
-				if len(parts) != 2 {
-					return fmt.Errorf("error while parsing capabilities: %s, the correct format is key:value", e)
-				}
-				capabilities[parts[0]] = parts[1]
-
+			capabilities, err = parseCapabilities(instanceInfoCapabilities.(string))
+			if err != nil {
+				return diag.FromErr(err)
 			}
 			delete(instanceInfo, "capabilities")
 		}
-		_, err := UpdateNode(client, nodeUUID, nodes.UpdateOpts{
+		for k, v := range requestedCapabilities(d.Get("deploy_capabilities").(map[string]interface{}), d.Get("disk_label").(string)) {
+			capabilities[k] = v
+		}
+		// Without a requested boot mode, the node boots in its own, which is only known once it's been chosen
+		if capabilities["disk_label"] == "msdos" && capabilities["boot_mode"] == nil {
+			node, err := getExtendedNode(api, nodeUUID)
+			if err != nil {
+				return diag.Errorf("could not get node %s: %s", nodeUUID, err)
+			}
+			bootState, err := api.GetBootState(nodeUUID)
+			if err != nil {
+				log.Printf("[WARN] Could not get the boot mode of node %s: %s", nodeUUID, err)
+			}
+			bootMode, _, _ := nodeBootMode(node.Properties, bootState)
+			if err := validateDiskLabel(capabilities, bootMode); err != nil {
+				return diag.Errorf("cannot deploy node %s: %s", nodeUUID, err)
+			}
+		}
+		_, err := UpdateNode(ctx, api, nodeUUID, nodes.UpdateOpts{
 			nodes.UpdateOperation{
 				Op:    nodes.AddOp,
 				Path:  "/instance_info",
@@ -123,11 +243,11 @@ func resourceDeploymentCreate(d *schema.ResourceData, meta interface{}) error {
 			},
 		})
 		if err != nil {
-			return fmt.Errorf("could not update instance info: %s", err)
+			return diag.Errorf("could not update instance info: %s", err)
 		}
 
 		if len(capabilities) != 0 {
-			_, err = UpdateNode(client, nodeUUID, nodes.UpdateOpts{
+			_, err = UpdateNode(ctx, api, nodeUUID, nodes.UpdateOpts{
 				nodes.UpdateOperation{
 					Op:    nodes.AddOp,
 					Path:  "/instance_info/capabilities",
@@ -135,7 +255,7 @@ func resourceDeploymentCreate(d *schema.ResourceData, meta interface{}) error {
 				},
 			})
 			if err != nil {
-				return fmt.Errorf("could not update instance info capabilities: %s", err)
+				return diag.Errorf("could not update instance info capabilities: %s", err)
 			}
 		}
 	}
@@ -148,10 +268,16 @@ func resourceDeploymentCreate(d *schema.ResourceData, meta interface{}) error {
 	if len(dSteps) > 0 {
 		deploySteps, err = buildDeploySteps(dSteps)
 		if err != nil {
-			return fmt.Errorf("could not fetch deploy steps: %s", err)
+			return diag.Errorf("could not fetch deploy steps: %s", err)
 		}
 	}
 
+	// A pre-built config drive is fetched by Ironic itself, so we don't need to build one
+	if configDriveURL := d.Get("config_drive_url").(string); configDriveURL != "" {
+		var configDrive interface{} = configDriveURL
+		return diag.FromErr(deployNode(deployCtx, d, api, nodeUUID, &configDrive, deploySteps))
+	}
+
 	userData := d.Get("user_data").(string)
 	userDataURL := d.Get("user_data_url").(string)
 	userDataCaCert := d.Get("user_data_url_ca_cert").(string)
@@ -160,7 +286,7 @@ func resourceDeploymentCreate(d *schema.ResourceData, meta interface{}) error {
 	// if user_data_url is specified in addition to user_data, use the former
 	ignitionData, err := fetchFullIgnition(userDataURL, userDataCaCert, userDataHeaders)
 	if err != nil {
-		return fmt.Errorf("could not fetch data from user_data_url: %s", err)
+		return diag.Errorf("could not fetch data from user_data_url: %s", err)
 	}
 	if ignitionData != "" {
 		userData = ignitionData
@@ -171,11 +297,48 @@ func resourceDeploymentCreate(d *schema.ResourceData, meta interface{}) error {
 		d.Get("network_data").(map[string]interface{}),
 		d.Get("metadata").(map[string]interface{}))
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
-	// Deploy the node - drive Ironic state machine until node is 'active'
-	return ChangeProvisionStateToTarget(client, nodeUUID, "active", &configDrive, deploySteps, nil)
+	return diag.FromErr(deployNode(deployCtx, d, api, nodeUUID, &configDrive, deploySteps))
+}
+
+// missingTraits returns the requested traits that the node doesn't have.
+func missingTraits(requested, nodeTraits []string) []string {
+	var missing []string
+	for _, trait := range requested {
+		found := false
+		for _, nodeTrait := range nodeTraits {
+			if trait == nodeTrait {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, trait)
+		}
+	}
+	return missing
+}
+
+// Deploy the node - drive Ironic state machine until node is 'active', recording the last deploy step we saw
+func deployNode(ctx context.Context, d *schema.ResourceData, api IronicClient, nodeUUID string, configDrive interface{}, deploySteps []nodes.DeployStep) error {
+	wf := provisionStateWorkflow{
+		target:        nodes.TargetActive,
+		ctx:           ctx,
+		api:           api,
+		wait:          provisionStatePollInterval,
+		uuid:          nodeUUID,
+		configDrive:   configDrive,
+		deploySteps:   deploySteps,
+		deployRetries: d.Get("deploy_retries").(int),
+	}
+
+	err := wf.run()
+	if wf.lastStep != "" {
+		_ = d.Set("last_step", wf.lastStep)
+	}
+	return err
 }
 
 // fetchFullIgnition gets full igntion from the URL and cert passed to it and returns userdata as a string
@@ -228,6 +391,142 @@ func fetchFullIgnition(userDataURL string, userDataCaCert string, userDataHeader
 	return "", nil
 }
 
+// Check the image in instance_info is complete before we start deploying
+func resourceDeploymentCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	if !d.NewValueKnown("instance_info") || !d.NewValueKnown("image_type") {
+		return nil
+	}
+
+	instanceInfo := d.Get("instance_info").(map[string]interface{})
+	if err := validateImageType(d.Get("image_type").(string), instanceInfo); err != nil {
+		return err
+	}
+	if err := validateImageChecksum(d.Get("image_type").(string), instanceInfo); err != nil {
+		return err
+	}
+
+	if !d.NewValueKnown("deploy_capabilities") || !d.NewValueKnown("disk_label") {
+		return nil
+	}
+	deployCapabilities := d.Get("deploy_capabilities").(map[string]interface{})
+	diskLabel := d.Get("disk_label").(string)
+	if value, ok := deployCapabilities["disk_label"]; ok && diskLabel != "" && value != diskLabel {
+		return fmt.Errorf("disk_label conflicts with deploy_capabilities.disk_label, only set one of them")
+	}
+	capabilities := make(map[string]interface{})
+	if configured, _ := instanceInfo["capabilities"].(string); configured != "" {
+		var err error
+		if capabilities, err = parseCapabilities(configured); err != nil {
+			return err
+		}
+	}
+	for k, v := range requestedCapabilities(deployCapabilities, diskLabel) {
+		capabilities[k] = v
+	}
+	// The node's own boot mode is checked when deploying
+	return validateDiskLabel(capabilities, "")
+}
+
+// requestedCapabilities returns the deploy_capabilities, along with the disk_label if it's set.
+func requestedCapabilities(deployCapabilities map[string]interface{}, diskLabel string) map[string]interface{} {
+	requested := make(map[string]interface{})
+	for k, v := range deployCapabilities {
+		requested[k] = v
+	}
+	if diskLabel != "" {
+		requested["disk_label"] = diskLabel
+	}
+	return requested
+}
+
+// validateDiskLabel ensures an msdos disk label isn't requested for a node booting in UEFI mode, as a UEFI partition
+// image needs a gpt disk label to hold its EFI system partition. The boot mode requested for the deployment takes
+// precedence over the node's own.
+func validateDiskLabel(capabilities map[string]interface{}, nodeBootMode string) error {
+	bootMode := nodeBootMode
+	if requested, ok := capabilities["boot_mode"]; ok {
+		bootMode = fmt.Sprint(requested)
+	}
+	if diskLabel := fmt.Sprint(capabilities["disk_label"]); diskLabel == "msdos" && bootMode == "uefi" {
+		return fmt.Errorf("the 'msdos' disk_label can't be used with the 'uefi' boot mode, UEFI requires 'gpt'")
+	}
+	return nil
+}
+
+// partitionImageKeys are the instance_info keys which together make up a partition image.
+var partitionImageKeys = []string{"image_source", "kernel", "ramdisk"}
+
+// validateImageType ensures the instance info is complete for the image type. Partition images need a kernel and
+// ramdisk to boot, without them the deployment fails part way through. Setting a kernel or ramdisk also implies a
+// partition image, when image_type isn't set.
+func validateImageType(imageType string, instanceInfo map[string]interface{}) error {
+	reason := "when image_type is 'partition'"
+	if imageType == "" {
+		for _, key := range []string{"kernel", "ramdisk"} {
+			if v, ok := instanceInfo[key]; ok && v != "" {
+				imageType = "partition"
+				reason = fmt.Sprintf("for a partition image, as instance_info.%s is set", key)
+				break
+			}
+		}
+	}
+	if imageType != "partition" {
+		return nil
+	}
+
+	for _, key := range partitionImageKeys {
+		if v, ok := instanceInfo[key]; !ok || v == "" {
+			return fmt.Errorf("instance_info.%s is required %s", key, reason)
+		}
+	}
+
+	return nil
+}
+
+// imageOSHashAlgos are the hash algorithms Ironic accepts in image_os_hash_algo.
+var imageOSHashAlgos = []string{"md5", "sha256", "sha512"}
+
+// validateImageChecksum ensures an image downloaded over HTTP comes with the checksum Ironic needs to verify it,
+// either image_checksum or both of image_os_hash_algo and image_os_hash_value. Whole-disk images must use exactly one
+// of them.
+func validateImageChecksum(imageType string, instanceInfo map[string]interface{}) error {
+	present := func(key string) bool {
+		v, ok := instanceInfo[key]
+		return ok && v != ""
+	}
+	if algo, ok := instanceInfo["image_os_hash_algo"].(string); ok && algo != "" {
+		supported := false
+		for _, a := range imageOSHashAlgos {
+			if algo == a {
+				supported = true
+			}
+		}
+		if !supported {
+			return fmt.Errorf("instance_info.image_os_hash_algo must be one of %s, got '%s'", strings.Join(imageOSHashAlgos, ", "), algo)
+		}
+	}
+
+	imageSource, _ := instanceInfo["image_source"].(string)
+	if !strings.HasPrefix(imageSource, "http://") && !strings.HasPrefix(imageSource, "https://") {
+		return nil
+	}
+
+	algo, value := present("image_os_hash_algo"), present("image_os_hash_value")
+	wholeDisk := imageType == "whole-disk" || (imageType == "" && !present("kernel") && !present("ramdisk"))
+	switch {
+	case wholeDisk && algo && present("image_checksum"):
+		return fmt.Errorf("only one of instance_info.image_checksum, or image_os_hash_algo and image_os_hash_value, may be set for a whole-disk image")
+	case algo && !value:
+		return fmt.Errorf("instance_info.image_os_hash_value is required when instance_info.image_os_hash_algo is set")
+	case value && !algo:
+		return fmt.Errorf("instance_info.image_os_hash_algo is required when instance_info.image_os_hash_value is set")
+	case !algo && !present("image_checksum"):
+		return fmt.Errorf("instance_info.image_checksum, or image_os_hash_algo and image_os_hash_value, is required for image_source %s", imageSource)
+	}
+
+	return nil
+}
+
 // buildDeploySteps handles customized deploy steps
 func buildDeploySteps(steps string) ([]nodes.DeployStep, error) {
 	var deploySteps []nodes.DeployStep
@@ -273,33 +572,210 @@ func buildConfigDrive(apiVersion, userData string, networkData, metaData map[str
 	}, nil
 }
 
+// resourceDeploymentUpdate only changes fields that are used while deploying, so there's nothing to do in Ironic.
+func resourceDeploymentUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return resourceDeploymentRead(ctx, d, meta)
+}
+
 // Read the deployment's data from Ironic
-func resourceDeploymentRead(d *schema.ResourceData, meta interface{}) error {
-	client, err := meta.(*Clients).GetIronicClient()
+func resourceDeploymentRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	// Ensure node exists first
 	id := d.Get("node_uuid").(string)
-	result, err := nodes.Get(client, id).Extract()
-	if err != nil {
-		return fmt.Errorf("could not find node %s: %s", id, err)
+	var result nodes.Node
+	if err := api.GetNode(id, &result); err != nil {
+		return diag.Errorf("could not find node %s: %s", id, err)
+	}
+
+	// Capabilities merged in from deploy_capabilities aren't part of the configured instance_info
+	if capabilities, ok := result.InstanceInfo["capabilities"].(map[string]interface{}); ok {
+		configured, _ := d.Get("instance_info").(map[string]interface{})["capabilities"].(string)
+		requested := requestedCapabilities(d.Get("deploy_capabilities").(map[string]interface{}), d.Get("disk_label").(string))
+		result.InstanceInfo["capabilities"] = withoutDeployCapabilities(capabilities, configured, requested)
 	}
 
+	// Only read back the instance_info keys in the config, Ironic adds its own keys during deployment
+	err = d.Set("instance_info", managedInstanceInfo(d.Get("instance_info").(map[string]interface{}), result.InstanceInfo))
+	if err != nil {
+		return diag.FromErr(err)
+	}
 	err = d.Set("provision_state", result.ProvisionState)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
+	}
+	err = d.Set("instance_uuid", result.InstanceUUID)
+	if err != nil {
+		return diag.FromErr(err)
 	}
-	return d.Set("last_error", result.LastError)
+	return diag.FromErr(d.Set("last_error", result.LastError))
+}
+
+// managedInstanceInfo returns the subset of the node's instance_info that is managed by the config, so keys that
+// Ironic injects while deploying (e.g. image_url, deploy_boot_mode, configdrive) or masks don't cause a diff.
+func managedInstanceInfo(managed, actual map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+
+	for k, v := range managed {
+		value, ok := actual[k]
+		if !ok {
+			continue
+		}
+
+		// Ironic masks secrets unless the show_instance_secrets policy allows them, keep what's configured
+		if value == "******" {
+			result[k] = v
+			continue
+		}
+
+		// Capabilities are sent to Ironic as a map, keep the configured string if the contents are the same
+		if capabilities, ok := value.(map[string]interface{}); ok && k == "capabilities" {
+			if configured, err := parseCapabilities(v.(string)); err == nil && reflect.DeepEqual(configured, capabilities) {
+				result[k] = v
+			} else {
+				result[k] = formatCapabilities(capabilities)
+			}
+			continue
+		}
+
+		if str, ok := value.(string); ok {
+			result[k] = str
+		} else {
+			result[k] = fmt.Sprint(value)
+		}
+	}
+
+	return result
+}
+
+// withoutDeployCapabilities returns the capabilities configured in instance_info if the node's capabilities are the
+// result of merging deploy_capabilities into them, otherwise the node's capabilities are returned so drift is reported.
+func withoutDeployCapabilities(actual map[string]interface{}, configured string, deployCapabilities map[string]interface{}) map[string]interface{} {
+	expected := make(map[string]interface{})
+	if configured != "" {
+		var err error
+		if expected, err = parseCapabilities(configured); err != nil {
+			return actual
+		}
+	}
+
+	merged := make(map[string]interface{})
+	for k, v := range expected {
+		merged[k] = v
+	}
+	for k, v := range deployCapabilities {
+		merged[k] = v
+	}
+	if !reflect.DeepEqual(merged, actual) {
+		return actual
+	}
+	return expected
+}
+
+// deployCapabilityValues are the values Ironic accepts for each capability requested at deploy time.
+var deployCapabilityValues = map[string][]string{
+	"boot_mode":    {"bios", "uefi"},
+	"boot_option":  {"local", "netboot", "ramdisk", "kickstart"},
+	"disk_label":   {"msdos", "gpt"},
+	"secure_boot":  {"true", "false"},
+	"trusted_boot": {"true", "false"},
+}
+
+// validateDeployCapabilities ensures each of the deploy_capabilities is one Ironic knows, with a value it accepts.
+func validateDeployCapabilities(i interface{}, k string) ([]string, []error) {
+	known := make([]string, 0, len(deployCapabilityValues))
+	for capability := range deployCapabilityValues {
+		known = append(known, capability)
+	}
+	sort.Strings(known)
+
+	var errs []error
+	for capability, value := range i.(map[string]interface{}) {
+		values, ok := deployCapabilityValues[capability]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown capability '%s', expected one of %s", k, capability, strings.Join(known, ", ")))
+			continue
+		}
+		valid := false
+		for _, v := range values {
+			if fmt.Sprint(value) == v {
+				valid = true
+			}
+		}
+		if !valid {
+			errs = append(errs, fmt.Errorf("%s: capability %s must be one of %s, got '%v'", k, capability, strings.Join(values, ", "), value))
+		}
+	}
+	return nil, errs
+}
+
+// parseCapabilities parses a capabilities string, e.g. "boot_option:local,secure_boot:true" into a map.
+func parseCapabilities(capabilities string) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for _, e := range strings.Split(capabilities, ",") {
+		parts := strings.Split(e, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("error while parsing capabilities: %s, the correct format is key:value", e)
+		}
+		result[parts[0]] = parts[1]
+	}
+	return result, nil
+}
+
+// formatCapabilities formats a map of capabilities as a string, sorted by key.
+func formatCapabilities(capabilities map[string]interface{}) string {
+	keys := make([]string, 0, len(capabilities))
+	for k := range capabilities {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s:%v", k, capabilities[k]))
+	}
+	return strings.Join(parts, ",")
 }
 
 // Delete an deployment from Ironic - this cleans the node and returns it's state to 'available'
-func resourceDeploymentDelete(d *schema.ResourceData, meta interface{}) error {
-	client, err := meta.(*Clients).GetIronicClient()
+func resourceDeploymentDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
-	return ChangeProvisionStateToTarget(client, d.Id(), "deleted", nil, nil, nil)
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout(schema.TimeoutDelete))
+	defer cancel()
+
+	if err := ChangeProvisionStateToTarget(ctx, api, d.Id(), "deleted", nil, nil, nil); err != nil {
+		return diag.FromErr(err)
+	}
+
+	return diag.FromErr(clearInstanceUUID(ctx, api, d.Id()))
+}
+
+// clearInstanceUUID disassociates an undeployed node from its instance, if Ironic didn't already. An instance_uuid
+// belonging to an allocation is left alone, it's cleared when the allocation is deleted.
+func clearInstanceUUID(ctx context.Context, api IronicClient, uuid string) error {
+	node, err := getExtendedNode(api, uuid)
+	if err != nil {
+		return fmt.Errorf("could not get node %s: %s", uuid, err)
+	}
+	if node.InstanceUUID == "" || node.AllocationUUID != "" {
+		return nil
+	}
+
+	log.Printf("[DEBUG] Node %s is still associated with instance %s after undeploying, clearing it", uuid, node.InstanceUUID)
+	if _, err := UpdateNode(ctx, api, uuid, nodes.UpdateOpts{
+		nodes.UpdateOperation{
+			Op:   nodes.RemoveOp,
+			Path: "/instance_uuid",
+		},
+	}); err != nil {
+		return fmt.Errorf("could not clear instance_uuid of node %s: %s", uuid, err)
+	}
+	return nil
 }
@@ -3,6 +3,7 @@
 package ironic
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/pem"
 	"fmt"
@@ -13,8 +14,9 @@ import (
 
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
-	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
 )
 
@@ -58,7 +60,7 @@ func TestBuildConfigDrive(t *testing.T) {
 }
 
 func testAccDeploymentDestroy(state *terraform.State) error {
-	client, err := testAccProvider.Meta().(*Clients).GetIronicClient()
+	client, err := testAccProvider.Meta().(*Clients).GetIronicClient(context.Background())
 	if err != nil {
 		return err
 	}
@@ -218,3 +220,433 @@ func TestBuildDeploySteps(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateImageType(t *testing.T) {
+	testCases := []struct {
+		Scenario      string
+		ImageType     string
+		InstanceInfo  map[string]interface{}
+		ExpectedError string
+	}{
+		{
+			Scenario:     "whole disk image without kernel",
+			ImageType:    "whole-disk",
+			InstanceInfo: map[string]interface{}{"image_source": "http://172.22.0.1/images/image.qcow2"},
+		},
+		{
+			Scenario:  "partition image with kernel and ramdisk",
+			ImageType: "partition",
+			InstanceInfo: map[string]interface{}{
+				"image_source": "http://172.22.0.1/images/image.qcow2",
+				"kernel":       "http://172.22.0.1/images/image.kernel",
+				"ramdisk":      "http://172.22.0.1/images/image.initramfs",
+			},
+		},
+		{
+			Scenario:  "partition image without ramdisk",
+			ImageType: "partition",
+			InstanceInfo: map[string]interface{}{
+				"image_source": "http://172.22.0.1/images/image.qcow2",
+				"kernel":       "http://172.22.0.1/images/image.kernel",
+			},
+			ExpectedError: "instance_info.ramdisk is required",
+		},
+		{
+			Scenario: "kernel implies a partition image",
+			InstanceInfo: map[string]interface{}{
+				"kernel":  "http://172.22.0.1/images/image.kernel",
+				"ramdisk": "http://172.22.0.1/images/image.initramfs",
+			},
+			ExpectedError: "instance_info.image_source is required for a partition image, as instance_info.kernel is set",
+		},
+	}
+	for _, tc := range testCases {
+		err := validateImageType(tc.ImageType, tc.InstanceInfo)
+		if tc.ExpectedError == "" {
+			th.AssertNoError(t, err)
+		} else {
+			th.AssertError(t, err, tc.ExpectedError)
+		}
+	}
+}
+
+func TestValidateDeployCapabilities(t *testing.T) {
+	testCases := []struct {
+		Scenario      string
+		Capabilities  map[string]interface{}
+		ExpectedError string
+	}{
+		{
+			Scenario:     "known capabilities",
+			Capabilities: map[string]interface{}{"disk_label": "gpt", "boot_option": "local", "trusted_boot": "false"},
+		},
+		{
+			Scenario:      "unknown capability",
+			Capabilities:  map[string]interface{}{"disk_lable": "gpt"},
+			ExpectedError: "unknown capability 'disk_lable', expected one of boot_mode, boot_option, disk_label, secure_boot, trusted_boot",
+		},
+		{
+			Scenario:      "invalid value",
+			Capabilities:  map[string]interface{}{"disk_label": "mbr"},
+			ExpectedError: "capability disk_label must be one of msdos, gpt, got 'mbr'",
+		},
+	}
+	for _, tc := range testCases {
+		_, errs := validateDeployCapabilities(tc.Capabilities, "deploy_capabilities")
+		if tc.ExpectedError == "" {
+			if len(errs) > 0 {
+				t.Errorf("%s: expected no errors, got %v", tc.Scenario, errs)
+			}
+		} else {
+			if len(errs) != 1 {
+				t.Fatalf("%s: expected one error, got %v", tc.Scenario, errs)
+			}
+			th.AssertError(t, errs[0], tc.ExpectedError)
+		}
+	}
+}
+
+func TestWithoutDeployCapabilities(t *testing.T) {
+	deployCapabilities := map[string]interface{}{"disk_label": "gpt", "boot_option": "local"}
+	testCases := []struct {
+		Scenario   string
+		Actual     map[string]interface{}
+		Configured string
+		Expected   map[string]interface{}
+	}{
+		{
+			Scenario:   "merged",
+			Actual:     map[string]interface{}{"disk_label": "gpt", "boot_option": "local", "secure_boot": "true"},
+			Configured: "secure_boot:true,boot_option:netboot",
+			Expected:   map[string]interface{}{"secure_boot": "true", "boot_option": "netboot"},
+		},
+		{
+			Scenario: "only deploy_capabilities",
+			Actual:   map[string]interface{}{"disk_label": "gpt", "boot_option": "local"},
+			Expected: map[string]interface{}{},
+		},
+		{
+			Scenario:   "drifted",
+			Actual:     map[string]interface{}{"disk_label": "msdos", "boot_option": "local", "secure_boot": "true"},
+			Configured: "secure_boot:true",
+			Expected:   map[string]interface{}{"disk_label": "msdos", "boot_option": "local", "secure_boot": "true"},
+		},
+	}
+	for _, tc := range testCases {
+		result := withoutDeployCapabilities(tc.Actual, tc.Configured, deployCapabilities)
+		if !reflect.DeepEqual(tc.Expected, result) {
+			t.Errorf("%s: expected: %v, got: %v", tc.Scenario, tc.Expected, result)
+		}
+	}
+}
+
+func TestValidateImageChecksum(t *testing.T) {
+	testCases := []struct {
+		Scenario      string
+		ImageType     string
+		InstanceInfo  map[string]interface{}
+		ExpectedError string
+	}{
+		{
+			Scenario:     "glance image",
+			InstanceInfo: map[string]interface{}{"image_source": "0f9c5ebb-6e4c-4c2f-9b8c-2fa2ab6b4d58"},
+		},
+		{
+			Scenario: "http image with checksum",
+			InstanceInfo: map[string]interface{}{
+				"image_source":   "http://172.22.0.1/images/image.qcow2",
+				"image_checksum": "26c53f3beca4e0b02e09d335257826fd",
+			},
+		},
+		{
+			Scenario: "http image with os hash",
+			InstanceInfo: map[string]interface{}{
+				"image_source":        "https://172.22.0.1/images/image.qcow2",
+				"image_os_hash_algo":  "sha256",
+				"image_os_hash_value": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			},
+		},
+		{
+			Scenario: "http image with os hash algo only",
+			InstanceInfo: map[string]interface{}{
+				"image_source":       "http://172.22.0.1/images/image.qcow2",
+				"image_os_hash_algo": "sha256",
+			},
+			ExpectedError: "instance_info.image_os_hash_value is required",
+		},
+		{
+			Scenario:      "http image without checksum",
+			InstanceInfo:  map[string]interface{}{"image_source": "http://172.22.0.1/images/image.qcow2"},
+			ExpectedError: "instance_info.image_checksum, or image_os_hash_algo and image_os_hash_value, is required",
+		},
+		{
+			Scenario: "whole disk image with both checksums",
+			InstanceInfo: map[string]interface{}{
+				"image_source":        "http://172.22.0.1/images/image.qcow2",
+				"image_checksum":      "26c53f3beca4e0b02e09d335257826fd",
+				"image_os_hash_algo":  "sha256",
+				"image_os_hash_value": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			},
+			ExpectedError: "only one of instance_info.image_checksum, or image_os_hash_algo and image_os_hash_value, may be set for a whole-disk image",
+		},
+		{
+			Scenario:  "partition image with both checksums",
+			ImageType: "partition",
+			InstanceInfo: map[string]interface{}{
+				"image_source":        "http://172.22.0.1/images/image.qcow2",
+				"kernel":              "http://172.22.0.1/images/image.kernel",
+				"ramdisk":             "http://172.22.0.1/images/image.initramfs",
+				"image_checksum":      "26c53f3beca4e0b02e09d335257826fd",
+				"image_os_hash_algo":  "sha256",
+				"image_os_hash_value": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			},
+		},
+		{
+			Scenario: "unsupported hash algorithm",
+			InstanceInfo: map[string]interface{}{
+				"image_source":        "http://172.22.0.1/images/image.qcow2",
+				"image_os_hash_algo":  "sha1",
+				"image_os_hash_value": "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+			},
+			ExpectedError: "instance_info.image_os_hash_algo must be one of md5, sha256, sha512, got 'sha1'",
+		},
+	}
+	for _, tc := range testCases {
+		err := validateImageChecksum(tc.ImageType, tc.InstanceInfo)
+		if tc.ExpectedError == "" {
+			th.AssertNoError(t, err)
+		} else {
+			th.AssertError(t, err, tc.ExpectedError)
+		}
+	}
+}
+
+func TestMissingTraits(t *testing.T) {
+	testCases := []struct {
+		Scenario   string
+		Requested  []string
+		NodeTraits []string
+		Expected   []string
+	}{
+		{
+			Scenario:   "all present",
+			Requested:  []string{"CUSTOM_GPU"},
+			NodeTraits: []string{"CUSTOM_GPU", "HW_CPU_X86_VMX"},
+		},
+		{
+			Scenario:   "one missing",
+			Requested:  []string{"CUSTOM_GPU", "CUSTOM_FPGA"},
+			NodeTraits: []string{"CUSTOM_GPU"},
+			Expected:   []string{"CUSTOM_FPGA"},
+		},
+		{
+			Scenario:  "node without traits",
+			Requested: []string{"CUSTOM_GPU"},
+			Expected:  []string{"CUSTOM_GPU"},
+		},
+	}
+	for _, tc := range testCases {
+		if actual := missingTraits(tc.Requested, tc.NodeTraits); !reflect.DeepEqual(actual, tc.Expected) {
+			t.Errorf("%s: expected %v, got %v", tc.Scenario, tc.Expected, actual)
+		}
+	}
+}
+
+func TestDeploymentUndeployClearsInstanceUUID(t *testing.T) {
+	cases := []struct {
+		Scenario             string
+		AllocationUUID       string
+		ExpectedInstanceUUID string
+	}{
+		{
+			Scenario: "deployed directly",
+		},
+		{
+			Scenario:             "deployed through an allocation",
+			AllocationUUID:       "allocation-0",
+			ExpectedInstanceUUID: "instance-0",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			// Ironic has finished undeploying, but left the node associated with its instance
+			api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+				"node-0": {
+					"uuid":            "node-0",
+					"provision_state": "available",
+					"instance_uuid":   "instance-0",
+					"allocation_uuid": c.AllocationUUID,
+				},
+			}}
+			meta := &Clients{ironicAPI: api}
+
+			d := schema.TestResourceDataRaw(t, resourceDeployment().Schema, map[string]interface{}{
+				"node_uuid":     "node-0",
+				"instance_info": map[string]interface{}{"image_source": "http://172.22.0.1/images/image.qcow2"},
+			})
+			d.SetId("node-0")
+			th.AssertNoDiagErrors(t, resourceDeploymentRead(context.Background(), d, meta))
+			if instanceUUID := d.Get("instance_uuid").(string); instanceUUID != "instance-0" {
+				t.Fatalf("expected instance_uuid 'instance-0' while deployed, got '%s'", instanceUUID)
+			}
+
+			th.AssertNoDiagErrors(t, resourceDeploymentDelete(context.Background(), d, meta))
+			th.AssertNoDiagErrors(t, resourceDeploymentRead(context.Background(), d, meta))
+			if instanceUUID := d.Get("instance_uuid").(string); instanceUUID != c.ExpectedInstanceUUID {
+				t.Errorf("expected instance_uuid '%s' after undeploying, got '%s'", c.ExpectedInstanceUUID, instanceUUID)
+			}
+		})
+	}
+}
+
+func TestDeployConfigDriveURL(t *testing.T) {
+	interval, deployInterval := provisionStatePollInterval, deployPollInterval
+	provisionStatePollInterval, deployPollInterval = 0, 0
+	defer func() { provisionStatePollInterval, deployPollInterval = interval, deployInterval }()
+
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+		"node-0": {"uuid": "node-0", "provision_state": "available"},
+	}}
+	meta := &Clients{ironicAPI: api}
+
+	configDriveURL := "https://172.22.0.1/configdrives/node-0.iso.gz?token=abc%20def"
+	d := schema.TestResourceDataRaw(t, resourceDeployment().Schema, map[string]interface{}{
+		"node_uuid":        "node-0",
+		"instance_info":    map[string]interface{}{"image_source": "http://172.22.0.1/images/image.qcow2"},
+		"config_drive_url": configDriveURL,
+	})
+	th.AssertNoDiagErrors(t, resourceDeploymentCreate(context.Background(), d, meta))
+
+	if !reflect.DeepEqual(api.targets, []nodes.TargetProvisionState{nodes.TargetActive}) {
+		t.Fatalf("expected the node to be deployed, got targets %v", api.targets)
+	}
+	if configDrive, ok := api.configDrives[0].(*interface{}); !ok || *configDrive != configDriveURL {
+		t.Errorf("expected the config drive to be the URL %q unchanged, got %#v", configDriveURL, api.configDrives[0])
+	}
+}
+
+func TestConfigDriveURLConflicts(t *testing.T) {
+	for _, field := range []string{"user_data", "user_data_url", "network_data", "metadata"} {
+		t.Run(field, func(t *testing.T) {
+			config := map[string]interface{}{
+				"node_uuid":        "node-0",
+				"instance_info":    map[string]interface{}{"image_source": "http://172.22.0.1/images/image.qcow2"},
+				"config_drive_url": "http://172.22.0.1/configdrives/node-0.iso.gz",
+			}
+			switch field {
+			case "user_data":
+				config[field] = "#cloud-config"
+			case "user_data_url":
+				config[field] = "http://172.22.0.1/user_data"
+			default:
+				config[field] = map[string]interface{}{"key": "value"}
+			}
+
+			diags := resourceDeployment().Validate(terraform.NewResourceConfigRaw(config))
+			if len(diags) != 1 || diags[0].Detail != fmt.Sprintf("\"config_drive_url\": conflicts with %s", field) {
+				t.Errorf("expected config_drive_url to conflict with %s, got: %v", field, diags)
+			}
+		})
+	}
+}
+
+func TestManagedInstanceInfo(t *testing.T) {
+	managed := map[string]interface{}{
+		"image_source":   "http://172.22.0.1/images/image.qcow2",
+		"image_checksum": "26c53f3beca4e0b02e09d335257826fd",
+		"root_gb":        "25",
+		"capabilities":   "secure_boot:true,boot_option:local",
+	}
+	actual := map[string]interface{}{
+		"image_source":     "http://172.22.0.1/images/image.qcow2",
+		"image_checksum":   "26c53f3beca4e0b02e09d335257826fd",
+		"image_url":        "http://172.22.0.1/images/image.qcow2",
+		"deploy_boot_mode": "uefi",
+		"root_gb":          25,
+		"capabilities": map[string]interface{}{
+			"boot_option": "local",
+			"secure_boot": "true",
+		},
+	}
+
+	result := managedInstanceInfo(managed, actual)
+	if !reflect.DeepEqual(managed, result) {
+		t.Errorf("expected: %v, got: %v", managed, result)
+	}
+
+	actual["capabilities"] = map[string]interface{}{"boot_option": "netboot"}
+	result = managedInstanceInfo(managed, actual)
+	if result["capabilities"] != "boot_option:netboot" {
+		t.Errorf("expected capabilities drift to be detected, got: %v", result["capabilities"])
+	}
+
+	managed["configdrive"] = "{\"user_data\": \"secret\"}"
+	actual["configdrive"] = "******"
+	result = managedInstanceInfo(managed, actual)
+	if result["configdrive"] != managed["configdrive"] {
+		t.Errorf("expected the masked configdrive to be kept, got: %v", result["configdrive"])
+	}
+}
+
+func TestInstanceInfoSensitive(t *testing.T) {
+	instanceInfo := resourceDeployment().Schema["instance_info"]
+	if !instanceInfo.Sensitive {
+		t.Errorf("expected instance_info to be sensitive")
+	}
+	if !instanceInfo.DiffSuppressFunc("instance_info.configdrive", "******", "{}", nil) {
+		t.Errorf("expected a diff against a masked value to be suppressed")
+	}
+	if instanceInfo.DiffSuppressFunc("instance_info.root_gb", "25", "30", nil) {
+		t.Errorf("expected a diff against an unmasked value to be kept")
+	}
+}
+
+func TestValidateDiskLabel(t *testing.T) {
+	cases := []struct {
+		Scenario      string
+		Capabilities  map[string]interface{}
+		NodeBootMode  string
+		ExpectedError string
+	}{
+		{
+			Scenario:     "gpt with uefi",
+			Capabilities: map[string]interface{}{"disk_label": "gpt", "boot_mode": "uefi"},
+		},
+		{
+			Scenario:     "msdos with bios",
+			Capabilities: map[string]interface{}{"disk_label": "msdos", "boot_mode": "bios"},
+		},
+		{
+			Scenario:      "msdos with uefi",
+			Capabilities:  requestedCapabilities(map[string]interface{}{"boot_mode": "uefi"}, "msdos"),
+			ExpectedError: "the 'msdos' disk_label can't be used with the 'uefi' boot mode, UEFI requires 'gpt'",
+		},
+		{
+			Scenario:      "msdos on a uefi node",
+			Capabilities:  map[string]interface{}{"disk_label": "msdos"},
+			NodeBootMode:  "uefi",
+			ExpectedError: "the 'msdos' disk_label can't be used with the 'uefi' boot mode",
+		},
+		{
+			Scenario:     "bios requested on a uefi node",
+			Capabilities: map[string]interface{}{"disk_label": "msdos", "boot_mode": "bios"},
+			NodeBootMode: "uefi",
+		},
+		{
+			Scenario:     "no disk label",
+			NodeBootMode: "uefi",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			err := validateDiskLabel(c.Capabilities, c.NodeBootMode)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+		})
+	}
+}
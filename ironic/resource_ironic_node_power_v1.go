@@ -0,0 +1,144 @@
+package ironic
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// Schema resource definition for the power state of an existing node. It only manages power, so a node registered
+// elsewhere, e.g. by another ironic_node_v1 or outside of Terraform, can be powered on and off on its own.
+func resourceNodePowerV1() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceNodePowerV1Create,
+		ReadContext:   resourceNodePowerV1Read,
+		UpdateContext: resourceNodePowerV1Update,
+		DeleteContext: resourceNodePowerV1Delete,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceNodePowerV1Import,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"node_uuid": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"target_power_state": {
+				Type:     schema.TypeString,
+				Required: true,
+				ValidateFunc: validation.StringInSlice([]string{
+					string(nodes.PowerOn), string(nodes.PowerOff), string(nodes.Rebooting),
+					string(nodes.SoftPowerOff), string(nodes.SoftRebooting),
+				}, false),
+			},
+			// Only used when changing power state, like the node's power_state_timeout
+			"power_state_timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"power_state": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceNodePowerV1Create(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	d.SetId(d.Get("node_uuid").(string))
+	if err := changePowerState(ctx, api, d, nodes.TargetPowerState(d.Get("target_power_state").(string))); err != nil {
+		d.SetId("")
+		return diag.Errorf("could not change power state: %s", err)
+	}
+
+	return resourceNodePowerV1Read(ctx, d, meta)
+}
+
+func resourceNodePowerV1Read(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	var node nodes.Node
+	if err := api.GetNode(d.Id(), &node); err != nil {
+		if _, ok := err.(gophercloud.ErrDefault404); ok {
+			d.SetId("")
+			return nil
+		}
+		return diag.FromErr(err)
+	}
+
+	powerState := normalizePowerState(node.PowerState)
+	err = d.Set("power_state", powerState)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// A node that was powered on or off outside of Terraform drifts from its target. Rebooting leaves the node on,
+	// so that's as expected.
+	target := nodes.TargetPowerState(d.Get("target_power_state").(string))
+	if normalizePowerState(node.TargetPowerState) == "" && powerState != expectedPowerState(target) {
+		return diag.FromErr(d.Set("target_power_state", powerState))
+	}
+	return nil
+}
+
+func resourceNodePowerV1Update(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if d.HasChange("target_power_state") {
+		if err := changePowerState(ctx, api, d, nodes.TargetPowerState(d.Get("target_power_state").(string))); err != nil {
+			return diag.Errorf("could not change power state: %s", err)
+		}
+	}
+
+	return resourceNodePowerV1Read(ctx, d, meta)
+}
+
+// resourceNodePowerV1Delete leaves the node in whatever power state it's in, only Terraform stops managing it.
+func resourceNodePowerV1Delete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	d.SetId("")
+	return nil
+}
+
+// resourceNodePowerV1Import imports the power state of a node by its UUID, with its current power state as the target.
+func resourceNodePowerV1Import(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var node nodes.Node
+	if err := api.GetNode(d.Id(), &node); err != nil {
+		return nil, fmt.Errorf("could not get node %s: %s", d.Id(), err)
+	}
+	d.SetId(node.UUID)
+
+	err = d.Set("node_uuid", node.UUID)
+	if err != nil {
+		return nil, err
+	}
+	err = d.Set("target_power_state", normalizePowerState(node.PowerState))
+	if err != nil {
+		return nil, err
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
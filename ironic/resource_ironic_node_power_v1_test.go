@@ -0,0 +1,74 @@
+// +build acceptance
+
+package ironic
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
+)
+
+func TestNodePowerV1(t *testing.T) {
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+		"node-0": {"uuid": "node-0", "power_state": "power off"},
+	}}
+	meta := &Clients{ironicAPI: api}
+
+	d := schema.TestResourceDataRaw(t, resourceNodePowerV1().Schema, map[string]interface{}{
+		"node_uuid":          "node-0",
+		"target_power_state": "rebooting",
+	})
+	th.AssertNoDiagErrors(t, resourceNodePowerV1Create(context.Background(), d, meta))
+	if d.Id() != "node-0" {
+		t.Errorf("expected ID 'node-0', got '%s'", d.Id())
+	}
+	if state := d.Get("power_state"); state != "power on" {
+		t.Errorf("expected the node to be 'power on', got '%s'", state)
+	}
+	if target := d.Get("target_power_state"); target != "rebooting" {
+		t.Errorf("expected a rebooted node not to drift, got target '%s'", target)
+	}
+
+	// Powered off outside of Terraform
+	api.nodes["node-0"]["power_state"] = "POWER_OFF"
+	th.AssertNoDiagErrors(t, resourceNodePowerV1Read(context.Background(), d, meta))
+	if target := d.Get("target_power_state"); target != "power off" {
+		t.Errorf("expected target_power_state to drift to 'power off', got '%s'", target)
+	}
+
+	expected := []nodes.TargetPowerState{nodes.Rebooting}
+	if !reflect.DeepEqual(expected, api.powerTargets) {
+		t.Errorf("expected power targets %v, got %v", expected, api.powerTargets)
+	}
+
+	// Removed from Ironic
+	delete(api.nodes, "node-0")
+	th.AssertNoDiagErrors(t, resourceNodePowerV1Read(context.Background(), d, meta))
+	if d.Id() != "" {
+		t.Errorf("expected the ID to be cleared, got '%s'", d.Id())
+	}
+}
+
+func TestNodePowerV1Import(t *testing.T) {
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+		"node-0": {"uuid": "node-0", "power_state": "power on"},
+	}}
+
+	d := schema.TestResourceDataRaw(t, resourceNodePowerV1().Schema, map[string]interface{}{})
+	d.SetId("node-0")
+	result, err := resourceNodePowerV1Import(context.Background(), d, &Clients{ironicAPI: api})
+	th.AssertNoError(t, err)
+	if len(result) != 1 {
+		t.Fatalf("expected one resource, got %d", len(result))
+	}
+	if uuid := d.Get("node_uuid"); uuid != "node-0" {
+		t.Errorf("expected node_uuid 'node-0', got '%s'", uuid)
+	}
+	if target := d.Get("target_power_state"); target != "power on" {
+		t.Errorf("expected target_power_state 'power on', got '%s'", target)
+	}
+}
@@ -1,32 +1,82 @@
 package ironic
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/drivers"
 	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
 	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/ports"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/structure"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic"
 )
 
+// nodeNamePattern matches the names Ironic accepts for a node, which must be usable as a hostname.
+var nodeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9\-._~]{1,255}$`)
+
 // Schema resource definition for an Ironic node.
 func resourceNodeV1() *schema.Resource {
 	return &schema.Resource{
-		Create: resourceNodeV1Create,
-		Read:   resourceNodeV1Read,
-		Update: resourceNodeV1Update,
-		Delete: resourceNodeV1Delete,
+		CreateContext: resourceNodeV1Create,
+		ReadContext:   resourceNodeV1Read,
+		UpdateContext: resourceNodeV1Update,
+		DeleteContext: resourceNodeV1Delete,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceNodeV1Import,
+		},
+
+		// Provision state changes, e.g. cleaning and inspection, are bounded by these
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(60 * time.Minute),
+			Update: schema.DefaultTimeout(60 * time.Minute),
+			Delete: schema.DefaultTimeout(60 * time.Minute),
+		},
+
+		CustomizeDiff: resourceNodeV1CustomizeDiff,
 
 		Schema: map[string]*schema.Schema{
 			"name": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringMatch(nodeNamePattern, "must contain only letters, digits, '-', '.', '_' and '~'"),
+			},
+			"description": {
 				Type:     schema.TypeString,
 				Optional: true,
 			},
+			"traits": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringMatch(traitPattern, "must be a trait, e.g. CUSTOM_GPU"),
+				},
+			},
+			// A string rather than a bool, as Ironic's null inherits the conductor's setting, which a bool can't represent
+			"automated_clean": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice([]string{"true", "false", "default"}, false),
+				Description:  "Whether Ironic cleans the node automatically, or 'default' to use the conductor's setting",
+			},
 			"boot_interface": {
 				Type:     schema.TypeString,
 				Optional: true,
@@ -36,9 +86,41 @@ func resourceNodeV1() *schema.Resource {
 				Type:     schema.TypeBool,
 				Optional: true,
 			},
+			"clean_trigger": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			// Only used when cleaning, after the RAID and BIOS configuration
+			"clean_steps": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ValidateFunc:     validation.StringIsJSON,
+				DiffSuppressFunc: structure.SuppressJsonDiff,
+				Description:      "JSON list of extra manual clean steps, e.g. vendor steps of the management interface",
+			},
+			"reset_bmc": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Reset the BMC when cleaning, with the clean step of the node's vendor management interface",
+			},
+			"conductor": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The conductor currently managing the node",
+			},
 			"conductor_group": {
 				Type:     schema.TypeString,
 				Optional: true,
+				// Left unset, whichever group Ironic puts the node in by default is kept without a diff
+				Computed: true,
+				// Ironic stores conductor groups in lower case
+				DiffSuppressFunc: func(_, old, new string, _ *schema.ResourceData) bool {
+					return strings.EqualFold(old, new)
+				},
+			},
+			// Where to connect to the serial console, e.g. with shellinabox or socat, empty unless it's enabled
+			"console_url": {
+				Type:     schema.TypeString,
 				Computed: true,
 			},
 			"console_interface": {
@@ -51,6 +133,11 @@ func resourceNodeV1() *schema.Resource {
 				Optional: true,
 				Computed: true,
 			},
+			// Stored in driver_info, overriding Ironic's fast_track setting for the node
+			"fast_track": {
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
 			"driver": {
 				Type:     schema.TypeString,
 				Required: true,
@@ -71,6 +158,104 @@ func resourceNodeV1() *schema.Resource {
 				// driver_info could contain passwords
 				Sensitive: true,
 			},
+			"last_step": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"provision_milestone": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The last of manage, clean, inspect and available to finish, so an interrupted apply can resume",
+			},
+			"provision_updated_at": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"inspection_started_at": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"inspection_finished_at": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"driver_internal_info": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"provisioning_network": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsUUID,
+			},
+			"cleaning_network": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsUUID,
+			},
+			"rescuing_network": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsUUID,
+			},
+			"inspection_network": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsUUID,
+			},
+			"inspection_kernel": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"deploy_kernel"},
+			},
+			"inspection_ramdisk": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"deploy_ramdisk"},
+			},
+			"inspection_kernel_params": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Kernel parameters appended when booting the ramdisk, e.g. for inspection hooks",
+			},
+			"deploy_kernel": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"inspection_kernel"},
+			},
+			"deploy_ramdisk": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"inspection_ramdisk"},
+			},
+			"rescue_kernel": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"rescue_ramdisk": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"image_download_source": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{"swift", "http", "local"}, false),
+				Description:  "Where the ramdisk downloads the instance image from when deploying with the 'direct' deploy interface",
+			},
+			"image_http_proxy": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+			},
+			"image_https_proxy": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+			},
+			"image_no_proxy": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
 			"properties": {
 				Type:     schema.TypeMap,
 				Optional: true,
@@ -79,9 +264,40 @@ func resourceNodeV1() *schema.Resource {
 				Type:     schema.TypeMap,
 				Optional: true,
 			},
+			"secure_boot": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
+				Description: "Whether the node's secure_boot capability is set, it's kept out of the capabilities in properties",
+			},
+			"boot_mode": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"current_secure_boot": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether secure boot is currently on, as reported by the node's management interface if it can tell",
+			},
 			"extra": {
-				Type:     schema.TypeMap,
-				Optional: true,
+				Type:          schema.TypeMap,
+				Optional:      true,
+				ConflictsWith: []string{"extra_json"},
+			},
+			// Other tools, e.g. a CMDB, may write their own keys to extra too, merging leaves them alone
+			"extra_merge": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Default:       false,
+				ConflictsWith: []string{"extra_json"},
+				Description:   "Only manage the keys of extra in the config, rather than replacing all of extra",
+			},
+			"extra_json": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ConflictsWith:    []string{"extra", "extra_merge"},
+				ValidateFunc:     validation.StringIsJSON,
+				DiffSuppressFunc: structure.SuppressJsonDiff,
 			},
 			"inspect_interface": {
 				Type:     schema.TypeString,
@@ -92,10 +308,39 @@ func resourceNodeV1() *schema.Resource {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"allocation_uuid": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The allocation the node belongs to, if any",
+			},
 			"inspect": {
 				Type:     schema.TypeBool,
 				Optional: true,
 			},
+			"force_delete": {
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
+			"power_off_on_delete": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ValidateFunc: validation.StringInSlice([]string{
+					"soft", "hard",
+				}, false),
+			},
+			"optimistic_updates": {
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
+			"maintenance_during_update": {
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
+			"validate_interfaces": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Check the hardware interfaces are enabled for the driver when planning",
+			},
 			"available": {
 				Type:     schema.TypeBool,
 				Optional: true,
@@ -104,11 +349,31 @@ func resourceNodeV1() *schema.Resource {
 				Type:     schema.TypeBool,
 				Optional: true,
 			},
+			"maintenance": {
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
+			"drain": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "Undeploy and power off the node before putting it in maintenance",
+			},
+			"drain_reason": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "Drained by Terraform",
+			},
 			"management_interface": {
 				Type:     schema.TypeString,
 				Optional: true,
 				Computed: true,
 			},
+			"network_data": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ValidateFunc:     validation.StringIsJSON,
+				DiffSuppressFunc: structure.SuppressJsonDiff,
+			},
 			"network_interface": {
 				Type:     schema.TypeString,
 				Optional: true,
@@ -119,6 +384,10 @@ func resourceNodeV1() *schema.Resource {
 				Optional: true,
 				Computed: true,
 			},
+			"protected": {
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
 			"raid_interface": {
 				Type:     schema.TypeString,
 				Optional: true,
@@ -133,6 +402,14 @@ func resourceNodeV1() *schema.Resource {
 				Type:     schema.TypeString,
 				Optional: true,
 			},
+			"retired": {
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
+			"retired_reason": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
 			"storage_interface": {
 				Type:     schema.TypeString,
 				Optional: true,
@@ -148,6 +425,11 @@ func resourceNodeV1() *schema.Resource {
 				Optional: true,
 				Computed: true,
 			},
+			"lessee": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
 			"ports": {
 				Type:     schema.TypeSet,
 				Optional: true,
@@ -155,6 +437,40 @@ func resourceNodeV1() *schema.Resource {
 					Type: schema.TypeMap,
 				},
 			},
+			"port": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"uuid": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"address": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"pxe_enabled": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"local_link_connection": {
+							Type:     schema.TypeMap,
+							Computed: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+			"port_uuids": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
 			"provision_state": {
 				Type:     schema.TypeString,
 				Computed: true,
@@ -169,13 +485,15 @@ func resourceNodeV1() *schema.Resource {
 
 				// If power_state is same as target_power_state, we have no changes to apply
 				DiffSuppressFunc: func(_, old, new string, d *schema.ResourceData) bool {
-					return new == d.Get("power_state").(string)
+					return normalizePowerState(new) == normalizePowerState(d.Get("power_state").(string))
 				},
 			},
+			// Only used when changing power state, Ironic doesn't store it on the node. It's never read back, so it
+			// always reflects the config.
 			"power_state_timeout": {
-				Type:     schema.TypeInt,
-				Optional: true,
-				Computed: true,
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(0),
 			},
 			"raid_config": {
 				Type:     schema.TypeString,
@@ -186,382 +504,2196 @@ func resourceNodeV1() *schema.Resource {
 				Type:     schema.TypeString,
 				Optional: true,
 				Computed: true,
+
+				// Drifted settings are read back as JSON, which may be formatted differently from the config
+				DiffSuppressFunc: func(_, old, new string, _ *schema.ResourceData) bool {
+					var oldSettings, newSettings []map[string]string
+					if json.Unmarshal([]byte(old), &oldSettings) != nil || json.Unmarshal([]byte(new), &newSettings) != nil {
+						return false
+					}
+					return reflect.DeepEqual(oldSettings, newSettings)
+				},
 			},
 		},
 	}
 }
 
-// Create a node, including driving Ironic's state machine
-func resourceNodeV1Create(d *schema.ResourceData, meta interface{}) error {
-	client, err := meta.(*Clients).GetIronicClient()
-	if err != nil {
-		return err
+// driverInfoFields maps first-class schema fields to the driver_info keys they're stored in. Fields sharing a key
+// conflict with each other, so only one of them is ever set.
+var driverInfoFields = map[string]string{
+	"provisioning_network": "provisioning_network",
+	"cleaning_network":     "cleaning_network",
+	"rescuing_network":     "rescuing_network",
+	"inspection_network":   "inspection_network",
+	"deploy_kernel":        "deploy_kernel",
+	"deploy_ramdisk":       "deploy_ramdisk",
+	"rescue_kernel":        "rescue_kernel",
+	"rescue_ramdisk":       "rescue_ramdisk",
+
+	// Used by the ramdisk to download the instance image with the direct deploy interface
+	"image_download_source": "image_download_source",
+	"image_http_proxy":      "image_http_proxy",
+	"image_https_proxy":     "image_https_proxy",
+	"image_no_proxy":        "image_no_proxy",
+
+	// Ironic boots the deploy ramdisk for managed inspection
+	"inspection_kernel":        "deploy_kernel",
+	"inspection_ramdisk":       "deploy_ramdisk",
+	"inspection_kernel_params": "kernel_append_params",
+}
+
+// neutronNetworkFields are the fields which only work with the neutron network interface.
+var neutronNetworkFields = []string{"provisioning_network", "cleaning_network", "rescuing_network", "inspection_network"}
+
+// imageDownloadFields are the driver_info settings for how the ramdisk downloads the instance image.
+var imageDownloadFields = []string{"image_download_source", "image_http_proxy", "image_https_proxy", "image_no_proxy"}
+
+// managedInspectInterfaces are the inspect interfaces where Ironic boots the node into the ramdisk itself.
+var managedInspectInterfaces = []string{"agent", "inspector"}
+
+// nodeFlagConflicts maps a flag to the flags that cannot be newly requested while it's enabled.
+var nodeFlagConflicts = map[string][]string{
+	// Ironic refuses provision state changes for nodes in maintenance
+	"maintenance": {"available", "clean", "inspect"},
+
+	// Retired nodes are cleaned back to manageable, and can't be made available
+	"retired": {"available"},
+
+	// Drained nodes are left in maintenance
+	"drain": {"available", "clean", "inspect"},
+}
+
+// Validate the desired state is something we can actually reach
+func resourceNodeV1CustomizeDiff(ctx context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	enabled := make(map[string]bool)
+	requested := make(map[string]bool)
+	for flag, others := range nodeFlagConflicts {
+		for _, f := range append([]string{flag}, others...) {
+			enabled[f] = d.Get(f).(bool)
+			requested[f] = enabled[f] && d.HasChange(f)
+		}
 	}
 
-	// Create the node object in Ironic
-	createOpts := schemaToCreateOpts(d)
-	result, err := nodes.Create(client, createOpts).Extract()
-	if err != nil {
-		d.SetId("")
+	if err := validateNodeFlags(enabled, requested); err != nil {
 		return err
 	}
+	if requested["drain"] && d.Id() == "" {
+		return fmt.Errorf("drain takes an existing node out of service, so it can't be set when creating the node")
+	}
+	if requested["drain"] && !d.Get("maintenance").(bool) {
+		return fmt.Errorf("drain leaves the node in maintenance, so maintenance must be true too")
+	}
+	// Ironic only protects active nodes, and a node this resource creates is never deployed
+	if d.HasChange("protected") && d.Get("protected").(bool) {
+		if state := d.Get("provision_state").(string); d.Id() == "" || state != "active" {
+			return fmt.Errorf("protected can only be set on an active node, deploy the node with ironic_deployment first")
+		}
+	}
 
-	// Setting the ID is what tells terraform we were successful in creating the node
-	log.Printf("[DEBUG] Node created with ID %s\n", d.Id())
-	d.SetId(result.UUID)
+	for _, field := range neutronNetworkFields {
+		if networkInterface := d.Get("network_interface").(string); d.Get(field).(string) != "" &&
+			d.NewValueKnown("network_interface") && networkInterface != "" && networkInterface != "neutron" {
+			return fmt.Errorf("%s requires the 'neutron' network_interface, got '%s'", field, networkInterface)
+		}
+	}
 
-	// Create ports as part of the node object - you may also use the native port resource
-	portSet := d.Get("ports").(*schema.Set)
-	if portSet != nil {
-		portList := portSet.List()
-		for _, portInterface := range portList {
-			port := portInterface.(map[string]interface{})
-
-			// Terraform map can't handle bool... seriously.
-			var pxeEnabled bool
-			if port["pxe_enabled"] != nil {
-				if port["pxe_enabled"] == "true" {
-					pxeEnabled = true
-				} else {
-					pxeEnabled = false
-				}
+	driverInfo := d.Get("driver_info").(map[string]interface{})
+	fields := make(map[string]string)
+	for field := range driverInfoFields {
+		fields[field] = d.Get(field).(string)
+	}
+	if err := validateDriverInfoFields(fields, driverInfo); err != nil {
+		return err
+	}
+	if err := validateDeployRamdisk(fields, driverInfo); err != nil {
+		return err
+	}
+	if d.NewValueKnown("driver") && d.NewValueKnown("driver_info") && (d.HasChange("driver") || d.HasChange("driver_info")) {
+		if err := validateDriverInfoRequired(d.Get("driver").(string), driverInfo); err != nil {
+			return err
+		}
+	}
+	// Only check when rescue is being configured, so existing nodes with Ironic's default rescue_interface still apply
+	if d.NewValueKnown("rescue_interface") &&
+		(d.HasChange("rescue_interface") || d.HasChange("rescue_kernel") || d.HasChange("rescue_ramdisk")) {
+		if err := validateRescueRamdisk(d.Get("rescue_interface").(string), fields, driverInfo); err != nil {
+			return err
+		}
+	}
 
+	if driver := d.Get("driver").(string); d.Get("validate_interfaces").(bool) && d.NewValueKnown("driver") && driver != "" {
+		interfaces := make(map[string]string)
+		for field := range driverInterfaceFields {
+			if value := d.Get(field).(string); value != "" && d.NewValueKnown(field) {
+				interfaces[field] = value
 			}
-			// FIXME: All values other than address and pxe
-			portCreateOpts := ports.CreateOpts{
-				NodeUUID:   d.Id(),
-				Address:    port["address"].(string),
-				PXEEnabled: &pxeEnabled,
-			}
-			_, err := ports.Create(client, portCreateOpts).Extract()
-			if err != nil {
-				_ = resourcePortV1Read(d, meta)
-				return err
-			}
+		}
+		api, err := meta.(*Clients).GetIronicAPI(ctx)
+		if err != nil {
+			return err
+		}
+		details, err := api.GetDriver(driver)
+		if err != nil {
+			return fmt.Errorf("could not get driver %s: %s", driver, err)
+		}
+		if err := validateEnabledInterfaces(driver, interfaces, enabledInterfaces(details)); err != nil {
+			return err
 		}
 	}
 
-	// Make node manageable
-	if d.Get("manage").(bool) || d.Get("clean").(bool) || d.Get("inspect").(bool) {
-		if err := ChangeProvisionStateToTarget(client, d.Id(), "manage", nil, nil, nil); err != nil {
-			return fmt.Errorf("could not manage: %s", err)
+	if d.NewValueKnown("deploy_interface") {
+		if err := validateImageDownload(d.Get("deploy_interface").(string), fields, driverInfo); err != nil {
+			return err
+		}
+		if err := validateFastTrack(d.Get("deploy_interface").(string), d.Get("fast_track").(bool)); err != nil {
+			return err
+		}
+	}
+	if _, ok := d.GetOkExists("fast_track"); ok {
+		if _, set := driverInfo["fast_track"]; set {
+			return fmt.Errorf("fast_track conflicts with driver_info.fast_track, only set one of them")
 		}
 	}
 
-	// Clean node
-	if d.Get("clean").(bool) {
-		if err := setRAIDConfig(client, d); err != nil {
-			return fmt.Errorf("fail to set raid config: %s", err)
+	if raidInterface := d.Get("raid_interface").(string); raidInterface != "" && d.NewValueKnown("raid_interface") &&
+		d.NewValueKnown("raid_config") {
+		if err := validateRAIDConfig(raidInterface, d.Get("raid_config").(string)); err != nil {
+			return err
 		}
+	}
 
-		var cleanSteps []nodes.CleanStep
-		if cleanSteps, err = buildManualCleaningSteps(d.Get("raid_interface").(string), d.Get("raid_config").(string), d.Get("bios_settings").(string)); err != nil {
-			return fmt.Errorf("fail to build raid clean steps: %s", err)
+	if d.NewValueKnown("management_interface") && d.NewValueKnown("clean_steps") {
+		if _, err := buildExtraCleanSteps(d.Get("management_interface").(string), d.Get("clean_steps").(string), d.Get("reset_bmc").(bool)); err != nil {
+			return err
 		}
+	}
 
-		if err := ChangeProvisionStateToTarget(client, d.Id(), "clean", nil, nil, cleanSteps); err != nil {
-			return fmt.Errorf("could not clean: %s", err)
+	// Ironic refuses manual cleaning without any steps, and clean_trigger does nothing when creating a node
+	if d.Id() != "" && d.HasChange("clean_trigger") && d.Get("clean_trigger").(string) != "" {
+		hasSteps := d.Get("reset_bmc").(bool)
+		for _, field := range []string{"raid_config", "bios_settings", "clean_steps"} {
+			hasSteps = hasSteps || d.Get(field).(string) != "" || !d.NewValueKnown(field)
+		}
+		if !hasSteps {
+			return fmt.Errorf("clean_trigger needs raid_config, bios_settings, clean_steps or reset_bmc to clean the node with")
 		}
 	}
 
-	// Inspect node
-	if d.Get("inspect").(bool) {
-		if err := ChangeProvisionStateToTarget(client, d.Id(), "inspect", nil, nil, nil); err != nil {
-			return fmt.Errorf("could not inspect: %s", err)
+	if d.NewValueKnown("inspect_interface") {
+		if err := validateInspectionKernelParams(d.Get("inspect_interface").(string), fields); err != nil {
+			return err
+		}
+	}
+	if d.Get("inspect").(bool) && d.HasChange("inspect") && d.NewValueKnown("inspect_interface") {
+		if err := validateManagedInspection(d.Get("inspect_interface").(string), fields, driverInfo); err != nil {
+			return err
 		}
 	}
 
-	// Make node available
-	if d.Get("available").(bool) {
-		if err := ChangeProvisionStateToTarget(client, d.Id(), "provide", nil, nil, nil); err != nil {
-			return fmt.Errorf("could not make node available: %s", err)
+	return validateFlatNetworkPorts(d.Get("network_data").(string), d.Get("network_interface").(string), d.Get("ports").(*schema.Set).List())
+}
+
+// validateDriverInfoFields ensures a driver_info key isn't set both by its own field and in the driver_info map, as
+// they'd fight over the value.
+func validateDriverInfoFields(fields map[string]string, driverInfo map[string]interface{}) error {
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	for _, field := range names {
+		key := driverInfoFields[field]
+		if _, ok := driverInfo[key]; ok && fields[field] != "" {
+			return fmt.Errorf("%s conflicts with driver_info.%s, only set one of them", field, key)
 		}
 	}
 
-	// Change power state, if required
-	if targetPowerState := d.Get("target_power_state").(string); targetPowerState != "" {
-		err := changePowerState(client, d, nodes.TargetPowerState(targetPowerState))
-		if err != nil {
-			return fmt.Errorf("could not change power state: %s", err)
+	return nil
+}
+
+// driverInfoKeySet returns whether a driver_info key is set, either by one of its fields or in driver_info itself.
+func driverInfoKeySet(key string, fields map[string]string, driverInfo map[string]interface{}) bool {
+	for field, k := range driverInfoFields {
+		if k == key && fields[field] != "" {
+			return true
 		}
 	}
+	value, _ := driverInfo[key].(string)
+	return value != ""
+}
 
-	return resourceNodeV1Read(d, meta)
+// driverRequiredKeys lists the driver_info keys each driver needs to manage the node, where each entry is satisfied by
+// any one of its keys. Drivers not listed here aren't checked, add them as needed.
+var driverRequiredKeys = map[string][][]string{
+	"ipmi":    {{"ipmi_address"}},
+	"redfish": {{"redfish_address"}},
+	"idrac":   {{"redfish_address", "drac_address"}},
+	"ilo":     {{"ilo_address"}, {"ilo_username"}, {"ilo_password"}},
+	"ilo5":    {{"ilo_address"}, {"ilo_username"}, {"ilo_password"}},
 }
 
-// Read the node's data from Ironic
-func resourceNodeV1Read(d *schema.ResourceData, meta interface{}) error {
-	client, err := meta.(*Clients).GetIronicClient()
-	if err != nil {
-		return err
+// validateDriverInfoRequired ensures driver_info has the keys the node's driver requires, which Ironic otherwise
+// only reports when it first tries to manage the node.
+func validateDriverInfoRequired(driver string, driverInfo map[string]interface{}) error {
+	for _, keys := range driverRequiredKeys[driver] {
+		found := false
+		for _, key := range keys {
+			if _, ok := driverInfo[key]; ok {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("the '%s' driver requires driver_info.%s", driver, strings.Join(keys, " or driver_info."))
+		}
 	}
+	return nil
+}
 
-	node, err := nodes.Get(client, d.Id()).Extract()
-	if err != nil {
-		d.SetId("")
-		return err
+// validateDeployRamdisk ensures the deploy kernel and ramdisk are set together, whether through their fields or in
+// driver_info. The deploy interfaces that boot a ramdisk need both, and only having one of them fails at deploy time.
+func validateDeployRamdisk(fields map[string]string, driverInfo map[string]interface{}) error {
+	if driverInfoKeySet("deploy_kernel", fields, driverInfo) != driverInfoKeySet("deploy_ramdisk", fields, driverInfo) {
+		return fmt.Errorf("deploy_kernel and deploy_ramdisk must be set together")
 	}
+	return nil
+}
 
-	// TODO: Ironic's Create is different than the Node object itself, GET returns things like the
-	//  RaidConfig, we need to add those and handle them in CREATE
-	err = d.Set("boot_interface", node.BootInterface)
-	if err != nil {
-		return err
+// validateRescueRamdisk ensures the rescue kernel and ramdisk are set together, and that they're set at all when the
+// agent rescue interface is used, which boots them to rescue the node. Otherwise rescuing fails.
+func validateRescueRamdisk(rescueInterface string, fields map[string]string, driverInfo map[string]interface{}) error {
+	kernel := driverInfoKeySet("rescue_kernel", fields, driverInfo)
+	ramdisk := driverInfoKeySet("rescue_ramdisk", fields, driverInfo)
+	if rescueInterface == "agent" && !kernel && !ramdisk {
+		return fmt.Errorf("the 'agent' rescue_interface requires rescue_kernel and rescue_ramdisk")
 	}
-	err = d.Set("conductor_group", node.ConductorGroup)
-	if err != nil {
-		return err
+	if kernel != ramdisk {
+		return fmt.Errorf("rescue_kernel and rescue_ramdisk must be set together")
 	}
-	err = d.Set("console_interface", node.ConsoleInterface)
-	if err != nil {
-		return err
+	return nil
+}
+
+// driverInterfaceFields maps each hardware interface field to the interfaces a driver has enabled for it.
+var driverInterfaceFields = map[string]func(*drivers.Driver) []string{
+	"boot_interface":       func(d *drivers.Driver) []string { return d.EnabledBootInterfaces },
+	"console_interface":    func(d *drivers.Driver) []string { return d.EnabledConsoleInterface },
+	"deploy_interface":     func(d *drivers.Driver) []string { return d.EnabledDeployInterfaces },
+	"inspect_interface":    func(d *drivers.Driver) []string { return d.EnabledInspectInterfaces },
+	"management_interface": func(d *drivers.Driver) []string { return d.EnabledManagementInterfaces },
+	"network_interface":    func(d *drivers.Driver) []string { return d.EnabledNetworkInterfaces },
+	"power_interface":      func(d *drivers.Driver) []string { return d.EnabledPowerInterfaces },
+	"raid_interface":       func(d *drivers.Driver) []string { return d.EnabledRaidInterfaces },
+	"rescue_interface":     func(d *drivers.Driver) []string { return d.EnabledRescueInterfaces },
+	"storage_interface":    func(d *drivers.Driver) []string { return d.EnabledStorageInterfaces },
+	"vendor_interface":     func(d *drivers.Driver) []string { return d.EnabledVendorInterfaces },
+}
+
+// enabledInterfaces returns the interfaces the driver has enabled, keyed by field.
+func enabledInterfaces(details *drivers.Driver) map[string][]string {
+	enabled := make(map[string][]string)
+	for field, get := range driverInterfaceFields {
+		enabled[field] = get(details)
 	}
-	err = d.Set("deploy_interface", node.DeployInterface)
-	if err != nil {
-		return err
+	return enabled
+}
+
+// validateEnabledInterfaces ensures each of the node's hardware interfaces is enabled for its driver. Otherwise the
+// node is only rejected once it's created or provisioned.
+func validateEnabledInterfaces(driver string, interfaces map[string]string, enabled map[string][]string) error {
+	fields := make([]string, 0, len(interfaces))
+	for field := range interfaces {
+		fields = append(fields, field)
 	}
-	err = d.Set("driver", node.Driver)
-	if err != nil {
-		return err
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		valid := enabled[field]
+		found := false
+		for _, iface := range valid {
+			if iface == interfaces[field] {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s '%s' isn't enabled for driver '%s', valid values are: %s", field, interfaces[field], driver, strings.Join(valid, ", "))
+		}
 	}
-	err = d.Set("driver_info", node.DriverInfo)
-	if err != nil {
-		return err
+
+	return nil
+}
+
+// validateImageDownload ensures the image download settings are consistent. Only the ramdisk downloads the instance
+// image itself, with the direct deploy interface, and image_no_proxy only makes sense alongside a proxy. Otherwise
+// the settings are silently ignored when deploying.
+func validateImageDownload(deployInterface string, fields map[string]string, driverInfo map[string]interface{}) error {
+	var set []string
+	for _, field := range imageDownloadFields {
+		if driverInfoKeySet(driverInfoFields[field], fields, driverInfo) {
+			set = append(set, field)
+		}
 	}
-	err = d.Set("extra", node.Extra)
-	if err != nil {
-		return err
+	if len(set) > 0 && deployInterface != "" && deployInterface != "direct" {
+		return fmt.Errorf("the 'direct' deploy_interface is required for %s, got '%s'", strings.Join(set, " and "), deployInterface)
 	}
-	err = d.Set("inspect_interface", node.InspectInterface)
-	if err != nil {
-		return err
+
+	if driverInfoKeySet("image_no_proxy", fields, driverInfo) && !driverInfoKeySet("image_http_proxy", fields, driverInfo) &&
+		!driverInfoKeySet("image_https_proxy", fields, driverInfo) {
+		return fmt.Errorf("image_no_proxy requires image_http_proxy or image_https_proxy")
+	}
+
+	return nil
+}
+
+// fastTrackDeployInterfaces are the deploy interfaces which use the ramdisk's agent, and so can keep it running
+// between operations.
+var fastTrackDeployInterfaces = []string{"direct", "ansible", "custom-agent"}
+
+// validateFastTrack ensures fast_track is only enabled with a deploy interface that uses the agent, otherwise there's
+// no ramdisk to keep running and the setting is silently ignored.
+func validateFastTrack(deployInterface string, fastTrack bool) error {
+	if !fastTrack || deployInterface == "" {
+		return nil
+	}
+	for _, iface := range fastTrackDeployInterfaces {
+		if deployInterface == iface {
+			return nil
+		}
+	}
+	return fmt.Errorf("fast_track requires an agent based deploy_interface (%s), got '%s'",
+		strings.Join(fastTrackDeployInterfaces, ", "), deployInterface)
+}
+
+// validateRAIDConfig checks the RAID config can be applied with the node's raid_interface before we get to cleaning.
+// Software RAID is built by the ramdisk, so it needs the agent raid interface, and a layout mdadm can boot from.
+func validateRAIDConfig(raidInterface, raidConfig string) error {
+	if raidConfig == "" {
+		return nil
+	}
+
+	var targetRAID *metal3v1alpha1.RAIDConfig
+	if err := json.Unmarshal([]byte(raidConfig), &targetRAID); err != nil {
+		return fmt.Errorf("could not parse raid_config: %s", err)
+	}
+	if targetRAID == nil {
+		return nil
+	}
+
+	if volumes := targetRAID.SoftwareRAIDVolumes; len(targetRAID.HardwareRAIDVolumes) == 0 && len(volumes) != 0 {
+		if raidInterface != "agent" {
+			return fmt.Errorf("software RAID requires the 'agent' raid_interface, got '%s'", raidInterface)
+		}
+		if len(volumes) > 2 {
+			return fmt.Errorf("software RAID supports at most 2 volumes, got %d", len(volumes))
+		}
+		if volumes[0].Level != "1" {
+			return fmt.Errorf("the first software RAID volume must be RAID-1, got '%s'", volumes[0].Level)
+		}
+		if len(volumes) == 2 && volumes[1].Level != "0" && volumes[1].Level != "1" && volumes[1].Level != "1+0" {
+			return fmt.Errorf("the second software RAID volume must be RAID-0, RAID-1 or RAID-1+0, got '%s'", volumes[1].Level)
+		}
+		for i, volume := range volumes {
+			if len(volume.PhysicalDisks) == 1 {
+				return fmt.Errorf("software RAID volume %d needs at least 2 physical disks", i)
+			}
+		}
+	}
+
+	return ironic.CheckRAIDInterface(raidInterface, targetRAID)
+}
+
+// validateManagedInspection ensures that when Ironic manages booting the node for inspection, it knows which ramdisk
+// to boot. The kernel and ramdisk can come from their own fields, or from driver_info.
+func validateManagedInspection(inspectInterface string, fields map[string]string, driverInfo map[string]interface{}) error {
+	if !isManagedInspectInterface(inspectInterface) {
+		return nil
+	}
+
+	var missing []string
+	for _, field := range []string{"inspection_kernel", "inspection_ramdisk"} {
+		// The deploy_kernel and deploy_ramdisk fields are named after the driver_info key they share
+		key := driverInfoFields[field]
+		if value, _ := driverInfo[key].(string); fields[field] == "" && fields[key] == "" && value == "" {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the '%s' inspect_interface requires %s", inspectInterface, strings.Join(missing, " and "))
+	}
+
+	return nil
+}
+
+// validateInspectionKernelParams ensures inspection_kernel_params is only set with a managed inspect interface, as
+// otherwise Ironic doesn't boot the ramdisk for inspection. Ironic's default inspect interface depends on its
+// configuration, so it isn't checked.
+func validateInspectionKernelParams(inspectInterface string, fields map[string]string) error {
+	if fields["inspection_kernel_params"] == "" || inspectInterface == "" || isManagedInspectInterface(inspectInterface) {
+		return nil
+	}
+	return fmt.Errorf("inspection_kernel_params requires a managed inspect_interface (%s), got '%s'",
+		strings.Join(managedInspectInterfaces, " or "), inspectInterface)
+}
+
+// isManagedInspectInterface returns whether Ironic boots the node into the ramdisk itself to inspect it.
+func isManagedInspectInterface(inspectInterface string) bool {
+	for _, iface := range managedInspectInterfaces {
+		if inspectInterface == iface {
+			return true
+		}
+	}
+	return false
+}
+
+// vendorCleanSteps are the management clean steps of the vendor management interfaces. Other management interfaces
+// aren't checked, Ironic rejects the steps they don't have when cleaning.
+var vendorCleanSteps = map[string][]string{
+	"idrac-redfish": {"reset_idrac", "clear_job_queue", "known_good_state", "export_configuration", "import_configuration",
+		"import_export_configuration", "update_firmware"},
+	"idrac-wsman": {"reset_idrac", "clear_job_queue", "known_good_state"},
+	"ilo": {"reset_ilo", "reset_ilo_credential", "reset_bios_to_default", "reset_secure_boot_keys_to_default",
+		"clear_secure_boot_keys", "update_firmware", "update_firmware_sum"},
+	"ilo5": {"reset_ilo", "reset_ilo_credential", "reset_bios_to_default", "reset_secure_boot_keys_to_default",
+		"clear_secure_boot_keys", "update_firmware", "update_firmware_sum", "clear_ca_certificates"},
+}
+
+// bmcResetSteps are the management clean steps which reset the BMC, for reset_bmc.
+var bmcResetSteps = map[string]string{
+	"idrac-redfish": "reset_idrac",
+	"idrac-wsman":   "reset_idrac",
+	"ilo":           "reset_ilo",
+	"ilo5":          "reset_ilo",
+}
+
+// buildExtraCleanSteps builds the clean steps run after the RAID and BIOS configuration, from clean_steps and
+// reset_bmc. Management steps must be supported by a vendor management interface. The management interface is only
+// required for reset_bmc.
+func buildExtraCleanSteps(managementInterface, steps string, resetBMC bool) ([]nodes.CleanStep, error) {
+	var cleanSteps []nodes.CleanStep
+	if steps != "" {
+		if err := json.Unmarshal([]byte(steps), &cleanSteps); err != nil {
+			return nil, fmt.Errorf("could not parse clean_steps: %s", err)
+		}
+	}
+
+	for _, step := range cleanSteps {
+		supported, vendor := vendorCleanSteps[managementInterface]
+		if step.Interface != "management" || !vendor {
+			continue
+		}
+		found := false
+		for _, name := range supported {
+			if step.Step == name {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("clean step management.%s isn't supported by management_interface %s, it supports %s",
+				step.Step, managementInterface, strings.Join(supported, ", "))
+		}
+	}
+
+	if resetBMC {
+		step, ok := bmcResetSteps[managementInterface]
+		if !ok {
+			return nil, fmt.Errorf("reset_bmc isn't supported by management_interface '%s'", managementInterface)
+		}
+		cleanSteps = append(cleanSteps, nodes.CleanStep{Interface: "management", Step: step})
+	}
+	return cleanSteps, nil
+}
+
+// validateFlatNetworkPorts ensures that with static network_data on a flat network, every port says which physical
+// network it's attached to. Otherwise the deployment fails later on with an error from neutron.
+func validateFlatNetworkPorts(networkData, networkInterface string, nodePorts []interface{}) error {
+	if networkData == "" || networkInterface != "flat" {
+		return nil
+	}
+
+	for _, p := range nodePorts {
+		port := p.(map[string]interface{})
+		if physicalNetwork, _ := port["physical_network"].(string); physicalNetwork == "" {
+			return fmt.Errorf("port %v must set physical_network when network_data is set with the 'flat' network_interface", port["address"])
+		}
+	}
+
+	return nil
+}
+
+// validateNodeFlags returns an error listing the conflicting flags, if a requested flag conflicts with an enabled one.
+func validateNodeFlags(enabled, requested map[string]bool) error {
+	flags := make([]string, 0, len(nodeFlagConflicts))
+	for flag := range nodeFlagConflicts {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+
+	var errs []string
+	for _, flag := range flags {
+		if !enabled[flag] {
+			continue
+		}
+
+		var conflicts []string
+		for _, other := range nodeFlagConflicts[flag] {
+			if requested[other] {
+				conflicts = append(conflicts, other)
+			}
+		}
+		if len(conflicts) > 0 {
+			errs = append(errs, fmt.Sprintf("%s cannot be combined with %s", flag, strings.Join(conflicts, ", ")))
+		}
 	}
-	err = d.Set("instance_uuid", node.InstanceUUID)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("conflicting flags: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// Create a node, including driving Ironic's state machine
+func resourceNodeV1Create(ctx context.Context, d *schema.ResourceData, meta interface{}) (diags diag.Diagnostics) {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
+	}
+	provisionCtx, cancel := provisionContext(ctx, d, schema.TimeoutCreate)
+	defer cancel()
+
+	if d.Get("retired").(bool) || d.Get("retired_reason").(string) != "" {
+		if err := checkRetirementSupport(api.Microversion()); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	// Create the node object in Ironic
+	createOpts := schemaToCreateOpts(d, meta.(*Clients).nodeDefaults)
+	if extraJSON := d.Get("extra_json").(string); extraJSON != "" {
+		extra, err := structure.ExpandJsonFromString(extraJSON)
+		if err != nil {
+			return diag.Errorf("could not parse extra_json: %s", err)
+		}
+		createOpts.Extra = extra
+	}
+	if networkData := d.Get("network_data").(string); networkData != "" {
+		createOpts.NetworkData, err = structure.ExpandJsonFromString(networkData)
+		if err != nil {
+			return diag.Errorf("could not parse network_data: %s", err)
+		}
+	}
+	result, err := api.CreateNode(createOpts)
+	if err != nil {
+		d.SetId("")
+		return diag.FromErr(err)
+	}
+
+	// Setting the ID is what tells terraform we were successful in creating the node
+	log.Printf("[DEBUG] Node created with ID %s\n", d.Id())
+	d.SetId(result.UUID)
+
+	// Some fields aren't part of the create request, so set them afterwards
+	var opts nodes.UpdateOpts
+	for _, field := range []string{"maintenance", "retired"} {
+		if d.Get(field).(bool) {
+			opts = append(opts, nodes.UpdateOperation{
+				Op:    nodes.ReplaceOp,
+				Path:  fmt.Sprintf("/%s", field),
+				Value: true,
+			})
+		}
+	}
+	for _, field := range []string{"description", "lessee", "retired_reason"} {
+		if value := d.Get(field).(string); value != "" {
+			opts = append(opts, stringFieldUpdateOp(field, value))
+		}
+	}
+	if len(opts) > 0 {
+		if _, err := UpdateNode(ctx, api, d.Id(), opts); err != nil {
+			return diag.Errorf("could not update node after creation: %s", err)
+		}
+	}
+	if lessee := d.Get("lessee").(string); lessee != "" {
+		if err := verifyNodeOwnership(api, d.Id(), map[string]string{"lessee": lessee}); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	if traits := d.Get("traits").(*schema.Set); traits.Len() > 0 {
+		if err := updateNodeTraits(api, d.Id(), nil, traits.List()); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	// Create ports as part of the node object - you may also use the native port resource
+	portSet := d.Get("ports").(*schema.Set)
+	portUUIDs := make(map[string]interface{})
+	if portSet != nil {
+		err := createNodePorts(ctx, portSet.List(), portUUIDs, api.RetryJitter(), func(port map[string]interface{}) (*ports.Port, error) {
+			return createNodePort(api, d.Id(), port)
+		})
+		if err != nil {
+			_ = d.Set("port_uuids", portUUIDs)
+			return diag.FromErr(err)
+		}
+	}
+	if err := d.Set("port_uuids", portUUIDs); err != nil {
+		return diag.FromErr(err)
+	}
+
+	progress := trackProvisionProgress(d, true)
+	defer func() {
+		if diags.HasError() {
+			progress.revert()
+		}
+	}()
+
+	// Make node manageable
+	if d.Get("manage").(bool) || d.Get("clean").(bool) || d.Get("inspect").(bool) {
+		stepBack := d.Get("clean").(bool) || d.Get("inspect").(bool)
+		if err := manageNode(provisionCtx, api, d.Id(), stepBack); err != nil {
+			return diag.Errorf("could not manage: %s", err)
+		}
+		progress.reach("manage")
+	}
+
+	// Clean node, which leaves it manageable unless it's made available below
+	if d.Get("clean").(bool) {
+		if err := cleanNode(provisionCtx, api, d, true); err != nil {
+			return diag.FromErr(err)
+		}
+		progress.reach("clean")
+	}
+
+	// Inspect node
+	if d.Get("inspect").(bool) {
+		if err := ChangeProvisionStateToTarget(provisionCtx, api, d.Id(), "inspect", nil, nil, nil); err != nil {
+			return diag.Errorf("could not inspect: %s", err)
+		}
+		progress.reach("inspect")
+	}
+
+	// Make node available, after inspection has brought it back to manageable if it was inspected
+	if d.Get("available").(bool) {
+		if err := ChangeProvisionStateToTarget(provisionCtx, api, d.Id(), "provide", nil, nil, nil); err != nil {
+			return diag.Errorf("could not make node available: %s", err)
+		}
+		progress.reach("available")
+	}
+
+	progress.finish()
+
+	// Change power state, if required
+	if targetPowerState := d.Get("target_power_state").(string); targetPowerState != "" {
+		err := changePowerState(ctx, api, d, nodes.TargetPowerState(targetPowerState))
+		if err != nil {
+			return diag.Errorf("could not change power state: %s", err)
+		}
+	}
+
+	return resourceNodeV1Read(ctx, d, meta)
+}
+
+// Read the node's data from Ironic
+func resourceNodeV1Read(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	node, err := getExtendedNode(api, d.Id())
+	if err != nil {
+		d.SetId("")
+		return diag.FromErr(err)
+	}
+
+	// TODO: Ironic's Create is different than the Node object itself, GET returns things like the
+	//  RaidConfig, we need to add those and handle them in CREATE
+	err = d.Set("automated_clean", flattenAutomatedClean(node.AutomatedClean))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("boot_interface", node.BootInterface)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if !strings.EqualFold(d.Get("conductor_group").(string), node.ConductorGroup) {
+		err = d.Set("conductor_group", node.ConductorGroup)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	err = d.Set("conductor", node.Conductor)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	diags := checkConductorAffinity(api, node)
+	err = d.Set("console_interface", node.ConsoleInterface)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("console_url", nodeConsoleURL(api, d.Id(), node.ConsoleEnabled))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("deploy_interface", node.DeployInterface)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("driver", node.Driver)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	for field, key := range driverInfoFields {
+		if d.Get(field).(string) == "" {
+			continue
+		}
+		value, _ := node.DriverInfo[key].(string)
+		err = d.Set(field, value)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	for field, key := range driverInfoFields {
+		if d.Get(field).(string) != "" {
+			delete(node.DriverInfo, key)
+		}
+	}
+	if _, ok := d.GetOkExists("fast_track"); ok {
+		fastTrack, _ := strconv.ParseBool(fmt.Sprint(node.DriverInfo["fast_track"]))
+		if err := d.Set("fast_track", fastTrack); err != nil {
+			return diag.FromErr(err)
+		}
+		delete(node.DriverInfo, "fast_track")
+	}
+	// Networks that came from the provider's defaults aren't part of the configuration, don't report a diff
+	configuredDriverInfo := d.Get("driver_info").(map[string]interface{})
+	for _, key := range nodeDefaultNetworkFields {
+		if _, ok := configuredDriverInfo[key]; !ok && node.DriverInfo[key] == meta.(*Clients).nodeDefaults[key] {
+			delete(node.DriverInfo, key)
+		}
+	}
+	if secrets := unmaskedSecrets(node.DriverInfo); len(secrets) > 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Ironic returned driver_info %s of node %s unmasked", strings.Join(secrets, ", "), d.Id()),
+			Detail:   "They're stored in the Terraform state in plain text. Disable the show_password policy in Ironic to mask them.",
+		})
+	}
+	err = d.Set("driver_info", node.DriverInfo)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	driverInternalInfo, err := structure.FlattenJsonToString(node.DriverInternalInfo)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("driver_internal_info", driverInternalInfo)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if d.Get("extra_json").(string) != "" {
+		extraJSON, err := structure.FlattenJsonToString(node.Extra)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		err = d.Set("extra_json", extraJSON)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	} else if d.Get("extra_merge").(bool) {
+		err = d.Set("extra", managedExtra(d.Get("extra").(map[string]interface{}), node.Extra))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	} else {
+		err = d.Set("extra", node.Extra)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	err = d.Set("inspect_interface", node.InspectInterface)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = setNodeInstanceFields(d, node)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("maintenance", node.Maintenance)
+	if err != nil {
+		return diag.FromErr(err)
 	}
 	err = d.Set("management_interface", node.ManagementInterface)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("name", node.Name)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
+	}
+	networkData := ""
+	if len(node.NetworkData) > 0 {
+		networkData, err = structure.FlattenJsonToString(node.NetworkData)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	err = d.Set("network_data", networkData)
+	if err != nil {
+		return diag.FromErr(err)
 	}
 	err = d.Set("network_interface", node.NetworkInterface)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("owner", node.Owner)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
+	}
+	err = d.Set("lessee", node.Lessee)
+	if err != nil {
+		return diag.FromErr(err)
 	}
 	err = d.Set("power_interface", node.PowerInterface)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	err = d.Set("power_state", node.PowerState)
+	nodePorts, err := api.ListNodePorts(d.Id())
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	err = d.Set("root_device", node.Properties["root_device"])
+	err = d.Set("port", flattenPorts(nodePorts))
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	delete(node.Properties, "root_device")
-	err = d.Set("properties", node.Properties)
+	// Only track the ports this resource created, others may be managed by ironic_port_v1
+	if recorded := d.Get("port_uuids").(map[string]interface{}); len(recorded) > 0 {
+		err = d.Set("port_uuids", recordedPortUUIDs(recorded, nodePorts))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	err = d.Set("power_state", normalizePowerState(node.PowerState))
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	err = d.Set("raid_interface", node.RAIDInterface)
+	err = d.Set("protected", node.Protected)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	err = d.Set("rescue_interface", node.RescueInterface)
+	rootDevice, _ := node.Properties["root_device"].(map[string]interface{})
+	err = d.Set("root_device", normalizeRootDeviceHints(rootDevice))
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	err = d.Set("resource_class", node.ResourceClass)
+	delete(node.Properties, "root_device")
+	bootState, err := api.GetBootState(d.Id())
 	if err != nil {
-		return err
+		log.Printf("[WARN] Could not read the boot state of node %s, using its capabilities: %s", d.Id(), err)
 	}
-	err = d.Set("storage_interface", node.StorageInterface)
+	bootMode, secureBoot, currentSecureBoot := nodeBootMode(node.Properties, bootState)
+	err = d.Set("boot_mode", bootMode)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	err = d.Set("vendor_interface", node.VendorInterface)
+	err = d.Set("secure_boot", secureBoot)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	return d.Set("provision_state", node.ProvisionState)
-}
+	err = d.Set("current_secure_boot", currentSecureBoot)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("properties", node.Properties)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("raid_interface", node.RAIDInterface)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("rescue_interface", node.RescueInterface)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	// A resource class that came from the provider's defaults isn't part of the configuration, don't report a diff
+	if node.ResourceClass != meta.(*Clients).nodeDefaults["resource_class"] || d.Get("resource_class").(string) != "" {
+		err = d.Set("resource_class", node.ResourceClass)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	err = d.Set("description", node.Description)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("traits", node.Traits)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("retired", node.Retired)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("retired_reason", node.RetiredReason)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("storage_interface", node.StorageInterface)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("vendor_interface", node.VendorInterface)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("provision_updated_at", node.ProvisionUpdatedAt)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("inspection_started_at", node.InspectionStartedAt)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = d.Set("inspection_finished_at", node.InspectionFinishedAt)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	err = readBIOSSettings(api, d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	return append(diags, diag.FromErr(d.Set("provision_state", node.ProvisionState))...)
+}
 
 // Update a node's state based on the terraform config - TODO: handle everything
-func resourceNodeV1Update(d *schema.ResourceData, meta interface{}) error {
-	client, err := meta.(*Clients).GetIronicClient()
+func resourceNodeV1Update(ctx context.Context, d *schema.ResourceData, meta interface{}) (diags diag.Diagnostics) {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	provisionCtx, cancel := provisionContext(ctx, d, schema.TimeoutUpdate)
+	defer cancel()
+
+	progress := trackProvisionProgress(d, false)
+	defer func() {
+		if diags.HasError() {
+			progress.revert()
+		}
+	}()
+
+	if d.HasChange("retired") || d.HasChange("retired_reason") {
+		if err := checkRetirementSupport(api.Microversion()); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	// Drain the node before the patch below puts it in maintenance. Ironic ignores the agent's heartbeats from nodes in
+	// maintenance, so the cleaning that follows undeploying the node wouldn't finish.
+	if d.HasChange("drain") && d.Get("drain").(bool) {
+		if err := drainNode(ctx, provisionCtx, api, d); err != nil {
+			return diag.Errorf("could not drain node %s: %s", d.Id(), err)
+		}
+		progress.apply("drain")
+	}
+
+	stringFields := []string{
+		"boot_interface",
+		"console_interface",
+		"deploy_interface",
+		"description",
+		"driver",
+		"inspect_interface",
+		"lessee",
+		"management_interface",
+		"name",
+		"network_interface",
+		"owner",
+		"power_interface",
+		"raid_interface",
+		"rescue_interface",
+		"resource_class",
+		"retired_reason",
+		"storage_interface",
+		"vendor_interface",
+	}
+
+	boolFields := []string{
+		"maintenance",
+		"protected",
+		"retired",
+	}
+
+	// Collect all changed fields into a single patch, so we only take the node lock once
+	var opts nodes.UpdateOpts
+	for _, field := range stringFields {
+		if d.HasChange(field) {
+			opts = append(opts, stringFieldUpdateOp(field, d.Get(field).(string)))
+		}
+	}
+	for _, field := range boolFields {
+		if d.HasChange(field) {
+			opts = append(opts, nodes.UpdateOperation{
+				Op:    nodes.ReplaceOp,
+				Path:  fmt.Sprintf("/%s", field),
+				Value: d.Get(field).(bool),
+			})
+		}
+	}
+	if d.HasChange("automated_clean") {
+		opts = append(opts, automatedCleanUpdateOp(d.Get("automated_clean").(string)))
+	}
+
+	if d.HasChange("properties") || d.HasChange("root_device") || d.HasChange("secure_boot") {
+		opts = append(opts, nodes.UpdateOperation{
+			Op:    nodes.AddOp,
+			Path:  "/properties",
+			Value: propertiesMerge(d, "root_device"),
+		})
+	}
+	if d.HasChange("extra_json") && d.Get("extra_json").(string) != "" {
+		extra, err := structure.ExpandJsonFromString(d.Get("extra_json").(string))
+		if err != nil {
+			return diag.Errorf("could not parse extra_json: %s", err)
+		}
+		opts = append(opts, nodes.UpdateOperation{
+			Op:    nodes.AddOp,
+			Path:  "/extra",
+			Value: extra,
+		})
+	} else {
+		opts = append(opts, mapFieldUpdateOpts(d, "extra")...)
+	}
+	if d.HasChange("network_data") {
+		networkData := make(map[string]interface{})
+		if v := d.Get("network_data").(string); v != "" {
+			networkData, err = structure.ExpandJsonFromString(v)
+			if err != nil {
+				return diag.Errorf("could not parse network_data: %s", err)
+			}
+		}
+		opts = append(opts, nodes.UpdateOperation{
+			Op:    nodes.AddOp,
+			Path:  "/network_data",
+			Value: networkData,
+		})
+	}
+	opts = append(opts, mapFieldUpdateOpts(d, "driver_info")...)
+	oldDriverInfo, newDriverInfo := driverInfoFieldChanges(d)
+	if d.HasChange("fast_track") {
+		if fastTrack, ok := d.GetOkExists("fast_track"); ok {
+			opts = append(opts, nodes.UpdateOperation{
+				Op:    nodes.AddOp,
+				Path:  "/driver_info/fast_track",
+				Value: strconv.FormatBool(fastTrack.(bool)),
+			})
+		} else {
+			opts = append(opts, nodes.UpdateOperation{Op: nodes.RemoveOp, Path: "/driver_info/fast_track"})
+		}
+	}
+	for key, value := range newDriverInfo {
+		if value == oldDriverInfo[key] {
+			continue
+		}
+		if value != "" {
+			opts = append(opts, nodes.UpdateOperation{
+				Op:    nodes.AddOp,
+				Path:  fmt.Sprintf("/driver_info/%s", key),
+				Value: value,
+			})
+		} else {
+			opts = append(opts, nodes.UpdateOperation{
+				Op:   nodes.RemoveOp,
+				Path: fmt.Sprintf("/driver_info/%s", key),
+			})
+		}
+	}
+
+	if len(opts) > 0 && d.Get("optimistic_updates").(bool) {
+		if err := checkPriorValues(api, d.Id(), priorValues(d, append(append(stringFields, boolFields...), "automated_clean"))); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	if len(opts) > 0 {
+		// Nodes already in maintenance, or being put in or out of it by this update, are updated as they are
+		if d.Get("maintenance_during_update").(bool) && !d.HasChange("maintenance") && !d.Get("maintenance").(bool) {
+			err = updateNodeInMaintenance(ctx, api, d.Id(), opts)
+		} else {
+			_, err = UpdateNode(ctx, api, d.Id(), opts)
+		}
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	progress.apply(append(append(stringFields, boolFields...),
+		"automated_clean", "properties", "root_device", "secure_boot", "extra_json", "extra", "network_data",
+		"driver_info", "fast_track")...)
+	for field := range driverInfoFields {
+		progress.apply(field)
+	}
+
+	// Ironic's policy may accept the patch but not apply an ownership change, so check it landed
+	ownership := make(map[string]string)
+	for _, field := range []string{"owner", "lessee"} {
+		if d.HasChange(field) {
+			ownership[field] = d.Get(field).(string)
+		}
+	}
+	if len(ownership) > 0 {
+		if err := verifyNodeOwnership(api, d.Id(), ownership); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	// Traits, including any added outside of Terraform, are reconciled with the configuration one at a time
+	if d.HasChange("traits") {
+		o, n := d.GetChange("traits")
+		if err := updateNodeTraits(api, d.Id(), o.(*schema.Set).List(), n.(*schema.Set).List()); err != nil {
+			return diag.FromErr(err)
+		}
+		progress.apply("traits")
+	}
+
+	if d.HasChange("ports") {
+		// port_uuids is set to the ports the node has even if updating them fails part way
+		err := updateNodePorts(ctx, api, d)
+		progress.apply("port_uuids")
+		if err != nil {
+			return diag.Errorf("could not update ports: %s", err)
+		}
+		progress.apply("ports")
+	}
+
+	// Moving a node between conductor groups is done on its own, as it changes which conductor manages the node
+	if d.HasChange("conductor_group") {
+		if err := changeConductorGroup(provisionCtx, api, d.Id(), d.Get("conductor_group").(string)); err != nil {
+			return diag.Errorf("could not change conductor group: %s", err)
+		}
+		progress.apply("conductor_group")
+	}
+
+	// Make node manageable
+	if (d.HasChange("manage") && d.Get("manage").(bool)) ||
+		(d.HasChange("clean") && d.Get("clean").(bool)) ||
+		(d.HasChange("inspect") && d.Get("inspect").(bool)) {
+		stepBack := (d.HasChange("clean") && d.Get("clean").(bool)) || (d.HasChange("inspect") && d.Get("inspect").(bool))
+		if err := manageNode(provisionCtx, api, d.Id(), stepBack); err != nil {
+			return diag.Errorf("could not manage: %s", err)
+		}
+		if d.HasChange("manage") {
+			progress.reach("manage")
+		}
+	}
+
+	// Update power state if required
+	if targetPowerState := d.Get("target_power_state").(string); d.HasChange("target_power_state") && targetPowerState != "" {
+		if err := changePowerState(ctx, api, d, nodes.TargetPowerState(targetPowerState)); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	// Clean node
+	if d.HasChange("clean") && d.Get("clean").(bool) {
+		if err := cleanNode(provisionCtx, api, d, true); err != nil {
+			return diag.FromErr(err)
+		}
+		progress.reach("clean")
+	}
+
+	// Inspect node
+	if d.HasChange("inspect") && d.Get("inspect").(bool) {
+		if err := ChangeProvisionStateToTarget(provisionCtx, api, d.Id(), "inspect", nil, nil, nil); err != nil {
+			return diag.Errorf("could not inspect: %s", err)
+		}
+		progress.reach("inspect")
+	}
+
+	// Apply a changed RAID configuration with a clean cycle, unless the node is already being cleaned below
+	if d.HasChange("raid_config") && !(d.HasChange("clean") && d.Get("clean").(bool)) &&
+		!(d.HasChange("clean_trigger") && d.Get("clean_trigger").(string) != "") {
+		clean, provide, err := configCleanCycle("RAID configuration", d.Get("provision_state").(string))
+		if err != nil {
+			return diag.Errorf("could not apply raid_config to node %s: %s", d.Id(), err)
+		}
+		if clean {
+			if err := cleanNode(provisionCtx, api, d, true); err != nil {
+				return diag.FromErr(err)
+			}
+		}
+		if provide {
+			if err := ChangeProvisionStateToTarget(provisionCtx, api, d.Id(), "provide", nil, nil, nil); err != nil {
+				return diag.Errorf("could not make node available: %s", err)
+			}
+		}
+	}
+
+	// Reconcile changed or drifted BIOS settings, unless the node is already being cleaned with all of them
+	if d.HasChange("bios_settings") && !d.HasChange("raid_config") && !(d.HasChange("clean") && d.Get("clean").(bool)) &&
+		!(d.HasChange("clean_trigger") && d.Get("clean_trigger").(string) != "") {
+		if err := reapplyBIOSSettings(provisionCtx, api, d); err != nil {
+			return diag.Errorf("could not apply bios_settings to node %s: %s", d.Id(), err)
+		}
+	}
+
+	// Run a one-off manual clean, returning the node to available if that's where it was
+	if d.HasChange("clean_trigger") && d.Get("clean_trigger").(string) != "" {
+		wasAvailable := d.Get("provision_state").(string) == "available"
+		if err := cleanNode(provisionCtx, api, d, false); err != nil {
+			return diag.FromErr(err)
+		}
+		if wasAvailable {
+			if err := ChangeProvisionStateToTarget(provisionCtx, api, d.Id(), "provide", nil, nil, nil); err != nil {
+				return diag.Errorf("could not make node available: %s", err)
+			}
+		}
+	}
+
+	// Make node available
+	if d.HasChange("available") && d.Get("available").(bool) {
+		if err := ChangeProvisionStateToTarget(provisionCtx, api, d.Id(), "provide", nil, nil, nil); err != nil {
+			return diag.Errorf("could not make node available: %s", err)
+		}
+		progress.reach("available")
+	}
+
+	progress.finish()
+
+	return resourceNodeV1Read(ctx, d, meta)
+}
+
+// Delete a node from Ironic
+func resourceNodeV1Delete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	provisionCtx, cancel := provisionContext(ctx, d, schema.TimeoutDelete)
+	defer cancel()
+
+	if err := powerOffNode(ctx, api, d); err != nil {
+		return diag.Errorf("could not power off node before deleting it: %s", err)
+	}
+
+	// Retired nodes are decommissioned rather than deleted, so they stay enrolled once they're undeployed
+	node, err := getExtendedNode(api, d.Id())
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if node.Retired {
+		log.Printf("[DEBUG] Node %s is retired, undeploying it but leaving it enrolled", d.Id())
+		return diag.FromErr(ChangeProvisionStateToTarget(provisionCtx, api, d.Id(), "deleted", nil, nil, nil))
+	}
+
+	forceDelete := d.Get("force_delete").(bool)
+	for attempt := 1; ; attempt++ {
+		err = deleteNode(provisionCtx, api, d.Id())
+
+		var conflict gophercloud.ErrDefault409
+		if !forceDelete || !errors.As(err, &conflict) || attempt == forceDeleteAttempts {
+			return diag.FromErr(err)
+		}
+
+		// The node may be locked by a conductor that went away, toggling maintenance lets Ironic clear the
+		// reservation.
+		log.Printf("[WARN] Node %s is locked, toggling maintenance to clear the reservation (attempt %d of %d)", d.Id(), attempt, forceDeleteAttempts)
+		if err := toggleMaintenance(ctx, api, d.Id()); err != nil {
+			log.Printf("[WARN] Could not toggle maintenance on node %s: %s", d.Id(), err)
+		}
+	}
+}
+
+// powerOffNode powers the node off before it's deleted, if power_off_on_delete is set, so decommissioned hardware
+// doesn't stay on.
+func powerOffNode(ctx context.Context, api IronicClient, d *schema.ResourceData) error {
+	mode := d.Get("power_off_on_delete").(string)
+	if mode == "" {
+		return nil
+	}
+
+	var node nodes.Node
+	if err := api.GetNode(d.Id(), &node); err != nil {
+		return err
+	}
+	if normalizePowerState(node.PowerState) == string(nodes.PowerOff) {
+		return nil
+	}
+
+	target := nodes.PowerOff
+	if mode == "soft" {
+		target = nodes.SoftPowerOff
+	}
+	log.Printf("[DEBUG] Node %s is '%s', changing power state to '%s' before deleting it", d.Id(), node.PowerState, target)
+	return changePowerState(ctx, api, d, target)
+}
+
+// forceDeleteAttempts is how many times we try to delete a locked node when force_delete is set.
+const forceDeleteAttempts = 3
+
+// deleteNode drives the node to a deletable state, and removes it from Ironic.
+func deleteNode(ctx context.Context, api IronicClient, uuid string) error {
+	if err := ChangeProvisionStateToTarget(ctx, api, uuid, "deleted", nil, nil, nil); err != nil {
+		return err
+	}
+
+	return newNodeError(uuid, api.DeleteNode(uuid), "", "")
+}
+
+// drainNode takes a node out of service: it's undeployed if it's active, powered off, and put in maintenance with
+// drain_reason. Each step waits for Ironic to finish, the undeploy within provisionCtx, and the power off within the
+// node's power_state_timeout.
+func drainNode(ctx, provisionCtx context.Context, api IronicClient, d *schema.ResourceData) error {
+	var node nodes.Node
+	if err := api.GetNode(d.Id(), &node); err != nil {
+		return err
+	}
+
+	if node.ProvisionState == "active" {
+		log.Printf("[DEBUG] Undeploying node %s before draining it", d.Id())
+		if err := ChangeProvisionStateToTarget(provisionCtx, api, d.Id(), "deleted", nil, nil, nil); err != nil {
+			return fmt.Errorf("could not undeploy: %s", err)
+		}
+		if err := api.GetNode(d.Id(), &node); err != nil {
+			return err
+		}
+	}
+
+	if normalizePowerState(node.PowerState) != string(nodes.PowerOff) {
+		log.Printf("[DEBUG] Node %s is '%s', powering it off before draining it", d.Id(), node.PowerState)
+		if err := changePowerState(ctx, api, d, nodes.PowerOff); err != nil {
+			return fmt.Errorf("could not power off: %s", err)
+		}
+	}
+
+	_, err := UpdateNode(ctx, api, d.Id(), nodes.UpdateOpts{
+		nodes.UpdateOperation{Op: nodes.ReplaceOp, Path: "/maintenance", Value: true},
+		stringFieldUpdateOp("maintenance_reason", d.Get("drain_reason").(string)),
+	})
+	if err != nil {
+		return fmt.Errorf("could not put the node in maintenance: %s", err)
+	}
+	return nil
+}
+
+// updateNodeInMaintenance applies the patch with the node in maintenance, so Ironic doesn't act on the node while it's
+// changing, e.g. by syncing its power state through a new power interface. Maintenance is cleared once the patch is
+// applied. If it fails, the node is left in maintenance with the error as the reason, for an operator to investigate.
+func updateNodeInMaintenance(ctx context.Context, api IronicClient, uuid string, opts nodes.UpdateOpts) error {
+	log.Printf("[DEBUG] Putting node %s in maintenance while it's updated", uuid)
+	_, err := UpdateNode(ctx, api, uuid, nodes.UpdateOpts{
+		nodes.UpdateOperation{Op: nodes.ReplaceOp, Path: "/maintenance", Value: true},
+		stringFieldUpdateOp("maintenance_reason", "Being updated by Terraform"),
+	})
+	if err != nil {
+		return fmt.Errorf("could not put node %s in maintenance before updating it: %s", uuid, err)
+	}
+
+	if _, err := UpdateNode(ctx, api, uuid, opts); err != nil {
+		reason := fmt.Sprintf("Terraform could not update the node: %s", err)
+		if _, reasonErr := UpdateNode(ctx, api, uuid, nodes.UpdateOpts{stringFieldUpdateOp("maintenance_reason", reason)}); reasonErr != nil {
+			log.Printf("[WARN] Could not set the maintenance reason of node %s: %s", uuid, reasonErr)
+		}
+		return fmt.Errorf("%s, the node was left in maintenance", err)
+	}
+
+	log.Printf("[DEBUG] Node %s was updated, taking it out of maintenance", uuid)
+	_, err = UpdateNode(ctx, api, uuid, nodes.UpdateOpts{
+		nodes.UpdateOperation{Op: nodes.ReplaceOp, Path: "/maintenance", Value: false},
+		nodes.UpdateOperation{Op: nodes.RemoveOp, Path: "/maintenance_reason"},
+	})
 	if err != nil {
+		return fmt.Errorf("node %s was updated, but could not be taken out of maintenance: %s", uuid, err)
+	}
+	return nil
+}
+
+// toggleMaintenance sets and then clears maintenance on a node.
+func toggleMaintenance(ctx context.Context, api IronicClient, uuid string) error {
+	for _, maintenance := range []bool{true, false} {
+		if _, err := UpdateNode(ctx, api, uuid, nodes.UpdateOpts{
+			nodes.UpdateOperation{
+				Op:    nodes.ReplaceOp,
+				Path:  "/maintenance",
+				Value: maintenance,
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stringFieldUpdateOp builds the patch operation for a changed string field. Replacing a field with an empty string
+// doesn't clear it on all Ironic versions, so removing a value from the config removes the field instead.
+func stringFieldUpdateOp(field, value string) nodes.UpdateOperation {
+	if value == "" {
+		return nodes.UpdateOperation{
+			Op:   nodes.RemoveOp,
+			Path: fmt.Sprintf("/%s", field),
+		}
+	}
+
+	return nodes.UpdateOperation{
+		Op:    nodes.ReplaceOp,
+		Path:  fmt.Sprintf("/%s", field),
+		Value: value,
+	}
+}
+
+// expandAutomatedClean converts automated_clean to Ironic's value, which is nil to use the conductor's setting.
+func expandAutomatedClean(value string) *bool {
+	if value == "" || value == "default" {
+		return nil
+	}
+	automatedClean := value == "true"
+	return &automatedClean
+}
+
+// flattenAutomatedClean converts Ironic's automated_clean to the resource's, where null is 'default'.
+func flattenAutomatedClean(value *bool) string {
+	if value == nil {
+		return "default"
+	}
+	return strconv.FormatBool(*value)
+}
+
+// automatedCleanUpdateOp builds the patch operation for automated_clean. Removing it resets Ironic's value to null, so
+// the conductor's setting is used again.
+func automatedCleanUpdateOp(value string) nodes.UpdateOperation {
+	automatedClean := expandAutomatedClean(value)
+	if automatedClean == nil {
+		return nodes.UpdateOperation{Op: nodes.RemoveOp, Path: "/automated_clean"}
+	}
+	return nodes.UpdateOperation{Op: nodes.ReplaceOp, Path: "/automated_clean", Value: *automatedClean}
+}
+
+// driverInfoFieldChanges returns the prior and planned values of the first-class driver_info fields, by the
+// driver_info key they're stored in. Keys no field sets have an empty value.
+func driverInfoFieldChanges(d *schema.ResourceData) (map[string]string, map[string]string) {
+	oldValues, newValues := make(map[string]string), make(map[string]string)
+	for _, key := range driverInfoFields {
+		oldValues[key], newValues[key] = "", ""
+	}
+	for field, key := range driverInfoFields {
+		o, n := d.GetChange(field)
+		if value := o.(string); value != "" {
+			oldValues[key] = value
+		}
+		if value := n.(string); value != "" {
+			newValues[key] = value
+		}
+	}
+	return oldValues, newValues
+}
+
+// priorValues collects the values in state of the changed fields, and driver_info keys, keyed by their patch path.
+func priorValues(d *schema.ResourceData, fields []string) map[string]interface{} {
+	prior := make(map[string]interface{})
+	for _, field := range fields {
+		if !d.HasChange(field) {
+			continue
+		}
+		old, _ := d.GetChange(field)
+		if field == "automated_clean" {
+			// Compare with Ironic's value, where the default is null
+			if value := expandAutomatedClean(old.(string)); value != nil {
+				old = *value
+			} else {
+				old = nil
+			}
+		}
+		prior[fmt.Sprintf("/%s", field)] = old
+	}
+	oldDriverInfo, newDriverInfo := driverInfoFieldChanges(d)
+	for key, value := range oldDriverInfo {
+		if value != newDriverInfo[key] {
+			prior[fmt.Sprintf("/driver_info/%s", key)] = value
+		}
+	}
+	for _, field := range []string{"driver_info", "extra"} {
+		if !d.HasChange(field) {
+			continue
+		}
+		o, _ := d.GetChange(field)
+		for k, v := range o.(map[string]interface{}) {
+			prior[fmt.Sprintf("/%s/%s", field, escapePatchPath(k))] = v
+		}
+	}
+	return prior
+}
+
+// verifyNodeOwnership re-reads the node and makes sure its owner and lessee have the expected values after changing
+// them. Ironic's RBAC policy can leave them unchanged, which would otherwise leave the state out of sync with Ironic.
+func verifyNodeOwnership(api IronicClient, uuid string, expected map[string]string) error {
+	node, err := getExtendedNode(api, uuid)
+	if err != nil {
+		return fmt.Errorf("could not verify the ownership of node %s: %s", uuid, err)
+	}
+
+	actual := map[string]string{"owner": node.Owner, "lessee": node.Lessee}
+	for _, field := range []string{"owner", "lessee"} {
+		value, ok := expected[field]
+		if ok && actual[field] != value {
+			return fmt.Errorf("the %s of node %s is %q rather than %q after updating it, Ironic's policy may not allow the change", field, uuid, actual[field], value)
+		}
+	}
+	return nil
+}
+
+// checkPriorValues makes sure the node still has the values we last saw, before we overwrite them. Ironic doesn't
+// accept JSON patch test operations, so this is checked before the update rather than as part of it.
+func checkPriorValues(api IronicClient, uuid string, prior map[string]interface{}) error {
+	body := make(map[string]interface{})
+	if err := api.GetNode(uuid, &body); err != nil {
 		return err
 	}
 
-	d.Partial(true)
+	if changed := changedPriorValues(body, prior); len(changed) > 0 {
+		return fmt.Errorf("node %s was changed outside of terraform (%s), refresh and try again", uuid, strings.Join(changed, ", "))
+	}
+
+	return nil
+}
+
+// changedPriorValues returns the sorted patch paths where the node no longer has the prior value. Unset values in
+// Ironic match empty ones in state, and masked values can't be compared so they always match.
+func changedPriorValues(node map[string]interface{}, prior map[string]interface{}) []string {
+	var changed []string
+	for path, expected := range prior {
+		var actual interface{} = node
+		for _, part := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+			part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+			if m, ok := actual.(map[string]interface{}); ok {
+				actual = m[part]
+			} else {
+				actual = nil
+			}
+		}
+
+		if actual == "******" {
+			continue
+		}
+		if actual == nil && (expected == "" || expected == false) {
+			continue
+		}
+		if fmt.Sprint(actual) != fmt.Sprint(expected) {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// sensitiveKeyParts are the parts of a driver_info key that mark it as a secret Ironic masks.
+var sensitiveKeyParts = []string{"password", "passphrase", "private_key", "secret", "token"}
+
+// unmaskedSecrets returns the sorted driver_info keys that look like secrets, but that Ironic returned without the
+// masking sentinel. This happens when the show_password policy is enabled.
+func unmaskedSecrets(driverInfo map[string]interface{}) []string {
+	var secrets []string
+	for key, value := range driverInfo {
+		if value == "******" || value == "" || value == nil {
+			continue
+		}
+		for _, part := range sensitiveKeyParts {
+			if strings.Contains(strings.ToLower(key), part) {
+				secrets = append(secrets, key)
+				break
+			}
+		}
+	}
+	sort.Strings(secrets)
+	return secrets
+}
+
+// mapFieldUpdateOpts builds per-key patch operations for a changed map field, rather than replacing the whole map.
+// Masked values (e.g. passwords in driver_info) are never sent back to Ironic.
+func mapFieldUpdateOpts(d *schema.ResourceData, field string) (opts nodes.UpdateOpts) {
+	if !d.HasChange(field) {
+		return nil
+	}
+
+	o, n := d.GetChange(field)
+	oldMap := o.(map[string]interface{})
+	newMap := n.(map[string]interface{})
+
+	keys := make([]string, 0, len(newMap))
+	for k := range newMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := newMap[k]
+		if old, ok := oldMap[k]; (ok && old == v) || v == "******" {
+			continue
+		}
+		opts = append(opts, nodes.UpdateOperation{
+			Op:    nodes.AddOp,
+			Path:  fmt.Sprintf("/%s/%s", field, escapePatchPath(k)),
+			Value: v,
+		})
+	}
+
+	keys = keys[:0]
+	for k := range oldMap {
+		if _, ok := newMap[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		opts = append(opts, nodes.UpdateOperation{
+			Op:   nodes.RemoveOp,
+			Path: fmt.Sprintf("/%s/%s", field, escapePatchPath(k)),
+		})
+	}
+
+	return opts
+}
+
+// managedExtra returns the keys of the node's extra that are managed by the config, so keys other tools write to it
+// neither cause a diff nor are removed.
+func managedExtra(managed, actual map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	for k := range managed {
+		if v, ok := actual[k]; ok {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// escapePatchPath escapes a key for use in a JSON pointer, as described in RFC 6901.
+func escapePatchPath(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+func propertiesMerge(d *schema.ResourceData, key string) map[string]interface{} {
+	properties := d.Get("properties").(map[string]interface{})
+	properties[key] = d.Get(key).(map[string]interface{})
+	if secureBoot, ok := d.GetOkExists("secure_boot"); ok {
+		capabilities, _ := properties["capabilities"].(string)
+		properties["capabilities"] = setCapability(capabilities, "secure_boot", strconv.FormatBool(secureBoot.(bool)))
+	}
+	return properties
+}
+
+// nodeBootMode returns the node's boot mode, whether its secure_boot capability is set, and whether secure boot is
+// currently on. The boot mode and current secure boot come from the node's current state if Ironic reports it, and
+// otherwise from its capabilities. The secure_boot capability is removed from properties either way.
+func nodeBootMode(properties map[string]interface{}, state *nodeBootState) (bootMode string, secureBoot, currentSecureBoot bool) {
+	capabilities, _ := properties["capabilities"].(string)
+	bootMode, _ = popCapability(capabilities, "boot_mode")
+	secureBoot = popSecureBoot(properties)
+	currentSecureBoot = secureBoot
+	if state != nil && state.BootMode != nil {
+		bootMode = *state.BootMode
+	}
+	if state != nil && state.SecureBoot != nil {
+		currentSecureBoot = *state.SecureBoot
+	}
+	return bootMode, secureBoot, currentSecureBoot
+}
+
+// popSecureBoot returns whether the secure_boot capability is set in the node's properties, and removes it from them,
+// so it's only tracked by the secure_boot field. The capabilities are dropped if that was the only one.
+func popSecureBoot(properties map[string]interface{}) bool {
+	capabilities, ok := properties["capabilities"].(string)
+	if !ok {
+		return false
+	}
+	value, rest := popCapability(capabilities, "secure_boot")
+	if rest == "" {
+		delete(properties, "capabilities")
+	} else {
+		properties["capabilities"] = rest
+	}
+	return value == "true"
+}
+
+// popCapability returns the value of key in a node's capabilities, e.g. "boot_mode:uefi,secure_boot:true", along with
+// the rest of the capabilities.
+func popCapability(capabilities, key string) (value string, rest string) {
+	var others []string
+	for _, capability := range strings.Split(capabilities, ",") {
+		parts := strings.SplitN(strings.TrimSpace(capability), ":", 2)
+		if parts[0] == "" {
+			continue
+		}
+		if parts[0] == key && len(parts) == 2 {
+			value = parts[1]
+			continue
+		}
+		others = append(others, strings.TrimSpace(capability))
+	}
+	return value, strings.Join(others, ",")
+}
+
+// setCapability sets key in a node's capabilities, leaving the others in the order they were in.
+func setCapability(capabilities, key, value string) string {
+	_, rest := popCapability(capabilities, key)
+	if rest == "" {
+		return key + ":" + value
+	}
+	return rest + "," + key + ":" + value
+}
+
+// normalizeRootDeviceHints converts the root device hints returned by Ironic to the strings stored in the state. Ironic
+// may return hints such as size as numbers, which would otherwise never match the configured value.
+func normalizeRootDeviceHints(hints map[string]interface{}) map[string]interface{} {
+	if hints == nil {
+		return nil
+	}
+
+	normalized := make(map[string]interface{}, len(hints))
+	for key, value := range hints {
+		switch v := value.(type) {
+		case string:
+			normalized[key] = v
+		case float64:
+			normalized[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			normalized[key] = strconv.FormatBool(v)
+		default:
+			normalized[key] = fmt.Sprint(v)
+		}
+	}
+	return normalized
+}
+
+// fakeHardwareInterfaces are the interfaces a fake-hardware node gets when it doesn't set them. Ironic may otherwise
+// pick interfaces that need real hardware, if they're enabled and fake-hardware supports them.
+var fakeHardwareInterfaces = map[string]string{
+	"boot_interface":       "fake",
+	"deploy_interface":     "fake",
+	"management_interface": "fake",
+	"power_interface":      "fake",
+}
+
+// Convert terraform schema to gophercloud CreateOpts, using the provider's defaults for fields the node doesn't set
+// TODO: Is there a better way to do this? Annotations?
+func schemaToCreateOpts(d *schema.ResourceData, defaults map[string]string) *nodes.CreateOpts {
+	get := func(field string) string {
+		if value := d.Get(field).(string); value != "" {
+			return value
+		}
+		if value := defaults[field]; value != "" {
+			return value
+		}
+		if d.Get("driver").(string) == "fake-hardware" {
+			return fakeHardwareInterfaces[field]
+		}
+		return ""
+	}
+
+	properties := propertiesMerge(d, "root_device")
+	driverInfo := d.Get("driver_info").(map[string]interface{})
+	for field, key := range driverInfoFields {
+		if value := d.Get(field).(string); value != "" {
+			driverInfo[key] = value
+		}
+	}
+	if fastTrack, ok := d.GetOkExists("fast_track"); ok {
+		driverInfo["fast_track"] = strconv.FormatBool(fastTrack.(bool))
+	}
+	// The provider's default networks only apply to nodes on neutron, and never replace a network the node sets
+	if get("network_interface") == "neutron" {
+		for _, key := range nodeDefaultNetworkFields {
+			if _, ok := driverInfo[key]; !ok && defaults[key] != "" {
+				driverInfo[key] = defaults[key]
+			}
+		}
+	}
+	opts := nodes.CreateOpts{
+		BootInterface:       get("boot_interface"),
+		ConductorGroup:      d.Get("conductor_group").(string),
+		ConsoleInterface:    get("console_interface"),
+		DeployInterface:     get("deploy_interface"),
+		Driver:              d.Get("driver").(string),
+		DriverInfo:          driverInfo,
+		Extra:               d.Get("extra").(map[string]interface{}),
+		InspectInterface:    get("inspect_interface"),
+		ManagementInterface: get("management_interface"),
+		Name:                d.Get("name").(string),
+		NetworkInterface:    get("network_interface"),
+		Owner:               d.Get("owner").(string),
+		PowerInterface:      get("power_interface"),
+		Properties:          properties,
+		RAIDInterface:       get("raid_interface"),
+		RescueInterface:     get("rescue_interface"),
+		ResourceClass:       get("resource_class"),
+		StorageInterface:    get("storage_interface"),
+		VendorInterface:     get("vendor_interface"),
+	}
+
+	opts.AutomatedClean = expandAutomatedClean(d.Get("automated_clean").(string))
+
+	return &opts
+}
+
+// listNodePorts returns all of the ports belonging to a node.
+// flattenPorts converts ports to the computed port list, including every port of the node.
+func flattenPorts(nodePorts []ports.Port) []interface{} {
+	result := make([]interface{}, 0, len(nodePorts))
+	for _, port := range nodePorts {
+		localLinkConnection := make(map[string]interface{})
+		for k, v := range port.LocalLinkConnection {
+			localLinkConnection[k] = fmt.Sprint(v)
+		}
+
+		result = append(result, map[string]interface{}{
+			"uuid":                  port.UUID,
+			"address":               port.Address,
+			"pxe_enabled":           port.PXEEnabled,
+			"local_link_connection": localLinkConnection,
+		})
+	}
+	return result
+}
+
+// Import a node, adopting its existing ports as inline ports so the next plan doesn't try to delete them
+func resourceNodeV1Import(ctx context.Context, d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	api, err := meta.(*Clients).GetIronicAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodePorts, err := api.ListNodePorts(d.Id())
+	if err != nil {
+		return nil, fmt.Errorf("could not list ports of node %s: %s", d.Id(), err)
+	}
+
+	portUUIDs := make(map[string]interface{})
+	for _, port := range nodePorts {
+		portUUIDs[port.Address] = port.UUID
+	}
+	if err := d.Set("ports", inlinePorts(nodePorts)); err != nil {
+		return nil, err
+	}
+	if err := d.Set("port_uuids", portUUIDs); err != nil {
+		return nil, err
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
 
-	stringFields := []string{
-		"boot_interface",
-		"conductor_group",
-		"console_interface",
-		"deploy_interface",
-		"driver",
-		"inspect_interface",
-		"management_interface",
-		"name",
-		"network_interface",
-		"owner",
-		"power_interface",
-		"raid_interface",
-		"rescue_interface",
-		"resource_class",
-		"storage_interface",
-		"vendor_interface",
+// inlinePorts converts ports to the entries of the inline ports set, which only holds strings.
+func inlinePorts(nodePorts []ports.Port) []interface{} {
+	result := make([]interface{}, 0, len(nodePorts))
+	for _, port := range nodePorts {
+		inline := map[string]interface{}{
+			"address":     port.Address,
+			"pxe_enabled": strconv.FormatBool(port.PXEEnabled),
+		}
+		if port.PhysicalNetwork != "" {
+			inline["physical_network"] = port.PhysicalNetwork
+		}
+		result = append(result, inline)
 	}
+	return result
+}
 
-	for _, field := range stringFields {
-		if d.HasChange(field) {
-			opts := nodes.UpdateOpts{
-				nodes.UpdateOperation{
-					Op:    nodes.ReplaceOp,
-					Path:  fmt.Sprintf("/%s", field),
-					Value: d.Get(field).(string),
-				},
-			}
+// updateNodeTraits adds the traits in desired the node doesn't have, and removes the ones it has that aren't desired.
+func updateNodeTraits(api IronicClient, uuid string, current, desired []interface{}) error {
+	have, want := make(map[string]bool), make(map[string]bool)
+	for _, trait := range current {
+		have[trait.(string)] = true
+	}
+	for _, trait := range desired {
+		want[trait.(string)] = true
+	}
 
-			if _, err := UpdateNode(client, d.Id(), opts); err != nil {
-				return err
-			}
+	var add, remove []string
+	for trait := range want {
+		if !have[trait] {
+			add = append(add, trait)
+		}
+	}
+	for trait := range have {
+		if !want[trait] {
+			remove = append(remove, trait)
+		}
+	}
+	sort.Strings(add)
+	sort.Strings(remove)
+
+	for _, trait := range remove {
+		log.Printf("[DEBUG] Removing trait %s from node %s", trait, uuid)
+		err := api.RemoveTrait(uuid, trait)
+		if _, ok := err.(gophercloud.ErrDefault404); err != nil && !ok {
+			return fmt.Errorf("could not remove trait %s from node %s: %s", trait, uuid, err)
+		}
+	}
+	for _, trait := range add {
+		log.Printf("[DEBUG] Adding trait %s to node %s", trait, uuid)
+		if err := api.AddTrait(uuid, trait); err != nil {
+			return fmt.Errorf("could not add trait %s to node %s: %s", trait, uuid, err)
 		}
 	}
+	return nil
+}
 
-	// Make node manageable
-	if (d.HasChange("manage") && d.Get("manage").(bool)) ||
-		(d.HasChange("clean") && d.Get("clean").(bool)) ||
-		(d.HasChange("inspect") && d.Get("inspect").(bool)) {
-		if err := ChangeProvisionStateToTarget(client, d.Id(), "manage", nil, nil, nil); err != nil {
-			return fmt.Errorf("could not manage: %s", err)
+// createNodePort creates one of the node's inline ports.
+func createNodePort(api IronicClient, nodeUUID string, port map[string]interface{}) (*ports.Port, error) {
+	// Terraform map can't handle bool... seriously.
+	var pxeEnabled bool
+	if port["pxe_enabled"] != nil {
+		if port["pxe_enabled"] == "true" {
+			pxeEnabled = true
+		} else {
+			pxeEnabled = false
 		}
+
+	}
+	// FIXME: All values other than address, pxe and physical network
+	physicalNetwork, _ := port["physical_network"].(string)
+	portCreateOpts := ports.CreateOpts{
+		NodeUUID:        nodeUUID,
+		Address:         port["address"].(string),
+		PXEEnabled:      &pxeEnabled,
+		PhysicalNetwork: physicalNetwork,
 	}
+	return api.CreatePort(portCreateOpts)
+}
 
-	// Update power state if required
-	if targetPowerState := d.Get("target_power_state").(string); d.HasChange("target_power_state") && targetPowerState != "" {
-		if err := changePowerState(client, d, nodes.TargetPowerState(targetPowerState)); err != nil {
+// updateNodePorts deletes the inline ports removed from the configuration, and creates the ones added. A changed port
+// is both removed and added, so it's recreated.
+func updateNodePorts(ctx context.Context, api IronicClient, d *schema.ResourceData) error {
+	o, n := d.GetChange("ports")
+	oldPorts, newPorts := o.(*schema.Set), n.(*schema.Set)
+
+	portUUIDs := make(map[string]interface{})
+	for address, uuid := range d.Get("port_uuids").(map[string]interface{}) {
+		portUUIDs[address] = uuid
+	}
+	defer func() { _ = d.Set("port_uuids", portUUIDs) }()
+
+	for address, uuid := range removedPortUUIDs(oldPorts.Difference(newPorts).List(), portUUIDs) {
+		log.Printf("[DEBUG] Deleting port %s (%s) of node %s", uuid, address, d.Id())
+		err := api.DeletePort(uuid)
+		if _, ok := err.(gophercloud.ErrDefault404); err != nil && !ok {
 			return err
 		}
+		delete(portUUIDs, address)
 	}
 
-	// Clean node
-	if d.HasChange("clean") && d.Get("clean").(bool) {
-		if err := ChangeProvisionStateToTarget(client, d.Id(), "clean", nil, nil, nil); err != nil {
-			return fmt.Errorf("could not clean: %s", err)
-		}
+	return createNodePorts(ctx, newPorts.Difference(oldPorts).List(), portUUIDs, api.RetryJitter(), func(port map[string]interface{}) (*ports.Port, error) {
+		return createNodePort(api, d.Id(), port)
+	})
+}
+
+// portCreateWorkers is how many inline ports are created at once.
+const portCreateWorkers = 4
+
+// createNodePorts creates the inline ports concurrently with create, retrying each one while Ironic is busy, and
+// records the UUID of every port created in portUUIDs by MAC address. Ports that fail don't stop the others from being
+// created, the error names the MAC address of each one that failed.
+func createNodePorts(ctx context.Context, portList []interface{}, portUUIDs map[string]interface{}, jitter bool, create func(map[string]interface{}) (*ports.Port, error)) error {
+	errs := make([]error, len(portList))
+	indexes := make(chan int)
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < portCreateWorkers && w < len(portList); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				port := portList[i].(map[string]interface{})
+				var result *ports.Port
+				err := retryOnConflict(ctx, jitter, "create port", func() (err error) {
+					result, err = create(port)
+					return err
+				})
+				if err != nil {
+					errs[i] = fmt.Errorf("could not create port %v: %s", port["address"], err)
+					continue
+				}
+				mux.Lock()
+				portUUIDs[result.Address] = result.UUID
+				mux.Unlock()
+			}
+		}()
+	}
+	for i := range portList {
+		indexes <- i
 	}
+	close(indexes)
+	wg.Wait()
 
-	// Inspect node
-	if d.HasChange("inspect") && d.Get("inspect").(bool) {
-		if err := ChangeProvisionStateToTarget(client, d.Id(), "inspect", nil, nil, nil); err != nil {
-			return fmt.Errorf("could not inspect: %s", err)
+	var failures []string
+	for _, err := range errs {
+		if err != nil {
+			failures = append(failures, err.Error())
 		}
 	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return nil
+}
 
-	// Make node available
-	if d.HasChange("available") && d.Get("available").(bool) {
-		if err := ChangeProvisionStateToTarget(client, d.Id(), "provide", nil, nil, nil); err != nil {
-			return fmt.Errorf("could not make node available: %s", err)
+// removedPortUUIDs returns the UUIDs, by MAC address, of removed inline ports that we created. Ports we don't have a
+// record of were created outside of Terraform, so they're left alone.
+func removedPortUUIDs(removed []interface{}, recorded map[string]interface{}) map[string]string {
+	result := make(map[string]string)
+	for _, portInterface := range removed {
+		address, _ := portInterface.(map[string]interface{})["address"].(string)
+		uuid, ok := recorded[address].(string)
+		if !ok {
+			log.Printf("[WARN] Port %s was removed from the configuration, but wasn't created by Terraform, leaving it in place", address)
+			continue
 		}
+		result[address] = uuid
 	}
+	return result
+}
 
-	if d.HasChange("properties") || d.HasChange("root_device") {
-		properties := propertiesMerge(d, "root_device")
-		opts := nodes.UpdateOpts{
-			nodes.UpdateOperation{
-				Op:    nodes.AddOp,
-				Path:  "/properties",
-				Value: properties,
-			},
-		}
-		if _, err := UpdateNode(client, d.Id(), opts); err != nil {
-			return err
+// recordedPortUUIDs returns the ports, by MAC address, that were recorded as created by us and still exist.
+func recordedPortUUIDs(recorded map[string]interface{}, nodePorts []ports.Port) map[string]interface{} {
+	recordedUUIDs := make(map[string]bool)
+	for _, uuid := range recorded {
+		recordedUUIDs[uuid.(string)] = true
+	}
+
+	result := make(map[string]interface{})
+	for _, port := range nodePorts {
+		if recordedUUIDs[port.UUID] {
+			result[port.Address] = port.UUID
 		}
 	}
+	return result
+}
+
+// extendedNode holds the fields of a node that gophercloud's nodes.Node doesn't know about.
+type extendedNode struct {
+	nodes.Node
+
+	// A string or UUID of the tenant who is leasing the node.
+	Lessee string `json:"lessee"`
+
+	// The conductor currently servicing the node. Requires microversion 1.49 or later.
+	Conductor string `json:"conductor"`
 
-	d.Partial(false)
+	// Whether the node is retired, and why. Requires microversion 1.61 or later.
+	Retired       bool   `json:"retired"`
+	RetiredReason string `json:"retired_reason"`
 
-	return resourceNodeV1Read(d, meta)
+	// Free-form description of the node. Requires microversion 1.51 or later.
+	Description string `json:"description"`
+
+	// The allocation the node belongs to, which owns its instance_uuid. Requires microversion 1.52 or later.
+	AllocationUUID string `json:"allocation_uuid"`
+
+	// The shard the node is in, for sharded deployments. Requires microversion 1.82 or later.
+	Shard string `json:"shard"`
+
+	// When the provision state last changed, and when the last inspection started and finished, in ISO 8601.
+	ProvisionUpdatedAt   string `json:"provision_updated_at"`
+	InspectionStartedAt  string `json:"inspection_started_at"`
+	InspectionFinishedAt string `json:"inspection_finished_at"`
 }
 
-// Delete a node from Ironic
-func resourceNodeV1Delete(d *schema.ResourceData, meta interface{}) error {
-	client, err := meta.(*Clients).GetIronicClient()
+// setNodeInstanceFields sets the node's instance_uuid and allocation_uuid. They're owned by the allocation or
+// deployment the node belongs to, and Ironic clears both when the allocation is deleted, so they're only ever read.
+func setNodeInstanceFields(d *schema.ResourceData, node *extendedNode) error {
+	err := d.Set("instance_uuid", node.InstanceUUID)
 	if err != nil {
 		return err
 	}
+	return d.Set("allocation_uuid", node.AllocationUUID)
+}
 
-	if err := ChangeProvisionStateToTarget(client, d.Id(), "deleted", nil, nil, nil); err != nil {
-		return err
+// nodeConsoleURL returns the URL of the node's serial console, or an empty string if it's disabled or can't be read.
+func nodeConsoleURL(api IronicClient, uuid string, enabled bool) string {
+	if !enabled {
+		return ""
+	}
+	console, err := api.GetConsole(uuid)
+	if err != nil {
+		log.Printf("[WARN] Could not read the console of node %s: %s", uuid, err)
+		return ""
 	}
+	if !console.ConsoleEnabled || console.ConsoleInfo == nil {
+		return ""
+	}
+	return console.ConsoleInfo.URL
+}
 
-	return nodes.Delete(client, d.Id()).ExtractErr()
+// getExtendedNode fetches a node including the fields not present in nodes.Node.
+func getExtendedNode(api IronicClient, uuid string) (*extendedNode, error) {
+	var node extendedNode
+	if err := api.GetNode(uuid, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
 }
 
-func propertiesMerge(d *schema.ResourceData, key string) map[string]interface{} {
-	properties := d.Get("properties").(map[string]interface{})
-	properties[key] = d.Get(key).(map[string]interface{})
-	return properties
+// UpdateNode wraps gophercloud's update function, so we are able to retry on 409 when Ironic is busy.
+func UpdateNode(ctx context.Context, api IronicClient, uuid string, opts nodes.UpdateOpts) (node *nodes.Node, err error) {
+	err = retryOnConflict(ctx, api.RetryJitter(), "update node", func() (err error) {
+		node, err = api.UpdateNode(uuid, opts)
+		return
+	})
+
+	return node, newNodeError(uuid, forbiddenError(api, uuid, err), "", "")
 }
 
-// Convert terraform schema to gophercloud CreateOpts
-// TODO: Is there a better way to do this? Annotations?
-func schemaToCreateOpts(d *schema.ResourceData) *nodes.CreateOpts {
-	properties := propertiesMerge(d, "root_device")
-	return &nodes.CreateOpts{
-		BootInterface:       d.Get("boot_interface").(string),
-		ConductorGroup:      d.Get("conductor_group").(string),
-		ConsoleInterface:    d.Get("console_interface").(string),
-		DeployInterface:     d.Get("deploy_interface").(string),
-		Driver:              d.Get("driver").(string),
-		DriverInfo:          d.Get("driver_info").(map[string]interface{}),
-		Extra:               d.Get("extra").(map[string]interface{}),
-		InspectInterface:    d.Get("inspect_interface").(string),
-		ManagementInterface: d.Get("management_interface").(string),
-		Name:                d.Get("name").(string),
-		NetworkInterface:    d.Get("network_interface").(string),
-		Owner:               d.Get("owner").(string),
-		PowerInterface:      d.Get("power_interface").(string),
-		Properties:          properties,
-		RAIDInterface:       d.Get("raid_interface").(string),
-		RescueInterface:     d.Get("rescue_interface").(string),
-		ResourceClass:       d.Get("resource_class").(string),
-		StorageInterface:    d.Get("storage_interface").(string),
-		VendorInterface:     d.Get("vendor_interface").(string),
+// forbiddenError turns a 403 from Ironic into an error explaining who may operate on the node. Any other error is
+// returned unchanged.
+func forbiddenError(api IronicClient, uuid string, err error) error {
+	if _, ok := err.(gophercloud.ErrDefault403); !ok {
+		return err
+	}
+
+	node, getErr := getExtendedNode(api, uuid)
+	if getErr != nil {
+		return fmt.Errorf("permission denied on node %s, and the node could not be read to determine its owner or lessee: %w", uuid, err)
+	}
+
+	if project := api.ProjectID(); project != "" {
+		return fmt.Errorf("permission denied on node %s: the project the provider authenticated as (%q) must be the node's owner (%q) or lessee (%q): %w", uuid, project, node.Owner, node.Lessee, err)
 	}
+	return fmt.Errorf("permission denied on node %s: the provider isn't authenticated as a project, which must be the node's owner (%q) or lessee (%q): %w", uuid, node.Owner, node.Lessee, err)
 }
 
-// UpdateNode wraps gophercloud's update function, so we are able to retry on 409 when Ironic is busy.
-func UpdateNode(client *gophercloud.ServiceClient, uuid string, opts nodes.UpdateOpts) (node *nodes.Node, err error) {
-	interval := 5 * time.Second
-	for retries := 0; retries < 5; retries++ {
-		node, err = nodes.Update(client, uuid, opts).Extract()
-		if _, ok := err.(gophercloud.ErrDefault409); ok {
-			log.Printf("[DEBUG] Failed to update node: ironic is busy, will try again in %s", interval.String())
-			time.Sleep(interval)
-			interval *= 2
-		} else {
-			return
-		}
+// manageNode makes the node manageable, if it isn't already past that point. A node that's available or further
+// along is only stepped back to manageable when stepBack is set, as cleaning and inspection need, so setting manage
+// on such a node does nothing rather than failing.
+func manageNode(ctx context.Context, api IronicClient, uuid string, stepBack bool) error {
+	node, err := getExtendedNode(api, uuid)
+	if err != nil {
+		return err
 	}
+	if !manageTransitionNeeded(node.ProvisionState, stepBack) {
+		log.Printf("[DEBUG] Node %s is '%s', not making it manageable", uuid, node.ProvisionState)
+		return nil
+	}
+	return ChangeProvisionStateToTarget(ctx, api, uuid, "manage", nil, nil, nil)
+}
 
-	return
+// manageTransitionNeeded determines whether a node in the given provision state needs to be made manageable. Nodes
+// that haven't been managed yet, or failed to get past manageable, always do.
+func manageTransitionNeeded(provisionState string, stepBack bool) bool {
+	switch provisionState {
+	case "manageable":
+		return false
+	case "enroll", "verifying", "adopt failed", "clean failed", "inspect failed":
+		return true
+	default:
+		return stepBack
+	}
+}
+
+// provisionContext bounds the provision state changes of an operation on the resource by its timeout for that
+// operation. Power state changes have their own timeout, power_state_timeout, so they're given the original context.
+func provisionContext(ctx context.Context, d *schema.ResourceData, timeout string) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, d.Timeout(timeout))
 }
 
 // Call Ironic's API and change the power state of the node
-func changePowerState(client *gophercloud.ServiceClient, d *schema.ResourceData, target nodes.TargetPowerState) error {
+func changePowerState(ctx context.Context, api IronicClient, d *schema.ResourceData, target nodes.TargetPowerState) error {
 	opts := nodes.PowerStateOpts{
 		Target: target,
 	}
@@ -573,32 +2705,39 @@ func changePowerState(client *gophercloud.ServiceClient, d *schema.ResourceData,
 		timeout = 300 // used below for how long to wait for Ironic to finish
 	}
 
-	interval := 5 * time.Second
-	for retries := 0; retries < 5; retries++ {
-		err := nodes.ChangePowerState(client, d.Id(), opts).ExtractErr()
-		if _, ok := err.(gophercloud.ErrDefault409); ok {
-			log.Printf("[DEBUG] Failed to change power state: ironic is busy, will try again in %s", interval.String())
-			time.Sleep(interval)
-			interval *= 2
-		} else {
-			break
-		}
+	err := retryOnConflict(ctx, api.RetryJitter(), "change power state", func() error {
+		return api.ChangePowerState(d.Id(), opts)
+	})
+	if err != nil {
+		return newNodeError(d.Id(), forbiddenError(api, d.Id(), err), "", "")
 	}
 
 	// Wait for target_power_state to be empty, i.e. Ironic thinks it's finished
 	checkInterval := 5
 
 	for {
-		node, err := nodes.Get(client, d.Id()).Extract()
-		if err != nil {
+		var node nodes.Node
+		if err := api.GetNode(d.Id(), &node); err != nil {
 			return err
 		}
 
-		if node.TargetPowerState == "" {
+		if normalizePowerState(node.TargetPowerState) == "" {
+			// Ironic also clears the target when the power change failed
+			if expected := expectedPowerState(target); normalizePowerState(node.PowerState) != expected {
+				err := fmt.Errorf("node %s is '%s' rather than '%s' after changing its power state to '%s'", d.Id(), node.PowerState, expected, target)
+				if node.Fault != "" {
+					err = fmt.Errorf("%s, its fault is '%s'", err, node.Fault)
+				}
+				return newNodeError(d.Id(), err, "", node.LastError)
+			}
 			break
 		}
 
-		time.Sleep(time.Duration(checkInterval) * time.Second)
+		select {
+		case <-time.After(time.Duration(checkInterval) * time.Second):
+		case <-ctx.Done():
+			return fmt.Errorf("interrupted while waiting for node %s to reach power state '%s'", d.Id(), target)
+		}
 		timeout -= checkInterval
 		if timeout <= 0 {
 			return fmt.Errorf("timed out waiting for power state change")
@@ -608,8 +2747,338 @@ func changePowerState(client *gophercloud.ServiceClient, d *schema.ResourceData,
 	return nil
 }
 
+// normalizePowerState converts a power state reported by Ironic to the canonical form, e.g. "power on". Depending on
+// the version and driver, states may be reported in a different case, or with underscores or extra spaces.
+func normalizePowerState(state string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.NewReplacer("_", " ", "-", " ").Replace(state))), " ")
+}
+
+// expectedPowerState returns the power state a node ends up in once a change to the target power state succeeds.
+func expectedPowerState(target nodes.TargetPowerState) string {
+	switch target {
+	case nodes.PowerOff, nodes.SoftPowerOff:
+		return string(nodes.PowerOff)
+	default:
+		// Rebooting leaves the node powered on
+		return string(nodes.PowerOn)
+	}
+}
+
+// provisionMilestones are the provisioning flags, in the order they're applied.
+var provisionMilestones = []string{"manage", "clean", "inspect", "available"}
+
+// provisionProgress records what an operation on a node has applied, so if the operation fails or is interrupted only
+// that is saved. Everything else it changed is set back to its prior value, and applied again by the next apply. For
+// a new node, that's the provisioning flags which weren't applied, so once the node is untainted the next apply
+// resumes provisioning from the first of them.
+type provisionProgress struct {
+	d        *schema.ResourceData
+	applied  map[string]bool
+	finished bool
+}
+
+// trackProvisionProgress starts recording the progress of an operation on the node. A node that was just created has
+// everything applied but its provisioning flags.
+func trackProvisionProgress(d *schema.ResourceData, created bool) *provisionProgress {
+	progress := &provisionProgress{d: d, applied: map[string]bool{"provision_milestone": true}}
+	if created {
+		for field := range resourceNodeV1().Schema {
+			progress.applied[field] = true
+		}
+		for _, flag := range provisionMilestones {
+			progress.applied[flag] = false
+		}
+	}
+	return progress
+}
+
+// reach records that a provisioning flag has been applied, so it's saved even if the operation fails later on, and
+// isn't applied again.
+func (progress *provisionProgress) reach(milestone string) {
+	progress.applied[milestone] = true
+	_ = progress.d.Set("provision_milestone", milestone)
+}
+
+// apply records that the fields have been applied to the node, so they're saved even if the operation fails later on.
+func (progress *provisionProgress) apply(fields ...string) {
+	for _, field := range fields {
+		progress.applied[field] = true
+	}
+}
+
+// finish records that the operation has applied everything, so nothing is set back if it fails after that.
+func (progress *provisionProgress) finish() {
+	progress.finished = true
+}
+
+// revert sets every field the operation changed but didn't apply back to its prior value, it's called when the
+// operation fails.
+func (progress *provisionProgress) revert() {
+	if progress.finished {
+		return
+	}
+	for field := range resourceNodeV1().Schema {
+		if !progress.applied[field] && progress.d.HasChange(field) {
+			old, _ := progress.d.GetChange(field)
+			_ = progress.d.Set(field, old)
+		}
+	}
+}
+
+// cleanNode runs manual cleaning on the node, with the RAID and BIOS configuration from the resource, followed by its
+// extra clean steps. The node is left in the manageable state. With onlyChanged, only the configuration that differs
+// from what's applied to the node is cleaned with, and the node isn't cleaned at all if everything is already applied
+// and there are no extra steps, as cleaning takes hours.
+func cleanNode(ctx context.Context, api IronicClient, d *schema.ResourceData, onlyChanged bool) error {
+	extraSteps, err := nodeExtraCleanSteps(api, d)
+	if err != nil {
+		return err
+	}
+
+	raidConfig, biosSettings := d.Get("raid_config").(string), d.Get("bios_settings").(string)
+	if onlyChanged {
+		raidConfig, biosSettings, err = changedCleaningConfig(api, d)
+		if err != nil {
+			return fmt.Errorf("could not compare the RAID and BIOS configuration: %s", err)
+		}
+		if raidConfig == "" && biosSettings == "" && len(extraSteps) == 0 &&
+			(d.Get("raid_config").(string) != "" || d.Get("bios_settings").(string) != "") {
+			log.Printf("[DEBUG] Node %s already has its RAID configuration and BIOS settings, not cleaning it", d.Id())
+			return nil
+		}
+	}
+
+	if raidConfig != "" {
+		if err := setRAIDConfig(api, d); err != nil {
+			return fmt.Errorf("fail to set raid config: %s", err)
+		}
+	}
+
+	cleanSteps, err := buildManualCleaningSteps(d.Get("raid_interface").(string), raidConfig, biosSettings)
+	if err != nil {
+		return fmt.Errorf("fail to build raid clean steps: %s", err)
+	}
+	cleanSteps = append(cleanSteps, extraSteps...)
+
+	lastStep, err := changeProvisionStateWithLastStep(ctx, api, d.Id(), "clean", nil, nil, cleanSteps)
+	if lastStep != "" {
+		_ = d.Set("last_step", lastStep)
+	}
+	if err != nil {
+		return fmt.Errorf("could not clean: %s", err)
+	}
+
+	return nil
+}
+
+// nodeExtraCleanSteps builds the resource's clean_steps and reset_bmc, for the node's management interface. That's only
+// read from the node when it isn't in the configuration.
+func nodeExtraCleanSteps(api IronicClient, d *schema.ResourceData) ([]nodes.CleanStep, error) {
+	steps, resetBMC := d.Get("clean_steps").(string), d.Get("reset_bmc").(bool)
+	if steps == "" && !resetBMC {
+		return nil, nil
+	}
+
+	managementInterface := d.Get("management_interface").(string)
+	if managementInterface == "" {
+		node, err := getExtendedNode(api, d.Id())
+		if err != nil {
+			return nil, fmt.Errorf("could not get node %s: %s", d.Id(), err)
+		}
+		managementInterface = node.ManagementInterface
+	}
+	return buildExtraCleanSteps(managementInterface, steps, resetBMC)
+}
+
+// changedCleaningConfig returns the resource's raid_config and bios_settings, leaving out what's already applied to the
+// node. The BIOS settings are narrowed down to the ones that differ.
+func changedCleaningConfig(api IronicClient, d *schema.ResourceData) (raidConfig, biosSettings string, err error) {
+	if raidConfig = d.Get("raid_config").(string); raidConfig != "" {
+		var targetRAID *metal3v1alpha1.RAIDConfig
+		if err := json.Unmarshal([]byte(raidConfig), &targetRAID); err != nil {
+			return "", "", fmt.Errorf("could not parse raid_config: %s", err)
+		}
+		logicalDisks, err := ironic.BuildTargetRAIDCfg(targetRAID)
+		if err != nil {
+			return "", "", err
+		}
+		node, err := getExtendedNode(api, d.Id())
+		if err != nil {
+			return "", "", err
+		}
+		if raidConfigApplied(logicalDisks, node.RAIDConfig) {
+			raidConfig = ""
+		}
+	}
+
+	if biosSettings = d.Get("bios_settings").(string); biosSettings != "" {
+		var desired []map[string]string
+		if err := json.Unmarshal([]byte(biosSettings), &desired); err != nil {
+			return "", "", fmt.Errorf("could not parse bios_settings: %s", err)
+		}
+		actual, err := api.ListBIOSSettings(d.Id())
+		if err != nil {
+			log.Printf("[WARN] Could not read the BIOS settings of node %s, applying all of them: %s", d.Id(), err)
+			return raidConfig, biosSettings, nil
+		}
+		_, apply := biosSettingsDrift(desired, actual)
+		if len(apply) == 0 {
+			return raidConfig, "", nil
+		}
+		b, err := json.Marshal(apply)
+		if err != nil {
+			return "", "", err
+		}
+		biosSettings = string(b)
+	}
+
+	return raidConfig, biosSettings, nil
+}
+
+// raidConfigApplied determines if the node's current RAID configuration has the desired logical disks, in the same
+// order and with the same RAID levels and sizes. Disks of the maximum size match any size.
+func raidConfigApplied(desired []nodes.LogicalDisk, current map[string]interface{}) bool {
+	disks, _ := current["logical_disks"].([]interface{})
+	if len(disks) != len(desired) {
+		return false
+	}
+	for i, disk := range disks {
+		actual, _ := disk.(map[string]interface{})
+		if fmt.Sprint(actual["raid_level"]) != string(desired[i].RAIDLevel) {
+			return false
+		}
+		if desired[i].SizeGB != nil && fmt.Sprint(actual["size_gb"]) != strconv.Itoa(*desired[i].SizeGB) {
+			return false
+		}
+	}
+	return true
+}
+
+// configCleanCycle determines how changed configuration, such as the RAID configuration, is applied to a node in the
+// given provision state. It's applied by cleaning, and then an available node is made available again. Nodes that
+// haven't been managed yet get the configuration when they're first cleaned.
+func configCleanCycle(config, provisionState string) (clean, provide bool, err error) {
+	switch provisionState {
+	case "available":
+		return true, true, nil
+	case "manageable":
+		return true, false, nil
+	case "active":
+		return false, false, fmt.Errorf("the node is active, it must be undeployed before its %s can be changed", config)
+	default:
+		log.Printf("[DEBUG] Node is '%s', its %s will be applied when it's cleaned", provisionState, config)
+		return false, false, nil
+	}
+}
+
+// biosSettingsDrift compares the desired BIOS settings with those Ironic has cached for the node. It returns the
+// desired settings with the node's actual values, and the settings that need to be applied. Read-only settings can't
+// be applied, so they're reported as desired. Settings Ironic doesn't know about are assumed to match when reading,
+// but are still applied.
+func biosSettingsDrift(desired []map[string]string, actual []nodes.BIOSSetting) (current, apply []map[string]string) {
+	actualSettings := make(map[string]nodes.BIOSSetting)
+	for _, setting := range actual {
+		actualSettings[setting.Name] = setting
+	}
+
+	for _, setting := range desired {
+		name, value := setting["name"], setting["value"]
+		actualSetting, ok := actualSettings[name]
+		switch {
+		case !ok:
+			current = append(current, setting)
+			apply = append(apply, setting)
+		case actualSetting.Value == value:
+			current = append(current, setting)
+		case actualSetting.ReadOnly != nil && *actualSetting.ReadOnly:
+			log.Printf("[WARN] BIOS setting %s is read-only, it can't be changed from '%s' to '%s'", name, actualSetting.Value, value)
+			current = append(current, setting)
+		default:
+			current = append(current, map[string]string{"name": name, "value": actualSetting.Value})
+			apply = append(apply, setting)
+		}
+	}
+	return current, apply
+}
+
+// readBIOSSettings reads back the node's actual values for the desired BIOS settings, so drift shows up in the plan.
+// The state is left alone when nothing drifted, keeping the configuration's formatting.
+func readBIOSSettings(api IronicClient, d *schema.ResourceData) error {
+	biosSettings := d.Get("bios_settings").(string)
+	if biosSettings == "" {
+		return nil
+	}
+
+	var desired []map[string]string
+	if err := json.Unmarshal([]byte(biosSettings), &desired); err != nil {
+		return nil
+	}
+
+	actual, err := api.ListBIOSSettings(d.Id())
+	if err != nil {
+		log.Printf("[WARN] Could not read the BIOS settings of node %s: %s", d.Id(), err)
+		return nil
+	}
+
+	current, apply := biosSettingsDrift(desired, actual)
+	if reflect.DeepEqual(current, desired) {
+		return nil
+	}
+	log.Printf("[DEBUG] BIOS settings of node %s have drifted, %d of them need to be applied", d.Id(), len(apply))
+	b, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	return d.Set("bios_settings", string(b))
+}
+
+// reapplyBIOSSettings applies the BIOS settings which don't match the node's actual settings with a clean cycle.
+func reapplyBIOSSettings(ctx context.Context, api IronicClient, d *schema.ResourceData) error {
+	clean, provide, err := configCleanCycle("BIOS settings", d.Get("provision_state").(string))
+	if err != nil || !clean {
+		return err
+	}
+
+	var desired []map[string]string
+	if biosSettings := d.Get("bios_settings").(string); biosSettings != "" {
+		if err := json.Unmarshal([]byte(biosSettings), &desired); err != nil {
+			return err
+		}
+	}
+	actual, err := api.ListBIOSSettings(d.Id())
+	if err != nil {
+		return fmt.Errorf("could not read BIOS settings: %s", err)
+	}
+	_, apply := biosSettingsDrift(desired, actual)
+	if len(apply) == 0 {
+		return nil
+	}
+
+	cleanSteps := []nodes.CleanStep{
+		{
+			Interface: "bios",
+			Step:      "apply_configuration",
+			Args: map[string]interface{}{
+				"settings": apply,
+			},
+		},
+	}
+	lastStep, err := changeProvisionStateWithLastStep(ctx, api, d.Id(), "clean", nil, nil, cleanSteps)
+	if lastStep != "" {
+		_ = d.Set("last_step", lastStep)
+	}
+	if err != nil {
+		return err
+	}
+
+	if provide {
+		return ChangeProvisionStateToTarget(ctx, api, d.Id(), "provide", nil, nil, nil)
+	}
+	return nil
+}
+
 // setRAIDConfig calls ironic's API to send request to change a Node's RAID config.
-func setRAIDConfig(client *gophercloud.ServiceClient, d *schema.ResourceData) (err error) {
+func setRAIDConfig(api IronicClient, d *schema.ResourceData) (err error) {
 	var logicalDisks []nodes.LogicalDisk
 	var targetRAID *metal3v1alpha1.RAIDConfig
 
@@ -643,11 +3112,7 @@ func setRAIDConfig(client *gophercloud.ServiceClient, d *schema.ResourceData) (e
 	}
 
 	// Set target for RAID configuration steps
-	return nodes.SetRAIDConfig(
-		client,
-		d.Id(),
-		nodes.RAIDConfigOpts{LogicalDisks: logicalDisks},
-	).ExtractErr()
+	return api.SetRAIDConfig(d.Id(), nodes.RAIDConfigOpts{LogicalDisks: logicalDisks})
 }
 
 // buildManualCleaningSteps builds the clean steps for RAID and BIOS configuration
@@ -3,14 +3,22 @@
 package ironic
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/drivers"
 	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
-	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/ports"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
 )
 
 func TestAccIronicNode(t *testing.T) {
@@ -104,9 +112,118 @@ func TestAccIronicNode(t *testing.T) {
 	})
 }
 
+// Removing resource_class from the config should clear it on the node
+func TestAccIronicNode_unsetResourceClass(t *testing.T) {
+	var node nodes.Node
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccNodeDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccNodeResource(""),
+				Check: resource.ComposeTestCheckFunc(
+					CheckNodeExists("ironic_node_v1.node-0", &node),
+					resource.TestCheckResourceAttr("ironic_node_v1.node-0", "resource_class", "baremetal"),
+				),
+			},
+			{
+				Config: strings.Replace(testAccNodeResource(""), `resource_class = "baremetal"`, "", 1),
+				Check: resource.ComposeTestCheckFunc(
+					CheckNodeExists("ironic_node_v1.node-0", &node),
+					resource.TestCheckResourceAttr("ironic_node_v1.node-0", "resource_class", ""),
+					func(*terraform.State) error {
+						if node.ResourceClass != "" {
+							return fmt.Errorf("expected resource_class to be cleared, got '%s'", node.ResourceClass)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+// A fake-hardware node without any interfaces set can be driven through the state machine, the interfaces that need
+// real hardware default to fake.
+func TestAccIronicNode_fakeHardware(t *testing.T) {
+	var node nodes.Node
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccNodeDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: `resource "ironic_node_v1" "node-0" {
+					name = "node-0"
+					driver = "fake-hardware"
+					manage = true
+				}`,
+				Check: resource.ComposeTestCheckFunc(
+					CheckNodeExists("ironic_node_v1.node-0", &node),
+					resource.TestCheckResourceAttr("ironic_node_v1.node-0", "provision_state", "manageable"),
+					resource.TestCheckResourceAttr("ironic_node_v1.node-0", "deploy_interface", "fake"),
+					resource.TestCheckResourceAttr("ironic_node_v1.node-0", "power_interface", "fake"),
+				),
+			},
+			{
+				Config: `resource "ironic_node_v1" "node-0" {
+					name = "node-0"
+					driver = "fake-hardware"
+					manage = true
+					clean = true
+					available = true
+				}`,
+				Check: resource.ComposeTestCheckFunc(
+					CheckNodeExists("ironic_node_v1.node-0", &node),
+					resource.TestCheckResourceAttr("ironic_node_v1.node-0", "provision_state", "available"),
+				),
+			},
+		},
+	})
+}
+
+// Creating a node in a conductor group shouldn't leave a diff behind, even though Ironic lower cases the group. Set
+// IRONIC_CONDUCTOR_GROUP to a group a conductor serves to run this test.
+func TestAccIronicNode_conductorGroup(t *testing.T) {
+	group := os.Getenv("IRONIC_CONDUCTOR_GROUP")
+	if group == "" {
+		t.Skip("IRONIC_CONDUCTOR_GROUP is not set")
+	}
+	config := testAccNodeResource(fmt.Sprintf(`conductor_group = "%s"`, strings.ToUpper(group)))
+
+	var node nodes.Node
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccNodeDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					CheckNodeExists("ironic_node_v1.node-0", &node),
+					func(*terraform.State) error {
+						if !strings.EqualFold(node.ConductorGroup, group) {
+							return fmt.Errorf("expected conductor_group '%s', got '%s'", group, node.ConductorGroup)
+						}
+						return nil
+					},
+				),
+			},
+			{
+				Config:   config,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
 func CheckNodeExists(name string, node *nodes.Node) resource.TestCheckFunc {
 	return func(state *terraform.State) error {
-		client, err := testAccProvider.Meta().(*Clients).GetIronicClient()
+		client, err := testAccProvider.Meta().(*Clients).GetIronicClient(context.Background())
 		if err != nil {
 			return err
 		}
@@ -132,7 +249,7 @@ func CheckNodeExists(name string, node *nodes.Node) resource.TestCheckFunc {
 }
 
 func testAccNodeDestroy(state *terraform.State) error {
-	client, err := testAccProvider.Meta().(*Clients).GetIronicClient()
+	client, err := testAccProvider.Meta().(*Clients).GetIronicClient(context.Background())
 	if err != nil {
 		return err
 	}
@@ -276,3 +393,2012 @@ func TestBuildManualCleaningSteps(t *testing.T) {
 		})
 	}
 }
+
+func TestNodeNameValidation(t *testing.T) {
+	validate := resourceNodeV1().Schema["name"].ValidateFunc
+
+	for _, name := range []string{"node-0", "rack1.node_2~a"} {
+		if _, errs := validate(name, "name"); len(errs) != 0 {
+			t.Errorf("expected %q to be a valid name, got %v", name, errs)
+		}
+	}
+	for _, name := range []string{"", "my node", "node/0", strings.Repeat("a", 256)} {
+		if _, errs := validate(name, "name"); len(errs) == 0 {
+			t.Errorf("expected an error for name %q", name)
+		}
+	}
+}
+
+func TestNormalizeRootDeviceHints(t *testing.T) {
+	hints := map[string]interface{}{
+		"size":       float64(100),
+		"rotational": false,
+		"model":      "PERC H730",
+	}
+	expected := map[string]interface{}{
+		"size":       "100",
+		"rotational": "false",
+		"model":      "PERC H730",
+	}
+
+	if actual := normalizeRootDeviceHints(hints); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if actual := normalizeRootDeviceHints(nil); actual != nil {
+		t.Errorf("expected no hints, got %v", actual)
+	}
+}
+
+func TestSchemaToCreateOptsDefaults(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, map[string]interface{}{
+		"driver":           "ipmi",
+		"deploy_interface": "ramdisk",
+	})
+	defaults := expandNodeDefaults([]interface{}{
+		map[string]interface{}{
+			"deploy_interface": "direct",
+			"resource_class":   "baremetal",
+			"boot_interface":   "",
+		},
+	})
+
+	opts := schemaToCreateOpts(d, defaults)
+	if opts.DeployInterface != "ramdisk" {
+		t.Errorf("expected the node's deploy_interface to take precedence, got '%s'", opts.DeployInterface)
+	}
+	if opts.ResourceClass != "baremetal" {
+		t.Errorf("expected the default resource_class, got '%s'", opts.ResourceClass)
+	}
+	if opts.BootInterface != "" {
+		t.Errorf("expected no boot_interface, got '%s'", opts.BootInterface)
+	}
+}
+
+func TestSchemaToCreateOptsDefaultNetworks(t *testing.T) {
+	defaults := expandNodeDefaults([]interface{}{
+		map[string]interface{}{
+			"provisioning_network": "a3e4c1d2-5b6f-4e7a-8c9d-0e1f2a3b4c5d",
+			"cleaning_network":     "b4f5d2e3-6c7a-4f8b-9dae-1f2a3b4c5d6e",
+			"rescuing_network":     "",
+		},
+	})
+
+	cases := []struct {
+		Scenario string
+		Config   map[string]interface{}
+		Expected map[string]interface{}
+	}{
+		{
+			Scenario: "neutron",
+			Config:   map[string]interface{}{"driver": "ipmi", "network_interface": "neutron"},
+			Expected: map[string]interface{}{
+				"provisioning_network": "a3e4c1d2-5b6f-4e7a-8c9d-0e1f2a3b4c5d",
+				"cleaning_network":     "b4f5d2e3-6c7a-4f8b-9dae-1f2a3b4c5d6e",
+			},
+		},
+		{
+			Scenario: "overridden by the node",
+			Config: map[string]interface{}{
+				"driver":            "ipmi",
+				"network_interface": "neutron",
+				"cleaning_network":  "c5a6e3f4-7d8b-4a9c-8ebf-2a3b4c5d6e7f",
+				"driver_info":       map[string]interface{}{"provisioning_network": "d6b7f4a5-8e9c-4bad-9fc0-3b4c5d6e7f8a"},
+			},
+			Expected: map[string]interface{}{
+				"provisioning_network": "d6b7f4a5-8e9c-4bad-9fc0-3b4c5d6e7f8a",
+				"cleaning_network":     "c5a6e3f4-7d8b-4a9c-8ebf-2a3b4c5d6e7f",
+			},
+		},
+		{
+			Scenario: "flat",
+			Config:   map[string]interface{}{"driver": "ipmi", "network_interface": "flat"},
+			Expected: map[string]interface{}{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, c.Config)
+			opts := schemaToCreateOpts(d, defaults)
+			if !reflect.DeepEqual(c.Expected, opts.DriverInfo) {
+				t.Errorf("expected driver_info %v, got %v", c.Expected, opts.DriverInfo)
+			}
+		})
+	}
+}
+
+func TestFastTrack(t *testing.T) {
+	cases := []struct {
+		Scenario        string
+		DeployInterface string
+		FastTrack       interface{}
+		Expected        map[string]interface{}
+		ExpectedError   string
+	}{
+		{
+			Scenario:        "enabled",
+			DeployInterface: "direct",
+			FastTrack:       true,
+			Expected:        map[string]interface{}{"fast_track": "true"},
+		},
+		{
+			Scenario:        "disabled",
+			DeployInterface: "ramdisk",
+			FastTrack:       false,
+			Expected:        map[string]interface{}{"fast_track": "false"},
+		},
+		{
+			Scenario: "unset",
+			Expected: map[string]interface{}{},
+		},
+		{
+			Scenario:        "without the agent",
+			DeployInterface: "ramdisk",
+			FastTrack:       true,
+			ExpectedError:   "fast_track requires an agent based deploy_interface (direct, ansible, custom-agent), got 'ramdisk'",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			config := map[string]interface{}{"driver": "ipmi", "deploy_interface": c.DeployInterface}
+			if c.FastTrack != nil {
+				config["fast_track"] = c.FastTrack
+			}
+			d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, config)
+
+			err := validateFastTrack(c.DeployInterface, d.Get("fast_track").(bool))
+			if c.ExpectedError != "" {
+				th.AssertError(t, err, c.ExpectedError)
+				return
+			}
+			th.AssertNoError(t, err)
+			if opts := schemaToCreateOpts(d, nil); !reflect.DeepEqual(c.Expected, opts.DriverInfo) {
+				t.Errorf("expected driver_info %v, got %v", c.Expected, opts.DriverInfo)
+			}
+		})
+	}
+}
+
+func TestSchemaToCreateOptsFakeHardware(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, map[string]interface{}{
+		"driver":          "fake-hardware",
+		"power_interface": "ipmitool",
+	})
+
+	opts := schemaToCreateOpts(d, map[string]string{"management_interface": "ipmitool"})
+	expected := map[string]string{
+		"boot":       "fake",
+		"deploy":     "fake",
+		"management": "ipmitool",
+		"power":      "ipmitool",
+		"vendor":     "",
+	}
+	actual := map[string]string{
+		"boot":       opts.BootInterface,
+		"deploy":     opts.DeployInterface,
+		"management": opts.ManagementInterface,
+		"power":      opts.PowerInterface,
+		"vendor":     opts.VendorInterface,
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected interfaces %v, got %v", expected, actual)
+	}
+}
+
+func TestConfigCleanCycle(t *testing.T) {
+	cases := []struct {
+		State           string
+		ExpectedClean   bool
+		ExpectedProvide bool
+		ExpectedError   string
+	}{
+		{State: "available", ExpectedClean: true, ExpectedProvide: true},
+		{State: "manageable", ExpectedClean: true},
+		{State: "enroll"},
+		{State: "active", ExpectedError: "the node is active"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.State, func(t *testing.T) {
+			clean, provide, err := configCleanCycle("RAID configuration", c.State)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+			if clean != c.ExpectedClean || provide != c.ExpectedProvide {
+				t.Errorf("expected clean %t and provide %t, got %t and %t", c.ExpectedClean, c.ExpectedProvide, clean, provide)
+			}
+		})
+	}
+}
+
+func TestBIOSSettingsDrift(t *testing.T) {
+	readOnly := true
+	writable := false
+	actual := []nodes.BIOSSetting{
+		{Name: "ProcVirtualization", Value: "Enabled", ReadOnly: &writable},
+		{Name: "BootMode", Value: "Bios", ReadOnly: &writable},
+		{Name: "SerialNumber", Value: "1234", ReadOnly: &readOnly},
+		{Name: "NumLock", Value: "Off"},
+	}
+
+	cases := []struct {
+		Scenario        string
+		Desired         []map[string]string
+		ExpectedCurrent []map[string]string
+		ExpectedApply   []map[string]string
+	}{
+		{
+			Scenario:        "no drift",
+			Desired:         []map[string]string{{"name": "ProcVirtualization", "value": "Enabled"}},
+			ExpectedCurrent: []map[string]string{{"name": "ProcVirtualization", "value": "Enabled"}},
+		},
+		{
+			Scenario:        "drifted",
+			Desired:         []map[string]string{{"name": "ProcVirtualization", "value": "Enabled"}, {"name": "BootMode", "value": "Uefi"}},
+			ExpectedCurrent: []map[string]string{{"name": "ProcVirtualization", "value": "Enabled"}, {"name": "BootMode", "value": "Bios"}},
+			ExpectedApply:   []map[string]string{{"name": "BootMode", "value": "Uefi"}},
+		},
+		{
+			Scenario:        "drifted without read-only details",
+			Desired:         []map[string]string{{"name": "NumLock", "value": "On"}},
+			ExpectedCurrent: []map[string]string{{"name": "NumLock", "value": "Off"}},
+			ExpectedApply:   []map[string]string{{"name": "NumLock", "value": "On"}},
+		},
+		{
+			Scenario:        "read-only",
+			Desired:         []map[string]string{{"name": "SerialNumber", "value": "5678"}},
+			ExpectedCurrent: []map[string]string{{"name": "SerialNumber", "value": "5678"}},
+		},
+		{
+			Scenario:        "unknown to ironic",
+			Desired:         []map[string]string{{"name": "L2Cache", "value": "10x256 KB"}},
+			ExpectedCurrent: []map[string]string{{"name": "L2Cache", "value": "10x256 KB"}},
+			ExpectedApply:   []map[string]string{{"name": "L2Cache", "value": "10x256 KB"}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			current, apply := biosSettingsDrift(c.Desired, actual)
+			if !reflect.DeepEqual(current, c.ExpectedCurrent) {
+				t.Errorf("expected current settings %v, got %v", c.ExpectedCurrent, current)
+			}
+			if !reflect.DeepEqual(apply, c.ExpectedApply) {
+				t.Errorf("expected settings to apply %v, got %v", c.ExpectedApply, apply)
+			}
+		})
+	}
+}
+
+func TestManageTransitionNeeded(t *testing.T) {
+	cases := []struct {
+		State    string
+		StepBack bool
+		Expected bool
+	}{
+		{State: "enroll", Expected: true},
+		{State: "clean failed", Expected: true},
+		{State: "manageable", StepBack: true},
+		{State: "available"},
+		{State: "available", StepBack: true, Expected: true},
+		{State: "active"},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%s step back %t", c.State, c.StepBack), func(t *testing.T) {
+			if actual := manageTransitionNeeded(c.State, c.StepBack); actual != c.Expected {
+				t.Errorf("expected %t, got %t", c.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestConductorGroupDefaultDiff(t *testing.T) {
+	// The node was put in the non-empty default group by Ironic, rather than by the configuration
+	state := &terraform.InstanceState{
+		ID: "node-0",
+		Attributes: map[string]string{
+			"id":              "node-0",
+			"driver":          "ipmi",
+			"conductor_group": "rack-1",
+		},
+	}
+
+	cases := []struct {
+		Scenario     string
+		Config       map[string]interface{}
+		ExpectedDiff bool
+	}{
+		{
+			Scenario: "unset",
+			Config:   map[string]interface{}{"driver": "ipmi"},
+		},
+		{
+			Scenario: "set in a different case",
+			Config:   map[string]interface{}{"driver": "ipmi", "conductor_group": "RACK-1"},
+		},
+		{
+			Scenario:     "moved to another group",
+			Config:       map[string]interface{}{"driver": "ipmi", "conductor_group": "rack-2"},
+			ExpectedDiff: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			diff, err := resourceNodeV1().Diff(context.Background(), state, terraform.NewResourceConfigRaw(c.Config), &Clients{})
+			th.AssertNoError(t, err)
+
+			changed := false
+			if diff != nil {
+				_, changed = diff.Attributes["conductor_group"]
+			}
+			if changed != c.ExpectedDiff {
+				t.Errorf("expected a conductor_group diff %t, got %v", c.ExpectedDiff, diff)
+			}
+		})
+	}
+}
+
+func TestSecureBootRoundTrip(t *testing.T) {
+	cases := []struct {
+		Scenario     string
+		Capabilities string
+		Config       map[string]interface{}
+		ExpectedDiff bool
+	}{
+		{
+			Scenario:     "enabled with other capabilities",
+			Capabilities: "boot_mode:uefi,secure_boot:true",
+			Config: map[string]interface{}{
+				"driver":      "ipmi",
+				"properties":  map[string]interface{}{"capabilities": "boot_mode:uefi"},
+				"secure_boot": true,
+			},
+		},
+		{
+			Scenario:     "enabled alone",
+			Capabilities: "secure_boot:true",
+			Config:       map[string]interface{}{"driver": "ipmi", "secure_boot": true},
+		},
+		{
+			Scenario:     "unset",
+			Capabilities: "secure_boot:true",
+			Config:       map[string]interface{}{"driver": "ipmi"},
+		},
+		{
+			Scenario:     "disabled at the BMC",
+			Capabilities: "boot_mode:uefi,secure_boot:false",
+			Config: map[string]interface{}{
+				"driver":      "ipmi",
+				"properties":  map[string]interface{}{"capabilities": "boot_mode:uefi"},
+				"secure_boot": true,
+			},
+			ExpectedDiff: true,
+		},
+		{
+			Scenario:     "capability removed",
+			Capabilities: "boot_mode:uefi",
+			Config: map[string]interface{}{
+				"driver":      "ipmi",
+				"properties":  map[string]interface{}{"capabilities": "boot_mode:uefi"},
+				"secure_boot": true,
+			},
+			ExpectedDiff: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			// Read the node's properties back as the resource does
+			properties := map[string]interface{}{"capabilities": c.Capabilities}
+			secureBoot := popSecureBoot(properties)
+			attributes := map[string]string{
+				"id":           "node-0",
+				"driver":       "ipmi",
+				"secure_boot":  fmt.Sprint(secureBoot),
+				"properties.%": fmt.Sprint(len(properties)),
+			}
+			for key, value := range properties {
+				attributes["properties."+key] = value.(string)
+			}
+			state := &terraform.InstanceState{ID: "node-0", Attributes: attributes}
+
+			diff, err := resourceNodeV1().Diff(context.Background(), state, terraform.NewResourceConfigRaw(c.Config), &Clients{})
+			th.AssertNoError(t, err)
+			changed := false
+			if diff != nil {
+				for key := range diff.Attributes {
+					if key == "secure_boot" || strings.HasPrefix(key, "properties.") {
+						changed = true
+					}
+				}
+			}
+			if changed != c.ExpectedDiff {
+				t.Errorf("expected a secure_boot or properties diff %t, got %v", c.ExpectedDiff, diff)
+			}
+		})
+	}
+}
+
+func TestNodeBootMode(t *testing.T) {
+	uefi, bios, on, off := "uefi", "bios", true, false
+	cases := []struct {
+		Scenario                  string
+		Capabilities              string
+		State                     *nodeBootState
+		ExpectedBootMode          string
+		ExpectedSecureBoot        bool
+		ExpectedCurrentSecureBoot bool
+	}{
+		{
+			Scenario:                  "capabilities only",
+			Capabilities:              "boot_mode:uefi,secure_boot:true",
+			ExpectedBootMode:          "uefi",
+			ExpectedSecureBoot:        true,
+			ExpectedCurrentSecureBoot: true,
+		},
+		{
+			Scenario:           "secure boot turned off at the BMC",
+			Capabilities:       "boot_mode:uefi,secure_boot:true",
+			State:              &nodeBootState{BootMode: &uefi, SecureBoot: &off},
+			ExpectedBootMode:   "uefi",
+			ExpectedSecureBoot: true,
+		},
+		{
+			Scenario:                  "booting in a different mode",
+			Capabilities:              "boot_mode:uefi",
+			State:                     &nodeBootState{BootMode: &bios, SecureBoot: &on},
+			ExpectedBootMode:          "bios",
+			ExpectedCurrentSecureBoot: true,
+		},
+		{
+			Scenario:                  "state not reported by the management interface",
+			Capabilities:              "boot_mode:uefi,secure_boot:true",
+			State:                     &nodeBootState{},
+			ExpectedBootMode:          "uefi",
+			ExpectedSecureBoot:        true,
+			ExpectedCurrentSecureBoot: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			properties := map[string]interface{}{"capabilities": c.Capabilities}
+			bootMode, secureBoot, currentSecureBoot := nodeBootMode(properties, c.State)
+			if bootMode != c.ExpectedBootMode || secureBoot != c.ExpectedSecureBoot || currentSecureBoot != c.ExpectedCurrentSecureBoot {
+				t.Errorf("expected boot mode '%s', secure boot %t and current secure boot %t, got '%s', %t and %t",
+					c.ExpectedBootMode, c.ExpectedSecureBoot, c.ExpectedCurrentSecureBoot, bootMode, secureBoot, currentSecureBoot)
+			}
+			if capabilities, _ := properties["capabilities"].(string); strings.Contains(capabilities, "secure_boot") {
+				t.Errorf("expected the secure_boot capability to be removed, got '%s'", capabilities)
+			}
+		})
+	}
+}
+
+func TestSetCapability(t *testing.T) {
+	cases := []struct {
+		Capabilities string
+		Expected     string
+	}{
+		{Capabilities: "", Expected: "secure_boot:true"},
+		{Capabilities: "boot_mode:uefi", Expected: "boot_mode:uefi,secure_boot:true"},
+		{Capabilities: "secure_boot:false, boot_mode:uefi", Expected: "boot_mode:uefi,secure_boot:true"},
+	}
+
+	for _, c := range cases {
+		if result := setCapability(c.Capabilities, "secure_boot", "true"); result != c.Expected {
+			t.Errorf("expected: %s, got: %s", c.Expected, result)
+		}
+	}
+}
+
+func TestTraitsDrift(t *testing.T) {
+	// CUSTOM_EXTERNAL was added to the node outside of Terraform, and read back into the state
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+		"node-0": {"uuid": "node-0", "traits": []string{"CUSTOM_GPU", "CUSTOM_EXTERNAL"}},
+	}}
+	var node nodes.Node
+	th.AssertNoError(t, api.GetNode("node-0", &node))
+	d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, map[string]interface{}{"driver": "ipmi"})
+	d.SetId("node-0")
+	th.AssertNoError(t, d.Set("traits", node.Traits))
+	traitsSet := d.Get("traits").(*schema.Set)
+
+	config := map[string]interface{}{"driver": "ipmi", "traits": []interface{}{"CUSTOM_GPU"}}
+	diff, err := resourceNodeV1().Diff(context.Background(), d.State(), terraform.NewResourceConfigRaw(config), &Clients{})
+	th.AssertNoError(t, err)
+	removed := fmt.Sprintf("traits.%d", traitsSet.F("CUSTOM_EXTERNAL"))
+	if diff == nil || diff.Attributes[removed] == nil || !diff.Attributes[removed].NewRemoved {
+		t.Fatalf("expected the plan to remove CUSTOM_EXTERNAL, got %v", diff)
+	}
+	if kept := diff.Attributes[fmt.Sprintf("traits.%d", traitsSet.F("CUSTOM_GPU"))]; kept != nil && (kept.NewRemoved || kept.Old != kept.New) {
+		t.Errorf("expected CUSTOM_GPU to be kept, got %v", kept)
+	}
+
+	// Applying the plan removes the trait from the node
+	th.AssertNoError(t, updateNodeTraits(api, "node-0", traitsSet.List(), []interface{}{"CUSTOM_GPU"}))
+	if traits := api.nodes["node-0"]["traits"]; !reflect.DeepEqual(traits, []string{"CUSTOM_GPU"}) {
+		t.Errorf("expected traits [CUSTOM_GPU], got %v", traits)
+	}
+}
+
+func TestUpdateNodeTraits(t *testing.T) {
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+		"node-0": {"uuid": "node-0", "traits": []string{"CUSTOM_GPU", "CUSTOM_RAID"}},
+	}}
+
+	err := updateNodeTraits(api, "node-0", []interface{}{"CUSTOM_GPU", "CUSTOM_RAID"}, []interface{}{"CUSTOM_RAID", "HW_CPU_X86_VMX"})
+	th.AssertNoError(t, err)
+	if traits := api.nodes["node-0"]["traits"]; !reflect.DeepEqual(traits, []string{"CUSTOM_RAID", "HW_CPU_X86_VMX"}) {
+		t.Errorf("expected traits [CUSTOM_RAID HW_CPU_X86_VMX], got %v", traits)
+	}
+
+	// A trait that's already gone doesn't need removing
+	th.AssertNoError(t, updateNodeTraits(api, "node-0", []interface{}{"CUSTOM_GPU"}, nil))
+}
+
+func TestNormalizePowerState(t *testing.T) {
+	cases := []struct {
+		State    string
+		Expected string
+	}{
+		{State: "power on", Expected: "power on"},
+		{State: "Power On", Expected: "power on"},
+		{State: "POWER OFF", Expected: "power off"},
+		{State: " power  off ", Expected: "power off"},
+		{State: "power_on", Expected: "power on"},
+		{State: "soft-power-off", Expected: "soft power off"},
+		{State: "", Expected: ""},
+	}
+
+	for _, c := range cases {
+		if result := normalizePowerState(c.State); result != c.Expected {
+			t.Errorf("%q: expected: %q, got: %q", c.State, c.Expected, result)
+		}
+	}
+}
+
+func TestTargetPowerStateDiff(t *testing.T) {
+	cases := []struct {
+		PowerState   string
+		Target       string
+		ExpectedDiff bool
+	}{
+		{PowerState: "Power On", Target: "power on"},
+		{PowerState: "power_off", Target: "power off"},
+		{PowerState: "power on", Target: "POWER ON"},
+		{PowerState: "POWER ON", Target: "power off", ExpectedDiff: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.PowerState+" to "+c.Target, func(t *testing.T) {
+			// The power state is normalized when it's read from Ironic
+			state := &terraform.InstanceState{
+				ID: "node-0",
+				Attributes: map[string]string{
+					"id":          "node-0",
+					"driver":      "ipmi",
+					"power_state": normalizePowerState(c.PowerState),
+				},
+			}
+			config := map[string]interface{}{"driver": "ipmi", "target_power_state": c.Target}
+
+			diff, err := resourceNodeV1().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config), &Clients{})
+			th.AssertNoError(t, err)
+			changed := false
+			if diff != nil {
+				_, changed = diff.Attributes["target_power_state"]
+			}
+			if changed != c.ExpectedDiff {
+				t.Errorf("expected a target_power_state diff %t, got %v", c.ExpectedDiff, diff)
+			}
+		})
+	}
+}
+
+func TestDrainRequiresMaintenance(t *testing.T) {
+	state := &terraform.InstanceState{
+		ID:         "node-0",
+		Attributes: map[string]string{"id": "node-0", "driver": "ipmi"},
+	}
+
+	_, err := resourceNodeV1().Diff(context.Background(), state, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"driver": "ipmi",
+		"drain":  true,
+	}), &Clients{})
+	th.AssertError(t, err, "drain leaves the node in maintenance, so maintenance must be true too")
+
+	_, err = resourceNodeV1().Diff(context.Background(), state, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"driver":      "ipmi",
+		"drain":       true,
+		"maintenance": true,
+	}), &Clients{})
+	th.AssertNoError(t, err)
+}
+
+func TestDrainRejectedOnCreate(t *testing.T) {
+	_, err := resourceNodeV1().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"driver":      "ipmi",
+		"drain":       true,
+		"maintenance": true,
+	}), &Clients{})
+	th.AssertError(t, err, "drain takes an existing node out of service, so it can't be set when creating the node")
+}
+
+func TestValidateNodeFlags(t *testing.T) {
+	cases := []struct {
+		Scenario      string
+		Enabled       map[string]bool
+		Requested     map[string]bool
+		ExpectedError string
+	}{
+		{
+			Scenario:  "no maintenance",
+			Enabled:   map[string]bool{"available": true, "clean": true},
+			Requested: map[string]bool{"available": true, "clean": true},
+		},
+		{
+			Scenario:  "maintenance on an already available node",
+			Enabled:   map[string]bool{"maintenance": true, "available": true},
+			Requested: map[string]bool{"maintenance": true},
+		},
+		{
+			Scenario:      "clean and inspect requested in maintenance",
+			Enabled:       map[string]bool{"maintenance": true, "clean": true, "inspect": true},
+			Requested:     map[string]bool{"clean": true, "inspect": true},
+			ExpectedError: "maintenance cannot be combined with clean, inspect",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			err := validateNodeFlags(c.Enabled, c.Requested)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestStringFieldUpdateOp(t *testing.T) {
+	cases := []struct {
+		Scenario string
+		Value    string
+		Expected nodes.UpdateOperation
+	}{
+		{
+			Scenario: "set value",
+			Value:    "baremetal",
+			Expected: nodes.UpdateOperation{Op: nodes.ReplaceOp, Path: "/resource_class", Value: "baremetal"},
+		},
+		{
+			Scenario: "empty value",
+			Value:    "",
+			Expected: nodes.UpdateOperation{Op: nodes.RemoveOp, Path: "/resource_class"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			op := stringFieldUpdateOp("resource_class", c.Value)
+			if !reflect.DeepEqual(c.Expected, op) {
+				t.Errorf("expected: %v, got: %v", c.Expected, op)
+			}
+		})
+	}
+}
+
+func TestRecordedPortUUIDs(t *testing.T) {
+	recorded := map[string]interface{}{
+		"00:bb:4a:d0:5e:38": "8a0ebe1a-5c6e-4a7b-9d2c-0df3c2a1e8f1",
+		"00:bb:4a:d0:5e:39": "b7f2d0c4-3c1a-4b5e-8f6d-2e9a7c4b1d03",
+	}
+	nodePorts := []ports.Port{
+		{UUID: "8a0ebe1a-5c6e-4a7b-9d2c-0df3c2a1e8f1", Address: "00:bb:4a:d0:5e:38"},
+		{UUID: "4c3d2e1f-0a9b-4c8d-9e7f-6a5b4c3d2e1f", Address: "00:bb:4a:d0:5e:40"},
+	}
+	expected := map[string]interface{}{
+		"00:bb:4a:d0:5e:38": "8a0ebe1a-5c6e-4a7b-9d2c-0df3c2a1e8f1",
+	}
+
+	result := recordedPortUUIDs(recorded, nodePorts)
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("expected: %v, got: %v", expected, result)
+	}
+}
+
+func TestRemovedPortUUIDs(t *testing.T) {
+	recorded := map[string]interface{}{
+		"00:bb:4a:d0:5e:38": "8a0ebe1a-5c6e-4a7b-9d2c-0df3c2a1e8f1",
+		"00:bb:4a:d0:5e:39": "b7f2d0c4-3c1a-4b5e-8f6d-2e9a7c4b1d03",
+	}
+	removed := []interface{}{
+		map[string]interface{}{"address": "00:bb:4a:d0:5e:38"},
+		map[string]interface{}{"address": "00:bb:4a:d0:5e:40"},
+	}
+	expected := map[string]string{
+		"00:bb:4a:d0:5e:38": "8a0ebe1a-5c6e-4a7b-9d2c-0df3c2a1e8f1",
+	}
+
+	result := removedPortUUIDs(removed, recorded)
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("expected: %v, got: %v", expected, result)
+	}
+}
+
+func TestCreateNodePorts(t *testing.T) {
+	var portList []interface{}
+	for i := 0; i < 10; i++ {
+		portList = append(portList, map[string]interface{}{"address": fmt.Sprintf("00:bb:4a:d0:5e:%02d", i)})
+	}
+
+	var mux sync.Mutex
+	var active, maxActive int
+	portUUIDs := make(map[string]interface{})
+	err := createNodePorts(context.Background(), portList, portUUIDs, false, func(port map[string]interface{}) (*ports.Port, error) {
+		mux.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mux.Unlock()
+		defer func() {
+			mux.Lock()
+			active--
+			mux.Unlock()
+		}()
+
+		address := port["address"].(string)
+		if address == "00:bb:4a:d0:5e:03" || address == "00:bb:4a:d0:5e:07" {
+			return nil, fmt.Errorf("invalid MAC address")
+		}
+		return &ports.Port{UUID: "uuid-" + address, Address: address}, nil
+	})
+
+	th.AssertError(t, err, "could not create port 00:bb:4a:d0:5e:03: invalid MAC address")
+	th.AssertError(t, err, "could not create port 00:bb:4a:d0:5e:07: invalid MAC address")
+	if len(portUUIDs) != 8 {
+		t.Errorf("expected the other 8 ports to be recorded, got: %v", portUUIDs)
+	}
+	if portUUIDs["00:bb:4a:d0:5e:05"] != "uuid-00:bb:4a:d0:5e:05" {
+		t.Errorf("expected port 00:bb:4a:d0:5e:05 to be recorded, got: %v", portUUIDs)
+	}
+	if maxActive > portCreateWorkers {
+		t.Errorf("expected at most %d ports to be created at once, got: %d", portCreateWorkers, maxActive)
+	}
+}
+
+func TestInlinePorts(t *testing.T) {
+	nodePorts := []ports.Port{
+		{UUID: "8a0ebe1a-5c6e-4a7b-9d2c-0df3c2a1e8f1", Address: "00:bb:4a:d0:5e:38", PXEEnabled: true},
+		{UUID: "b7f2d0c4-3c1a-4b5e-8f6d-2e9a7c4b1d03", Address: "00:bb:4a:d0:5e:39", PhysicalNetwork: "provisioning"},
+	}
+	expected := []interface{}{
+		map[string]interface{}{"address": "00:bb:4a:d0:5e:38", "pxe_enabled": "true"},
+		map[string]interface{}{"address": "00:bb:4a:d0:5e:39", "pxe_enabled": "false", "physical_network": "provisioning"},
+	}
+
+	result := inlinePorts(nodePorts)
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("expected: %v, got: %v", expected, result)
+	}
+}
+
+func TestFlattenPorts(t *testing.T) {
+	nodePorts := []ports.Port{
+		{
+			UUID:                "8a0ebe1a-5c6e-4a7b-9d2c-0df3c2a1e8f1",
+			Address:             "00:bb:4a:d0:5e:38",
+			PXEEnabled:          true,
+			LocalLinkConnection: map[string]interface{}{"switch_id": "0a:1b:2c:3d:4e:5f", "port_id": "Ethernet3/1"},
+		},
+	}
+	expected := []interface{}{
+		map[string]interface{}{
+			"uuid":                  "8a0ebe1a-5c6e-4a7b-9d2c-0df3c2a1e8f1",
+			"address":               "00:bb:4a:d0:5e:38",
+			"pxe_enabled":           true,
+			"local_link_connection": map[string]interface{}{"switch_id": "0a:1b:2c:3d:4e:5f", "port_id": "Ethernet3/1"},
+		},
+	}
+
+	result := flattenPorts(nodePorts)
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("expected: %v, got: %v", expected, result)
+	}
+}
+
+func TestValidateFlatNetworkPorts(t *testing.T) {
+	networkData := `{"links": [], "networks": [], "services": []}`
+	withPhysicalNetwork := map[string]interface{}{"address": "00:bb:4a:d0:5e:38", "physical_network": "physnet1"}
+	withoutPhysicalNetwork := map[string]interface{}{"address": "00:bb:4a:d0:5e:39"}
+
+	cases := []struct {
+		Scenario         string
+		NetworkData      string
+		NetworkInterface string
+		Ports            []interface{}
+		ExpectedError    string
+	}{
+		{
+			Scenario:         "no network data",
+			NetworkInterface: "flat",
+			Ports:            []interface{}{withoutPhysicalNetwork},
+		},
+		{
+			Scenario:         "neutron network interface",
+			NetworkData:      networkData,
+			NetworkInterface: "neutron",
+			Ports:            []interface{}{withoutPhysicalNetwork},
+		},
+		{
+			Scenario:         "flat with physical network",
+			NetworkData:      networkData,
+			NetworkInterface: "flat",
+			Ports:            []interface{}{withPhysicalNetwork},
+		},
+		{
+			Scenario:         "flat without physical network",
+			NetworkData:      networkData,
+			NetworkInterface: "flat",
+			Ports:            []interface{}{withPhysicalNetwork, withoutPhysicalNetwork},
+			ExpectedError:    "port 00:bb:4a:d0:5e:39 must set physical_network",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			err := validateFlatNetworkPorts(c.NetworkData, c.NetworkInterface, c.Ports)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestValidateManagedInspection(t *testing.T) {
+	cases := []struct {
+		Scenario         string
+		InspectInterface string
+		Fields           map[string]string
+		DriverInfo       map[string]interface{}
+		ExpectedError    string
+	}{
+		{
+			Scenario:         "unmanaged inspect interface",
+			InspectInterface: "redfish",
+		},
+		{
+			Scenario:         "ramdisk fields",
+			InspectInterface: "agent",
+			Fields:           map[string]string{"inspection_kernel": "http://192.0.2.1/ipa.kernel", "inspection_ramdisk": "http://192.0.2.1/ipa.initramfs"},
+		},
+		{
+			Scenario:         "ramdisk in driver_info",
+			InspectInterface: "inspector",
+			DriverInfo:       map[string]interface{}{"deploy_kernel": "http://192.0.2.1/ipa.kernel", "deploy_ramdisk": "http://192.0.2.1/ipa.initramfs"},
+		},
+		{
+			Scenario:         "missing ramdisk",
+			InspectInterface: "agent",
+			Fields:           map[string]string{"inspection_kernel": "http://192.0.2.1/ipa.kernel"},
+			ExpectedError:    "the 'agent' inspect_interface requires inspection_ramdisk",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			err := validateManagedInspection(c.InspectInterface, c.Fields, c.DriverInfo)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestValidateInspectionKernelParams(t *testing.T) {
+	params := map[string]string{"inspection_kernel_params": "ipa-inspection-collectors=default,logs"}
+	cases := []struct {
+		Scenario         string
+		InspectInterface string
+		Fields           map[string]string
+		ExpectedError    string
+	}{
+		{
+			Scenario:         "managed inspect interface",
+			InspectInterface: "agent",
+			Fields:           params,
+		},
+		{
+			Scenario: "default inspect interface",
+			Fields:   params,
+		},
+		{
+			Scenario:         "unmanaged inspect interface",
+			InspectInterface: "redfish",
+			Fields:           params,
+			ExpectedError:    "inspection_kernel_params requires a managed inspect_interface (agent or inspector), got 'redfish'",
+		},
+		{
+			Scenario:         "unmanaged inspect interface without params",
+			InspectInterface: "redfish",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			err := validateInspectionKernelParams(c.InspectInterface, c.Fields)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestValidateDriverInfoFields(t *testing.T) {
+	driverInfo := map[string]interface{}{"deploy_kernel": "http://192.0.2.1/ipa.kernel"}
+
+	th.AssertNoError(t, validateDriverInfoFields(map[string]string{"inspection_ramdisk": "http://192.0.2.1/ipa.initramfs"}, driverInfo))
+	th.AssertError(t, validateDriverInfoFields(map[string]string{"inspection_kernel": "http://192.0.2.1/ipa.kernel"}, driverInfo),
+		"inspection_kernel conflicts with driver_info.deploy_kernel")
+}
+
+func TestValidateDriverInfoRequired(t *testing.T) {
+	cases := []struct {
+		Scenario      string
+		Driver        string
+		DriverInfo    map[string]interface{}
+		ExpectedError string
+	}{
+		{
+			Scenario:   "redfish",
+			Driver:     "redfish",
+			DriverInfo: map[string]interface{}{"redfish_address": "https://192.0.2.10"},
+		},
+		{
+			Scenario:      "redfish without address",
+			Driver:        "redfish",
+			DriverInfo:    map[string]interface{}{"redfish_system_id": "/redfish/v1/Systems/1"},
+			ExpectedError: "the 'redfish' driver requires driver_info.redfish_address",
+		},
+		{
+			Scenario:   "idrac with wsman",
+			Driver:     "idrac",
+			DriverInfo: map[string]interface{}{"drac_address": "192.0.2.10"},
+		},
+		{
+			Scenario:      "idrac without address",
+			Driver:        "idrac",
+			ExpectedError: "the 'idrac' driver requires driver_info.redfish_address or driver_info.drac_address",
+		},
+		{
+			Scenario:      "ilo without password",
+			Driver:        "ilo",
+			DriverInfo:    map[string]interface{}{"ilo_address": "192.0.2.10", "ilo_username": "admin"},
+			ExpectedError: "the 'ilo' driver requires driver_info.ilo_password",
+		},
+		{
+			Scenario: "unknown driver",
+			Driver:   "fake-hardware",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			err := validateDriverInfoRequired(c.Driver, c.DriverInfo)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestValidateDeployRamdisk(t *testing.T) {
+	cases := []struct {
+		Scenario      string
+		Fields        map[string]string
+		DriverInfo    map[string]interface{}
+		ExpectedError string
+	}{
+		{
+			Scenario: "neither set",
+		},
+		{
+			Scenario: "both fields",
+			Fields:   map[string]string{"deploy_kernel": "http://192.0.2.1/ipa.kernel", "deploy_ramdisk": "http://192.0.2.1/ipa.initramfs"},
+		},
+		{
+			Scenario:   "kernel field and ramdisk in driver_info",
+			Fields:     map[string]string{"inspection_kernel": "http://192.0.2.1/ipa.kernel"},
+			DriverInfo: map[string]interface{}{"deploy_ramdisk": "http://192.0.2.1/ipa.initramfs"},
+		},
+		{
+			Scenario:      "only the kernel",
+			Fields:        map[string]string{"deploy_kernel": "http://192.0.2.1/ipa.kernel"},
+			ExpectedError: "deploy_kernel and deploy_ramdisk must be set together",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			err := validateDeployRamdisk(c.Fields, c.DriverInfo)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestValidateRescueRamdisk(t *testing.T) {
+	cases := []struct {
+		Scenario        string
+		RescueInterface string
+		Fields          map[string]string
+		DriverInfo      map[string]interface{}
+		ExpectedError   string
+	}{
+		{
+			Scenario:        "no rescue",
+			RescueInterface: "no-rescue",
+		},
+		{
+			Scenario:        "agent rescue with both set",
+			RescueInterface: "agent",
+			Fields:          map[string]string{"rescue_kernel": "http://192.0.2.1/ipa.kernel"},
+			DriverInfo:      map[string]interface{}{"rescue_ramdisk": "http://192.0.2.1/ipa.initramfs"},
+		},
+		{
+			Scenario:        "agent rescue without a ramdisk",
+			RescueInterface: "agent",
+			ExpectedError:   "the 'agent' rescue_interface requires rescue_kernel and rescue_ramdisk",
+		},
+		{
+			Scenario:      "only the ramdisk",
+			Fields:        map[string]string{"rescue_ramdisk": "http://192.0.2.1/ipa.initramfs"},
+			ExpectedError: "rescue_kernel and rescue_ramdisk must be set together",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			err := validateRescueRamdisk(c.RescueInterface, c.Fields, c.DriverInfo)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestValidateImageDownload(t *testing.T) {
+	cases := []struct {
+		Scenario        string
+		DeployInterface string
+		Fields          map[string]string
+		DriverInfo      map[string]interface{}
+		ExpectedError   string
+	}{
+		{
+			Scenario:        "nothing set",
+			DeployInterface: "ramdisk",
+		},
+		{
+			Scenario:        "direct deploy from a local image server",
+			DeployInterface: "direct",
+			Fields:          map[string]string{"image_download_source": "http", "image_no_proxy": "192.0.2.1"},
+			DriverInfo:      map[string]interface{}{"image_http_proxy": "http://proxy.example.com:3128"},
+		},
+		{
+			Scenario:   "default deploy interface",
+			DriverInfo: map[string]interface{}{"image_download_source": "local"},
+		},
+		{
+			Scenario:        "ramdisk deploy",
+			DeployInterface: "ramdisk",
+			Fields:          map[string]string{"image_download_source": "http", "image_https_proxy": "http://proxy.example.com:3128"},
+			ExpectedError:   "the 'direct' deploy_interface is required for image_download_source and image_https_proxy, got 'ramdisk'",
+		},
+		{
+			Scenario:      "no_proxy without a proxy",
+			Fields:        map[string]string{"image_no_proxy": "192.0.2.1"},
+			ExpectedError: "image_no_proxy requires image_http_proxy or image_https_proxy",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			err := validateImageDownload(c.DeployInterface, c.Fields, c.DriverInfo)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestUpdateNodeInMaintenance(t *testing.T) {
+	cases := []struct {
+		Scenario            string
+		UpdateErr           error
+		ExpectedMaintenance bool
+		ExpectedReason      string
+		ExpectedError       string
+	}{
+		{
+			Scenario: "updated",
+		},
+		{
+			Scenario:            "failed",
+			UpdateErr:           fmt.Errorf("invalid power_interface"),
+			ExpectedMaintenance: true,
+			ExpectedReason:      "Terraform could not update the node: invalid power_interface",
+			ExpectedError:       "invalid power_interface, the node was left in maintenance",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			api := &fakeIronicClient{
+				nodes:          map[string]map[string]interface{}{"node-0": {"uuid": "node-0", "maintenance": false}},
+				updatePathErrs: map[string]error{"/power_interface": c.UpdateErr},
+			}
+			opts := nodes.UpdateOpts{stringFieldUpdateOp("power_interface", "redfish")}
+
+			err := updateNodeInMaintenance(context.Background(), api, "node-0", opts)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+
+			var node nodes.Node
+			th.AssertNoError(t, api.GetNode("node-0", &node))
+			if node.Maintenance != c.ExpectedMaintenance || node.MaintenanceReason != c.ExpectedReason {
+				t.Errorf("expected maintenance %t with reason '%s', got %t with '%s'", c.ExpectedMaintenance, c.ExpectedReason, node.Maintenance, node.MaintenanceReason)
+			}
+			if c.UpdateErr == nil && node.PowerInterface != "redfish" {
+				t.Errorf("expected the power_interface to be updated, got '%s'", node.PowerInterface)
+			}
+		})
+	}
+}
+
+func TestVerifyNodeOwnership(t *testing.T) {
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+		"node-0": {"uuid": "node-0", "owner": "project-a", "lessee": "project-b"},
+	}}
+
+	cases := []struct {
+		Scenario      string
+		Expected      map[string]string
+		ExpectedError string
+	}{
+		{
+			Scenario: "both landed",
+			Expected: map[string]string{"owner": "project-a", "lessee": "project-b"},
+		},
+		{
+			Scenario:      "lessee cleared",
+			Expected:      map[string]string{"lessee": ""},
+			ExpectedError: `the lessee of node node-0 is "project-b" rather than ""`,
+		},
+		{
+			Scenario:      "owner change denied",
+			Expected:      map[string]string{"owner": "project-c"},
+			ExpectedError: `the owner of node node-0 is "project-a" rather than "project-c"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			err := verifyNodeOwnership(api, "node-0", c.Expected)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestValidateEnabledInterfaces(t *testing.T) {
+	enabled := enabledInterfaces(&drivers.Driver{
+		EnabledDeployInterfaces: []string{"direct", "ramdisk"},
+		EnabledRaidInterfaces:   []string{"agent", "no-raid"},
+	})
+
+	cases := []struct {
+		Scenario      string
+		Interfaces    map[string]string
+		ExpectedError string
+	}{
+		{
+			Scenario:   "enabled",
+			Interfaces: map[string]string{"deploy_interface": "direct", "raid_interface": "no-raid"},
+		},
+		{
+			Scenario:      "not enabled",
+			Interfaces:    map[string]string{"deploy_interface": "direct", "raid_interface": "idrac-redfish"},
+			ExpectedError: "raid_interface 'idrac-redfish' isn't enabled for driver 'ipmi', valid values are: agent, no-raid",
+		},
+		{
+			Scenario:      "none enabled",
+			Interfaces:    map[string]string{"rescue_interface": "agent"},
+			ExpectedError: "rescue_interface 'agent' isn't enabled for driver 'ipmi'",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			err := validateEnabledInterfaces("ipmi", c.Interfaces, enabled)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestChangedPriorValues(t *testing.T) {
+	node := map[string]interface{}{
+		"name":            "node-0",
+		"owner":           nil,
+		"maintenance":     false,
+		"driver_info":     map[string]interface{}{"ipmi_address": "192.0.2.10", "ipmi_password": "******", "a/b": "c"},
+		"resource_class":  "baremetal",
+		"automated_clean": nil,
+	}
+
+	prior := map[string]interface{}{
+		"/name":                      "node-0",
+		"/owner":                     "",
+		"/maintenance":               false,
+		"/automated_clean":           false,
+		"/resource_class":            "compute",
+		"/driver_info/ipmi_address":  "192.0.2.11",
+		"/driver_info/ipmi_password": "secret",
+		"/driver_info/a~1b":          "c",
+		"/driver_info/ipmi_username": "admin",
+	}
+
+	changed := changedPriorValues(node, prior)
+	expected := []string{"/driver_info/ipmi_address", "/driver_info/ipmi_username", "/resource_class"}
+	if strings.Join(changed, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected %v, got %v", expected, changed)
+	}
+}
+
+func TestValidateRAIDConfig(t *testing.T) {
+	softwareRAID := `{"softwareRAIDVolumes": [{"level": "1", "physicalDisks": [{"deviceName": "/dev/sda"}, {"deviceName": "/dev/sdb"}]}, {"level": "0"}]}`
+
+	cases := []struct {
+		Scenario      string
+		RAIDInterface string
+		RAIDConfig    string
+		ExpectedError string
+	}{
+		{
+			Scenario:      "no raid config",
+			RAIDInterface: "idrac-wsman",
+		},
+		{
+			Scenario:      "software raid",
+			RAIDInterface: "agent",
+			RAIDConfig:    softwareRAID,
+		},
+		{
+			Scenario:      "software raid with hardware raid interface",
+			RAIDInterface: "idrac-wsman",
+			RAIDConfig:    softwareRAID,
+			ExpectedError: "software RAID requires the 'agent' raid_interface, got 'idrac-wsman'",
+		},
+		{
+			Scenario:      "software raid first volume not mirrored",
+			RAIDInterface: "agent",
+			RAIDConfig:    `{"softwareRAIDVolumes": [{"level": "0"}]}`,
+			ExpectedError: "the first software RAID volume must be RAID-1",
+		},
+		{
+			Scenario:      "software raid single disk",
+			RAIDInterface: "agent",
+			RAIDConfig:    `{"softwareRAIDVolumes": [{"level": "1", "physicalDisks": [{"deviceName": "/dev/sda"}]}]}`,
+			ExpectedError: "software RAID volume 0 needs at least 2 physical disks",
+		},
+		{
+			Scenario:      "hardware raid with agent raid interface",
+			RAIDInterface: "agent",
+			RAIDConfig:    `{"hardwareRAIDVolumes": [{"level": "0"}]}`,
+			ExpectedError: "does not support hardware RAID",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			err := validateRAIDConfig(c.RAIDInterface, c.RAIDConfig)
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestUnmaskedSecrets(t *testing.T) {
+	driverInfo := map[string]interface{}{
+		"ipmi_address":        "192.0.2.10",
+		"ipmi_username":       "admin",
+		"ipmi_password":       "******",
+		"redfish_password":    "hunter2",
+		"snmp_auth_key_token": "abc",
+		"deploy_kernel":       "http://192.0.2.1/ipa.kernel",
+	}
+
+	secrets := unmaskedSecrets(driverInfo)
+	if strings.Join(secrets, ",") != "redfish_password,snmp_auth_key_token" {
+		t.Errorf("expected redfish_password and snmp_auth_key_token, got %v", secrets)
+	}
+}
+
+func TestSaveProvisionProgress(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, map[string]interface{}{
+		"name":      "node-0",
+		"manage":    true,
+		"clean":     true,
+		"available": true,
+	})
+	d.SetId("node-0")
+
+	// As resourceNodeV1Create does when cleaning fails
+	progress := trackProvisionProgress(d, true)
+	progress.reach("manage")
+	progress.revert()
+
+	state := d.State()
+	expected := map[string]string{"name": "node-0", "manage": "true", "provision_milestone": "manage"}
+	for key, value := range expected {
+		if state.Attributes[key] != value {
+			t.Errorf("expected %s to be '%s', got '%s'", key, value, state.Attributes[key])
+		}
+	}
+	for _, key := range []string{"clean", "available"} {
+		if value := state.Attributes[key]; value == "true" {
+			t.Errorf("expected %s not to be saved, got '%s'", key, value)
+		}
+	}
+}
+
+func TestSaveUpdateProgress(t *testing.T) {
+	state := &terraform.InstanceState{
+		ID:         "node-0",
+		Attributes: map[string]string{"id": "node-0", "name": "node-0", "driver": "ipmi"},
+	}
+	config := map[string]interface{}{"name": "node-1", "driver": "ipmi", "manage": true, "clean": true}
+	diff, err := resourceNodeV1().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config), &Clients{})
+	th.AssertNoError(t, err)
+	d, err := schema.InternalMap(resourceNodeV1().Schema).Data(state, diff)
+	th.AssertNoError(t, err)
+
+	// As resourceNodeV1Update does when cleaning fails, the rename isn't saved as it may not have been applied
+	progress := trackProvisionProgress(d, false)
+	progress.reach("manage")
+	progress.revert()
+
+	saved := d.State()
+	expected := map[string]string{"name": "node-0", "manage": "true", "clean": "false", "provision_milestone": "manage"}
+	for key, value := range expected {
+		if saved.Attributes[key] != value {
+			t.Errorf("expected %s to be '%s', got '%s'", key, value, saved.Attributes[key])
+		}
+	}
+}
+
+func TestAllocateThenDeallocate(t *testing.T) {
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+		"node-0": {"uuid": "node-0", "allocation_uuid": "allocation-0", "instance_uuid": "allocation-0"},
+	}}
+	d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, map[string]interface{}{"name": "node-0"})
+	d.SetId("node-0")
+
+	node, err := getExtendedNode(api, "node-0")
+	th.AssertNoError(t, err)
+	th.AssertNoError(t, setNodeInstanceFields(d, node))
+	if uuid := d.Get("allocation_uuid"); uuid != "allocation-0" {
+		t.Errorf("expected allocation_uuid 'allocation-0', got '%s'", uuid)
+	}
+	if uuid := d.Get("instance_uuid"); uuid != "allocation-0" {
+		t.Errorf("expected instance_uuid 'allocation-0', got '%s'", uuid)
+	}
+
+	// Ironic clears both when the allocation is deleted
+	api.nodes["node-0"]["allocation_uuid"], api.nodes["node-0"]["instance_uuid"] = nil, nil
+	node, err = getExtendedNode(api, "node-0")
+	th.AssertNoError(t, err)
+	th.AssertNoError(t, setNodeInstanceFields(d, node))
+	for _, field := range []string{"allocation_uuid", "instance_uuid"} {
+		if uuid := d.Get(field); uuid != "" {
+			t.Errorf("expected %s to be cleared, got '%s'", field, uuid)
+		}
+	}
+
+	// Neither is part of the configuration, so the node doesn't try to put them back
+	diff, err := resourceNodeV1().Diff(context.Background(), d.State(), terraform.NewResourceConfigRaw(map[string]interface{}{"name": "node-0"}), &Clients{})
+	th.AssertNoError(t, err)
+	if diff != nil {
+		for _, field := range []string{"allocation_uuid", "instance_uuid"} {
+			if attr, ok := diff.Attributes[field]; ok && attr.Old != attr.New {
+				t.Errorf("expected no diff for %s, got %v", field, attr)
+			}
+		}
+	}
+}
+
+func TestManagedExtra(t *testing.T) {
+	managed := map[string]interface{}{"rack": "1", "role": "master"}
+	actual := map[string]interface{}{"rack": "2", "cmdb_id": "12345"}
+
+	result := managedExtra(managed, actual)
+	expected := map[string]interface{}{"rack": "2"}
+	if !reflect.DeepEqual(expected, result) {
+		t.Errorf("expected: %v, got: %v", expected, result)
+	}
+}
+
+func TestNodeConsoleURL(t *testing.T) {
+	api := &fakeIronicClient{consoles: map[string]*nodeConsole{
+		"node-0": {ConsoleEnabled: true, ConsoleInfo: &nodeConsoleInfo{Type: "shellinabox", URL: "http://192.168.1.1:8023"}},
+		"node-1": {ConsoleEnabled: false},
+	}}
+
+	cases := []struct {
+		Scenario string
+		UUID     string
+		Enabled  bool
+		Expected string
+	}{
+		{
+			Scenario: "enabled",
+			UUID:     "node-0",
+			Enabled:  true,
+			Expected: "http://192.168.1.1:8023",
+		},
+		{
+			Scenario: "disabled",
+			UUID:     "node-0",
+		},
+		{
+			Scenario: "disabled since the node was read",
+			UUID:     "node-1",
+			Enabled:  true,
+		},
+		{
+			Scenario: "console can't be read",
+			UUID:     "node-2",
+			Enabled:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			if url := nodeConsoleURL(api, c.UUID, c.Enabled); url != c.Expected {
+				t.Errorf("expected console URL '%s', got '%s'", c.Expected, url)
+			}
+		})
+	}
+}
+
+func TestBuildExtraCleanSteps(t *testing.T) {
+	cases := []struct {
+		Scenario            string
+		ManagementInterface string
+		Steps               string
+		ResetBMC            bool
+		Expected            []nodes.CleanStep
+		ExpectedError       string
+	}{
+		{
+			Scenario:            "none",
+			ManagementInterface: "idrac-redfish",
+		},
+		{
+			Scenario:            "vendor step",
+			ManagementInterface: "idrac-redfish",
+			Steps:               `[{"interface": "management", "step": "clear_job_queue"}]`,
+			Expected:            []nodes.CleanStep{{Interface: "management", Step: "clear_job_queue"}},
+		},
+		{
+			Scenario:            "reset the BMC after the steps",
+			ManagementInterface: "ilo5",
+			Steps:               `[{"interface": "deploy", "step": "erase_devices_metadata"}]`,
+			ResetBMC:            true,
+			Expected: []nodes.CleanStep{
+				{Interface: "deploy", Step: "erase_devices_metadata"},
+				{Interface: "management", Step: "reset_ilo"},
+			},
+		},
+		{
+			Scenario:            "step of another vendor",
+			ManagementInterface: "ilo",
+			Steps:               `[{"interface": "management", "step": "reset_idrac"}]`,
+			ExpectedError:       "clean step management.reset_idrac isn't supported by management_interface ilo",
+		},
+		{
+			Scenario:            "management step of another interface",
+			ManagementInterface: "redfish",
+			Steps:               `[{"interface": "management", "step": "reset_idrac"}]`,
+			Expected:            []nodes.CleanStep{{Interface: "management", Step: "reset_idrac"}},
+		},
+		{
+			Scenario:            "reset without a vendor interface",
+			ManagementInterface: "ipmitool",
+			ResetBMC:            true,
+			ExpectedError:       "reset_bmc isn't supported by management_interface 'ipmitool'",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			steps, err := buildExtraCleanSteps(c.ManagementInterface, c.Steps, c.ResetBMC)
+			if c.ExpectedError != "" {
+				th.AssertError(t, err, c.ExpectedError)
+				return
+			}
+			th.AssertNoError(t, err)
+			if !reflect.DeepEqual(c.Expected, steps) {
+				t.Errorf("expected steps %v, got %v", c.Expected, steps)
+			}
+		})
+	}
+}
+
+func TestAutomatedCleanRoundTrip(t *testing.T) {
+	on, off := true, false
+	cases := []struct {
+		Scenario     string
+		Ironic       *bool
+		Config       interface{}
+		ExpectedDiff bool
+		ExpectedOp   nodes.UpdateOperation
+	}{
+		{
+			Scenario: "inherited and unset",
+		},
+		{
+			Scenario: "inherited and default",
+			Config:   "default",
+		},
+		{
+			Scenario: "enabled and unset",
+			Ironic:   &on,
+		},
+		{
+			Scenario: "disabled and false",
+			Ironic:   &off,
+			Config:   "false",
+		},
+		{
+			Scenario:     "inherited and true",
+			Config:       "true",
+			ExpectedDiff: true,
+			ExpectedOp:   nodes.UpdateOperation{Op: nodes.ReplaceOp, Path: "/automated_clean", Value: true},
+		},
+		{
+			Scenario:     "disabled and default",
+			Ironic:       &off,
+			Config:       "default",
+			ExpectedDiff: true,
+			ExpectedOp:   nodes.UpdateOperation{Op: nodes.RemoveOp, Path: "/automated_clean"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			state := &terraform.InstanceState{ID: "node-0", Attributes: map[string]string{
+				"id":              "node-0",
+				"driver":          "ipmi",
+				"automated_clean": flattenAutomatedClean(c.Ironic),
+			}}
+			config := map[string]interface{}{"driver": "ipmi"}
+			if c.Config != nil {
+				config["automated_clean"] = c.Config
+			}
+
+			diff, err := resourceNodeV1().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config), &Clients{})
+			th.AssertNoError(t, err)
+			attr, changed := diff.GetAttribute("automated_clean")
+			if changed != c.ExpectedDiff {
+				t.Fatalf("expected an automated_clean diff %t, got %v", c.ExpectedDiff, attr)
+			}
+			if changed {
+				if op := automatedCleanUpdateOp(attr.New); !reflect.DeepEqual(c.ExpectedOp, op) {
+					t.Errorf("expected %v, got %v", c.ExpectedOp, op)
+				}
+			}
+		})
+	}
+}
+
+func TestProtectedCreateThenPlan(t *testing.T) {
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{}}
+	meta := &Clients{ironicAPI: api}
+	config := map[string]interface{}{"name": "node-0", "driver": "ipmi"}
+	protectedConfig := map[string]interface{}{"name": "node-0", "driver": "ipmi", "protected": true}
+
+	_, err := resourceNodeV1().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(protectedConfig), meta)
+	th.AssertError(t, err, "protected can only be set on an active node")
+
+	d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, config)
+	th.AssertNoDiagErrors(t, resourceNodeV1Create(context.Background(), d, meta))
+	_, err = resourceNodeV1().Diff(context.Background(), d.State(), terraform.NewResourceConfigRaw(protectedConfig), meta)
+	th.AssertError(t, err, "protected can only be set on an active node")
+
+	// Once the node is deployed, it can be protected, and stays so without a diff
+	api.nodes[d.Id()]["provision_state"] = "active"
+	th.AssertNoDiagErrors(t, resourceNodeV1Read(context.Background(), d, meta))
+	state := d.State()
+	diff, err := resourceNodeV1().Diff(context.Background(), state, terraform.NewResourceConfigRaw(protectedConfig), meta)
+	th.AssertNoError(t, err)
+	d, err = schema.InternalMap(resourceNodeV1().Schema).Data(state, diff)
+	th.AssertNoError(t, err)
+	th.AssertNoDiagErrors(t, resourceNodeV1Update(context.Background(), d, meta))
+	if protected, _ := api.nodes[d.Id()]["protected"].(bool); !protected {
+		t.Errorf("expected the node to be protected, got: %v", api.nodes[d.Id()])
+	}
+
+	diff, err = resourceNodeV1().Diff(context.Background(), d.State(), terraform.NewResourceConfigRaw(protectedConfig), meta)
+	th.AssertNoError(t, err)
+	if diff != nil && !diff.Empty() {
+		t.Errorf("expected no diff once the node is protected, got: %v", diff.Attributes)
+	}
+}
+
+func TestSecureBootAvailableNode(t *testing.T) {
+	// An available node isn't booted into an instance, so its management interface reports secure boot off even
+	// though the capability requests it
+	uefi, off := "uefi", false
+	api := &fakeIronicClient{
+		nodes: map[string]map[string]interface{}{
+			"node-0": {
+				"uuid":            "node-0",
+				"driver":          "redfish",
+				"provision_state": "available",
+				"properties":      map[string]interface{}{"capabilities": "boot_mode:uefi,secure_boot:true"},
+			},
+		},
+		bootStates: map[string]*nodeBootState{"node-0": {BootMode: &uefi, SecureBoot: &off}},
+	}
+	meta := &Clients{ironicAPI: api}
+	config := map[string]interface{}{"driver": "redfish", "secure_boot": true}
+
+	d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, config)
+	d.SetId("node-0")
+	th.AssertNoDiagErrors(t, resourceNodeV1Read(context.Background(), d, meta))
+	if !d.Get("secure_boot").(bool) || d.Get("current_secure_boot").(bool) {
+		t.Errorf("expected secure_boot from the capability and current_secure_boot from the node's state, got %t and %t",
+			d.Get("secure_boot").(bool), d.Get("current_secure_boot").(bool))
+	}
+
+	diff, err := resourceNodeV1().Diff(context.Background(), d.State(), terraform.NewResourceConfigRaw(config), meta)
+	th.AssertNoError(t, err)
+	if diff != nil && diff.Attributes["secure_boot"] != nil {
+		t.Errorf("expected no secure_boot diff while the capability is set, got: %v", diff.Attributes["secure_boot"])
+	}
+}
+
+func TestNodePortsAndConductor(t *testing.T) {
+	api := &fakeIronicClient{
+		nodes:      map[string]map[string]interface{}{},
+		conductors: []conductor{{Hostname: "conductor-0", ConductorGroup: "rack-2", Alive: true}},
+	}
+	meta := &Clients{ironicAPI: api}
+	d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, map[string]interface{}{
+		"driver":          "ipmi",
+		"conductor_group": "rack-1",
+		"ports":           []interface{}{map[string]interface{}{"address": "00:bb:4a:d0:5e:38"}},
+	})
+
+	// Ironic puts the node on a conductor serving another group
+	diags := resourceNodeV1Create(context.Background(), d, meta)
+	th.AssertNoDiagErrors(t, diags)
+	api.nodes[d.Id()]["conductor"] = "conductor-0"
+	diags = resourceNodeV1Read(context.Background(), d, meta)
+	th.AssertNoDiagErrors(t, diags)
+
+	if len(diags) != 1 || !strings.Contains(diags[0].Summary, "is managed by conductor conductor-0 in group 'rack-2'") {
+		t.Errorf("expected a conductor affinity warning, got: %v", diags)
+	}
+	portUUIDs := d.Get("port_uuids").(map[string]interface{})
+	if portUUIDs["00:bb:4a:d0:5e:38"] != "port-0" || api.ports["port-0"].NodeUUID != d.Id() {
+		t.Errorf("expected port port-0 to be created for the node, got %v and %v", portUUIDs, api.ports)
+	}
+	if port := d.Get("port").([]interface{}); len(port) != 1 || port[0].(map[string]interface{})["uuid"] != "port-0" {
+		t.Errorf("expected the node's ports to be read back, got: %v", port)
+	}
+}
+
+func TestUpdateFailsAfterPorts(t *testing.T) {
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{}}
+	meta := &Clients{ironicAPI: api}
+	created := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, map[string]interface{}{
+		"name":   "node-0",
+		"driver": "ipmi",
+		"ports":  []interface{}{map[string]interface{}{"address": "00:bb:4a:d0:5e:38"}},
+	})
+	th.AssertNoDiagErrors(t, resourceNodeV1Create(context.Background(), created, meta))
+
+	config := map[string]interface{}{
+		"name":            "node-1",
+		"driver":          "ipmi",
+		"conductor_group": "rack-2",
+		"ports": []interface{}{
+			map[string]interface{}{"address": "00:bb:4a:d0:5e:38"},
+			map[string]interface{}{"address": "00:bb:4a:d0:5e:39"},
+		},
+	}
+	diff, err := resourceNodeV1().Diff(context.Background(), created.State(), terraform.NewResourceConfigRaw(config), meta)
+	th.AssertNoError(t, err)
+	d, err := schema.InternalMap(resourceNodeV1().Schema).Data(created.State(), diff)
+	th.AssertNoError(t, err)
+
+	// The node's conductor can't be looked up, so moving it to another conductor group fails after a port is added
+	api.nodes[d.Id()]["conductor"] = "conductor-0"
+	diags := resourceNodeV1Update(context.Background(), d, meta)
+	th.AssertDiagError(t, diags, "could not change conductor group")
+
+	saved := d.State()
+	expected := map[string]string{
+		"name":                         "node-1",
+		"conductor_group":              "",
+		"ports.#":                      "2",
+		"port_uuids.%":                 "2",
+		"port_uuids.00:bb:4a:d0:5e:38": "port-0",
+		"port_uuids.00:bb:4a:d0:5e:39": "port-1",
+	}
+	for key, value := range expected {
+		if saved.Attributes[key] != value {
+			t.Errorf("expected %s to be '%s', got '%s'", key, value, saved.Attributes[key])
+		}
+	}
+	if api.ports["port-1"].Address != "00:bb:4a:d0:5e:39" {
+		t.Errorf("expected port-1 to be created, got %v", api.ports)
+	}
+}
+
+func TestRetiredNode(t *testing.T) {
+	interval := provisionStatePollInterval
+	provisionStatePollInterval = 0
+	defer func() { provisionStatePollInterval = interval }()
+
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{}, microversion: "1.52"}
+	meta := &Clients{ironicAPI: api}
+	config := map[string]interface{}{"driver": "ipmi", "retired": true, "retired_reason": "decommissioned"}
+
+	// The node isn't created if it can't be retired
+	d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, config)
+	th.AssertDiagError(t, resourceNodeV1Create(context.Background(), d, meta), "retiring nodes requires microversion 1.61 or later, got '1.52'")
+	if len(api.nodes) != 0 {
+		t.Errorf("expected no node to be created, got %v", api.nodes)
+	}
+
+	api.microversion = "1.61"
+	th.AssertNoDiagErrors(t, resourceNodeV1Create(context.Background(), d, meta))
+	if api.nodes[d.Id()]["retired"] != true || api.nodes[d.Id()]["retired_reason"] != "decommissioned" {
+		t.Errorf("expected the node to be retired, got %v", api.nodes[d.Id()])
+	}
+	if !d.Get("retired").(bool) || d.Get("retired_reason") != "decommissioned" {
+		t.Errorf("expected retired to be read back, got %v and '%s'", d.Get("retired"), d.Get("retired_reason"))
+	}
+
+	config["retired_reason"] = "replaced"
+	update := func() *schema.ResourceData {
+		diff, err := resourceNodeV1().Diff(context.Background(), d.State(), terraform.NewResourceConfigRaw(config), meta)
+		th.AssertNoError(t, err)
+		updated, err := schema.InternalMap(resourceNodeV1().Schema).Data(d.State(), diff)
+		th.AssertNoError(t, err)
+		return updated
+	}
+
+	api.microversion = "1.52"
+	th.AssertDiagError(t, resourceNodeV1Update(context.Background(), update(), meta), "retiring nodes requires microversion 1.61 or later, got '1.52'")
+	if api.nodes[d.Id()]["retired_reason"] != "decommissioned" {
+		t.Errorf("expected retired_reason not to be patched, got '%s'", api.nodes[d.Id()]["retired_reason"])
+	}
+
+	api.microversion = "1.61"
+	updated := update()
+	th.AssertNoDiagErrors(t, resourceNodeV1Update(context.Background(), updated, meta))
+	if api.nodes[d.Id()]["retired_reason"] != "replaced" {
+		t.Errorf("expected retired_reason to be patched, got '%s'", api.nodes[d.Id()]["retired_reason"])
+	}
+
+	// Destroying the retired node undeploys it, but leaves it enrolled
+	api.nodes[d.Id()]["provision_state"] = "active"
+	api.transitions = map[nodes.TargetProvisionState][]string{nodes.TargetDeleted: {"deleting", "cleaning"}}
+	th.AssertNoDiagErrors(t, resourceNodeV1Delete(context.Background(), updated, meta))
+	if _, ok := api.nodes[d.Id()]; !ok {
+		t.Errorf("expected the retired node to stay enrolled")
+	}
+	if state := api.nodes[d.Id()]["provision_state"]; state != "manageable" || len(api.targets) != 1 || api.targets[0] != nodes.TargetDeleted {
+		t.Errorf("expected the node to be undeployed to manageable, got '%s' after %v", state, api.targets)
+	}
+}
+
+func TestForceDelete(t *testing.T) {
+	locked := gophercloud.ErrDefault409{ErrUnexpectedResponseCode: gophercloud.ErrUnexpectedResponseCode{Actual: 409}}
+	maintenance := func(toggles int) []nodes.UpdateOperation {
+		var patches []nodes.UpdateOperation
+		for i := 0; i < toggles; i++ {
+			patches = append(patches,
+				nodes.UpdateOperation{Op: nodes.ReplaceOp, Path: "/maintenance", Value: true},
+				nodes.UpdateOperation{Op: nodes.ReplaceOp, Path: "/maintenance", Value: false})
+		}
+		return patches
+	}
+
+	cases := []struct {
+		Scenario        string
+		ForceDelete     bool
+		DeleteErrs      []error
+		ExpectedPatches []nodes.UpdateOperation
+		ExpectedDeletes int
+		ExpectedError   string
+	}{
+		{
+			Scenario:        "unlocked after toggling maintenance twice",
+			ForceDelete:     true,
+			DeleteErrs:      []error{locked, locked},
+			ExpectedPatches: maintenance(2),
+			ExpectedDeletes: 3,
+		},
+		{
+			Scenario:        "still locked after every attempt",
+			ForceDelete:     true,
+			DeleteErrs:      []error{locked, locked, locked, locked},
+			ExpectedPatches: maintenance(forceDeleteAttempts - 1),
+			ExpectedDeletes: forceDeleteAttempts,
+			ExpectedError:   "got 409 instead",
+		},
+		{
+			Scenario:        "without force_delete",
+			DeleteErrs:      []error{locked, locked},
+			ExpectedDeletes: 1,
+			ExpectedError:   "got 409 instead",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			api := &fakeIronicClient{
+				nodes:      map[string]map[string]interface{}{"node-0": {"uuid": "node-0", "provision_state": "available"}},
+				deleteErrs: c.DeleteErrs,
+			}
+			d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, map[string]interface{}{"force_delete": c.ForceDelete})
+			d.SetId("node-0")
+
+			diags := resourceNodeV1Delete(context.Background(), d, &Clients{ironicAPI: api})
+			if c.ExpectedError != "" {
+				if !diags.HasError() || !strings.Contains(diags[0].Summary, c.ExpectedError) {
+					t.Errorf("expected an error containing '%s', got: %v", c.ExpectedError, diags)
+				}
+			} else {
+				th.AssertNoDiagErrors(t, diags)
+				if _, ok := api.nodes["node-0"]; ok {
+					t.Error("expected node-0 to be deleted")
+				}
+			}
+			if !reflect.DeepEqual(c.ExpectedPatches, api.patches) {
+				t.Errorf("expected patches %v, got %v", c.ExpectedPatches, api.patches)
+			}
+			deletes := len(c.DeleteErrs) - len(api.deleteErrs)
+			if _, ok := api.nodes["node-0"]; !ok {
+				deletes++
+			}
+			if deletes != c.ExpectedDeletes {
+				t.Errorf("expected %d attempts to delete the node, got %d", c.ExpectedDeletes, deletes)
+			}
+		})
+	}
+}
+
+func TestCleanTrigger(t *testing.T) {
+	interval := provisionStatePollInterval
+	provisionStatePollInterval = 0
+	defer func() { provisionStatePollInterval = interval }()
+
+	biosSettings := `[{"name": "ProcVirtualization", "value": "Disabled"}]`
+	cases := []struct {
+		Scenario        string
+		BIOSSettings    string
+		Clean           []string
+		ExpectedTargets []nodes.TargetProvisionState
+		ExpectedState   string
+		ExpectedError   string
+	}{
+		{
+			Scenario:        "available node made available again",
+			BIOSSettings:    biosSettings,
+			Clean:           []string{"cleaning", "clean wait", "cleaning"},
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetManage, nodes.TargetClean, nodes.TargetProvide},
+			ExpectedState:   "available",
+		},
+		{
+			Scenario:        "cleaning fails",
+			BIOSSettings:    biosSettings,
+			Clean:           []string{"cleaning", "clean wait", "clean failed"},
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetManage, nodes.TargetClean},
+			ExpectedState:   "clean failed",
+			ExpectedError:   "last error was 'Failed to apply BIOS settings'",
+		},
+		{
+			Scenario:      "nothing to clean with",
+			ExpectedState: "available",
+			ExpectedError: "clean_trigger needs raid_config, bios_settings, clean_steps or reset_bmc to clean the node with",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			api := &fakeIronicClient{
+				nodes: map[string]map[string]interface{}{
+					"node-0": {"uuid": "node-0", "provision_state": "available", "last_error": "Failed to apply BIOS settings"},
+				},
+				biosSettings: map[string][]nodes.BIOSSetting{"node-0": {{Name: "ProcVirtualization", Value: "Disabled"}}},
+				transitions:  map[nodes.TargetProvisionState][]string{nodes.TargetClean: c.Clean},
+			}
+			meta := &Clients{ironicAPI: api}
+			state := &terraform.InstanceState{
+				ID: "node-0",
+				Attributes: map[string]string{
+					"id": "node-0", "driver": "ipmi", "provision_state": "available", "bios_settings": c.BIOSSettings,
+				},
+			}
+			config := map[string]interface{}{"driver": "ipmi", "bios_settings": c.BIOSSettings, "clean_trigger": "1"}
+
+			diff, err := resourceNodeV1().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config), meta)
+			if err != nil {
+				th.AssertError(t, err, c.ExpectedError)
+				if len(api.targets) != 0 {
+					t.Errorf("expected no targets, got %v", api.targets)
+				}
+				return
+			}
+			d, err := schema.InternalMap(resourceNodeV1().Schema).Data(state, diff)
+			th.AssertNoError(t, err)
+
+			diags := resourceNodeV1Update(context.Background(), d, meta)
+			if c.ExpectedError != "" {
+				if !diags.HasError() || !strings.Contains(diags[0].Summary, c.ExpectedError) {
+					t.Errorf("expected an error containing '%s', got: %v", c.ExpectedError, diags)
+				}
+			} else {
+				th.AssertNoDiagErrors(t, diags)
+			}
+			if !reflect.DeepEqual(c.ExpectedTargets, api.targets) {
+				t.Errorf("expected targets %v, got %v", c.ExpectedTargets, api.targets)
+			}
+			if state := api.nodes["node-0"]["provision_state"]; state != c.ExpectedState {
+				t.Errorf("expected state '%s', got '%s'", c.ExpectedState, state)
+			}
+		})
+	}
+}
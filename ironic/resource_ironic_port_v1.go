@@ -1,16 +1,21 @@
 package ironic
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/ports"
-	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 func resourcePortV1() *schema.Resource {
 	return &schema.Resource{
-		Create: resourcePortV1Create,
-		Read:   resourcePortV1Read,
-		Update: resourcePortV1Update,
-		Delete: resourcePortV1Delete,
+		CreateContext: resourcePortV1Create,
+		ReadContext:   resourcePortV1Read,
+		UpdateContext: resourcePortV1Update,
+		DeleteContext: resourcePortV1Delete,
 
 		Schema: map[string]*schema.Schema{
 			"node_uuid": {
@@ -18,8 +23,9 @@ func resourcePortV1() *schema.Resource {
 				Optional: true,
 			},
 			"address": {
-				Type:     schema.TypeString,
-				Optional: true,
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsMACAddress,
 			},
 			"port_group_uuid": {
 				Type:     schema.TypeString,
@@ -49,70 +55,81 @@ func resourcePortV1() *schema.Resource {
 	}
 }
 
-func resourcePortV1Create(d *schema.ResourceData, meta interface{}) error {
-	client, err := meta.(*Clients).GetIronicClient()
+func resourcePortV1Create(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, err := meta.(*Clients).GetIronicClient(ctx)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	opts := portSchemaToCreateOpts(d)
 	result, err := ports.Create(client, opts).Extract()
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	d.SetId(result.UUID)
 
-	return resourcePortV1Read(d, meta)
+	return resourcePortV1Read(ctx, d, meta)
 }
 
-func resourcePortV1Read(d *schema.ResourceData, meta interface{}) error {
-	client, err := meta.(*Clients).GetIronicClient()
+func resourcePortV1Read(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, err := meta.(*Clients).GetIronicClient(ctx)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	port, err := ports.Get(client, d.Id()).Extract()
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 
 	err = d.Set("address", port.Address)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("node_uuid", port.NodeUUID)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	err = d.Set("port_group_id", port.PortGroupUUID)
+	err = d.Set("port_group_uuid", port.PortGroupUUID)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	err = d.Set("local_link_collection", port.LocalLinkConnection)
+	err = d.Set("local_link_connection", port.LocalLinkConnection)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("pxe_enabled", port.PXEEnabled)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("physical_network", port.PhysicalNetwork)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
 	err = d.Set("extra", port.Extra)
 	if err != nil {
-		return err
+		return diag.FromErr(err)
 	}
-	return d.Set("is_smart_nic", port.IsSmartNIC)
+	return diag.FromErr(d.Set("is_smart_nic", port.IsSmartNIC))
 }
 
-func resourcePortV1Update(d *schema.ResourceData, meta interface{}) error {
-	return nil
+func resourcePortV1Update(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	client, err := meta.(*Clients).GetIronicClient(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
+	opts := portSchemaToUpdateOpts(d)
+	if len(opts) > 0 {
+		if _, err := ports.Update(client, d.Id(), opts).Extract(); err != nil {
+			return diag.Errorf("could not update port %s: %s", d.Id(), err)
+		}
+	}
+
+	return resourcePortV1Read(ctx, d, meta)
 }
 
-func resourcePortV1Delete(d *schema.ResourceData, meta interface{}) error {
+func resourcePortV1Delete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	return nil
 
 }
@@ -122,15 +139,62 @@ func portSchemaToCreateOpts(d *schema.ResourceData) *ports.CreateOpts {
 	isSmartNic := d.Get("is_smart_nic").(bool)
 
 	opts := ports.CreateOpts{
-		NodeUUID:      d.Get("node_uuid").(string),
-		Address:       d.Get("address").(string),
-		PortGroupUUID: d.Get("port_group_uuid").(string),
-		//LocalLinkConnection: d.Get("local_link_collection").(map[string]interface{}),
-		PXEEnabled:      &pxeEnabled,
-		PhysicalNetwork: d.Get("physical_network").(string),
-		//Extra:               d.Get("extra").(map[string]interface{}),
-		IsSmartNIC: &isSmartNic,
+		NodeUUID:            d.Get("node_uuid").(string),
+		Address:             d.Get("address").(string),
+		PortGroupUUID:       d.Get("port_group_uuid").(string),
+		LocalLinkConnection: d.Get("local_link_connection").(map[string]interface{}),
+		PXEEnabled:          &pxeEnabled,
+		PhysicalNetwork:     d.Get("physical_network").(string),
+		Extra:               d.Get("extra").(map[string]interface{}),
+		IsSmartNIC:          &isSmartNic,
 	}
 
 	return &opts
 }
+
+// portPatchPaths maps the schema fields whose names differ from Ironic's to their patch paths.
+var portPatchPaths = map[string]string{
+	"port_group_uuid": "/portgroup_uuid",
+	"is_smart_nic":    "/is_smartnic",
+}
+
+// portPatchPath returns the patch path of a schema field.
+func portPatchPath(field string) string {
+	if path, ok := portPatchPaths[field]; ok {
+		return path
+	}
+	return fmt.Sprintf("/%s", field)
+}
+
+// portSchemaToUpdateOpts builds the patch for the fields that have changed. Emptied string fields are removed, so
+// Ironic unsets them rather than storing an empty string.
+func portSchemaToUpdateOpts(d *schema.ResourceData) (opts ports.UpdateOpts) {
+	for _, field := range []string{"node_uuid", "address", "port_group_uuid", "physical_network"} {
+		if !d.HasChange(field) {
+			continue
+		}
+		if value := d.Get(field).(string); value != "" {
+			opts = append(opts, ports.UpdateOperation{Op: ports.ReplaceOp, Path: portPatchPath(field), Value: value})
+		} else {
+			opts = append(opts, ports.UpdateOperation{Op: ports.RemoveOp, Path: portPatchPath(field)})
+		}
+	}
+
+	for _, field := range []string{"pxe_enabled", "is_smart_nic"} {
+		if d.HasChange(field) {
+			opts = append(opts, ports.UpdateOperation{Op: ports.ReplaceOp, Path: portPatchPath(field), Value: d.Get(field).(bool)})
+		}
+	}
+
+	for _, field := range []string{"local_link_connection", "extra"} {
+		if d.HasChange(field) {
+			opts = append(opts, ports.UpdateOperation{
+				Op:    ports.AddOp,
+				Path:  portPatchPath(field),
+				Value: d.Get(field).(map[string]interface{}),
+			})
+		}
+	}
+
+	return opts
+}
@@ -0,0 +1,46 @@
+// +build acceptance
+
+package ironic
+
+import (
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/ports"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestPortSchemaToUpdateOpts(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourcePortV1().Schema, map[string]interface{}{
+		"physical_network": "physnet1",
+		"is_smart_nic":     true,
+		"extra":            map[string]interface{}{"rack": "r1"},
+	})
+
+	expected := map[string]ports.UpdateOp{
+		"/physical_network": ports.ReplaceOp,
+		"/is_smartnic":      ports.ReplaceOp,
+		"/extra":            ports.AddOp,
+	}
+
+	opts := portSchemaToUpdateOpts(d)
+	if len(opts) != len(expected) {
+		t.Fatalf("expected %d operations, got %v", len(expected), opts)
+	}
+	for _, patch := range opts {
+		op := patch.(ports.UpdateOperation)
+		if expected[op.Path] != op.Op {
+			t.Errorf("unexpected operation %s on %s", op.Op, op.Path)
+		}
+	}
+}
+
+func TestPortAddressValidation(t *testing.T) {
+	validate := resourcePortV1().Schema["address"].ValidateFunc
+
+	if _, errs := validate("00:bb:4a:d0:5e:38", "address"); len(errs) != 0 {
+		t.Errorf("expected a valid MAC address, got %v", errs)
+	}
+	if _, errs := validate("00:bb:4a:d0:5e", "address"); len(errs) == 0 {
+		t.Error("expected an error for a malformed MAC address")
+	}
+}
@@ -0,0 +1,44 @@
+package ironic
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+)
+
+// conflictRetries is how many times a request Ironic rejected as busy is tried before giving up.
+const conflictRetries = 5
+
+// retryOnConflict calls fn until Ironic stops telling us it's busy (409), backing off exponentially between tries.
+// The backoff is randomized when jitter is set, from the provider's retry_jitter option.
+// Waiting between tries stops when ctx is done.
+func retryOnConflict(ctx context.Context, jitter bool, action string, fn func() error) (err error) {
+	interval := 5 * time.Second
+	for retries := 1; ; retries++ {
+		err = fn()
+		if _, ok := err.(gophercloud.ErrDefault409); !ok || retries == conflictRetries {
+			return err
+		}
+
+		wait := backoff(interval, jitter)
+		log.Printf("[DEBUG] Failed to %s: ironic is busy, will try again in %s", action, wait.String())
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		interval *= 2
+	}
+}
+
+// backoff returns how long to wait before retrying. With jitter, this is a random duration between half and all of
+// the interval.
+func backoff(interval time.Duration, jitter bool) time.Duration {
+	if !jitter {
+		return interval
+	}
+	return interval/2 + time.Duration(rand.Int63n(int64(interval/2)))
+}
@@ -0,0 +1,55 @@
+// +build acceptance
+
+package ironic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
+)
+
+func TestBackoff(t *testing.T) {
+	interval := 10 * time.Second
+
+	if wait := backoff(interval, false); wait != interval {
+		t.Errorf("expected %s without jitter, got %s", interval, wait)
+	}
+
+	for i := 0; i < 100; i++ {
+		if wait := backoff(interval, true); wait < interval/2 || wait >= interval {
+			t.Fatalf("expected wait between %s and %s, got %s", interval/2, interval, wait)
+		}
+	}
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	calls := 0
+	err := retryOnConflict(context.Background(), false, "test", func() error {
+		calls++
+		return gophercloud.ErrDefault404{}
+	})
+	th.AssertError(t, err, "Resource not found")
+	if calls != 1 {
+		t.Errorf("expected errors other than 409 not to be retried, got %d calls", calls)
+	}
+}
+
+func TestRetryOnConflict_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := retryOnConflict(ctx, false, "test", func() error {
+		calls++
+		return gophercloud.ErrDefault409{}
+	})
+	if err != context.Canceled {
+		t.Errorf("expected the retry to stop when the context is canceled, got: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected no more tries once the context is canceled, got %d calls", calls)
+	}
+}
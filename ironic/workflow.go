@@ -1,17 +1,20 @@
 package ironic
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"time"
 
-	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
 )
 
 // provisionStateWorkflow is used to track state through the process of updating's it's provision state
 type provisionStateWorkflow struct {
-	client *gophercloud.ServiceClient
+	ctx    context.Context
+	api    IronicClient
 	node   nodes.Node
 	uuid   string
 	target nodes.TargetProvisionState
@@ -20,23 +23,48 @@ type provisionStateWorkflow struct {
 	configDrive interface{}
 	deploySteps []nodes.DeployStep
 	cleanSteps  []nodes.CleanStep
+
+	// The last deploy or clean step we saw Ironic running, and its progress
+	lastStep     string
+	lastProgress string
+
+	// Whether we undeployed the node, so the automated cleaning that follows is waited on rather than aborted
+	undeploying bool
+
+	// How many more times a failed deployment is retried
+	deployRetries int
 }
 
+// provisionStatePollInterval is how long to wait between polls of the node's provision state.
+var provisionStatePollInterval = 5 * time.Second
+
+// deployPollInterval is how long to wait between polls of a node being deployed, which takes a while.
+var deployPollInterval = 30 * time.Second
+
 // ChangeProvisionStateToTarget drives Ironic's state machine through the process to reach our desired end state. This requires multiple
 // possibly long-running steps.  If required, we'll build a config drive ISO for deployment.
-func ChangeProvisionStateToTarget(client *gophercloud.ServiceClient, uuid string, target nodes.TargetProvisionState, configDrive interface{}, deploySteps []nodes.DeployStep, cleanSteps []nodes.CleanStep) error {
+func ChangeProvisionStateToTarget(ctx context.Context, api IronicClient, uuid string, target nodes.TargetProvisionState, configDrive interface{}, deploySteps []nodes.DeployStep, cleanSteps []nodes.CleanStep) error {
+	_, err := changeProvisionStateWithLastStep(ctx, api, uuid, target, configDrive, deploySteps, cleanSteps)
+	return err
+}
+
+// changeProvisionStateWithLastStep is like ChangeProvisionStateToTarget, but also returns the last deploy or clean step
+// Ironic was seen running, if any.
+func changeProvisionStateWithLastStep(ctx context.Context, api IronicClient, uuid string, target nodes.TargetProvisionState, configDrive interface{}, deploySteps []nodes.DeployStep, cleanSteps []nodes.CleanStep) (string, error) {
 	// Run the provisionStateWorkflow - this could take a while
 	wf := provisionStateWorkflow{
 		target:      target,
-		client:      client,
-		wait:        5 * time.Second,
+		ctx:         ctx,
+		api:         api,
+		wait:        provisionStatePollInterval,
 		uuid:        uuid,
 		configDrive: configDrive,
 		deploySteps: deploySteps,
 		cleanSteps:  cleanSteps,
 	}
 
-	return wf.run()
+	err := wf.run()
+	return wf.lastStep, err
 }
 
 // Keep driving the state machine forward
@@ -49,16 +77,69 @@ func (workflow *provisionStateWorkflow) run() error {
 		done, err := workflow.next()
 		if err != nil {
 			_ = workflow.reloadNode() // to get the lastError
-			return fmt.Errorf("%w , last error was '%s'", err, workflow.node.LastError)
+			return newNodeError(workflow.uuid, err, workflow.node.ProvisionState, workflow.node.LastError)
 		}
 		if done {
 			return nil
 		}
 
-		time.Sleep(workflow.wait)
+		if err := workflow.sleep(); err != nil {
+			return err
+		}
 	}
 }
 
+// abortableStates are the states where Ironic is waiting on the ramdisk, and it's safe to abort the operation.
+var abortableStates = []string{"clean wait", "inspect wait", "wait call-back"}
+
+// abortTimeout bounds the requests made to abort the operation once its own context has ended.
+const abortTimeout = 30 * time.Second
+
+// sleep waits before polling Ironic again. If Terraform is interrupted in the meantime, the current operation is
+// aborted where that's safe, and an error is returned so we stop promptly rather than leave the node mid-transition
+// without saying so.
+func (workflow *provisionStateWorkflow) sleep() error {
+	ctx := workflow.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	select {
+	case <-time.After(workflow.wait):
+		return nil
+	case <-ctx.Done():
+	}
+
+	// The context also ends when the resource's timeout for the operation passes
+	reason := "interrupted"
+	if ctx.Err() == context.DeadlineExceeded {
+		reason = "timed out"
+	}
+
+	// The workflow's client makes its requests with the context that just ended, so use one of our own
+	abortCtx, cancel := context.WithTimeout(context.Background(), abortTimeout)
+	defer cancel()
+	api := workflow.api.WithContext(abortCtx)
+
+	state := workflow.node.ProvisionState
+	if err := api.GetNode(workflow.uuid, &workflow.node); err == nil {
+		state = workflow.node.ProvisionState
+	}
+	for _, abortable := range abortableStates {
+		if state != abortable {
+			continue
+		}
+		log.Printf("[WARN] Node %s is '%s' and the operation %s, aborting", workflow.uuid, state, reason)
+		opts := nodes.ProvisionStateOpts{Target: nodes.TargetAbort}
+		if err := api.ChangeProvisionState(workflow.uuid, opts); err != nil {
+			return fmt.Errorf("%s, and node %s is still '%s' as it could not be aborted: %s", reason, workflow.uuid, state, err)
+		}
+		return fmt.Errorf("%s while node %s was '%s', the operation was aborted", reason, workflow.uuid, state)
+	}
+
+	return fmt.Errorf("%s while node %s was '%s', Ironic will finish the operation on its own", reason, workflow.uuid, state)
+}
+
 // Do the next thing to get us to our target state
 func (workflow *provisionStateWorkflow) next() (bool, error) {
 	// Refresh the node on each run
@@ -115,7 +196,7 @@ func (workflow *provisionStateWorkflow) toClean() (bool, error) {
 		return true, err
 	}
 	if workflow.node.ProvisionState != string(nodes.Manageable) {
-		if err := ChangeProvisionStateToTarget(workflow.client, workflow.uuid, nodes.TargetManage, nil, nil, nil); err != nil {
+		if err := ChangeProvisionStateToTarget(workflow.ctx, workflow.api, workflow.uuid, nodes.TargetManage, nil, nil, nil); err != nil {
 			return true, err
 		}
 	}
@@ -139,6 +220,9 @@ func (workflow *provisionStateWorkflow) toClean() (bool, error) {
 		case "cleaning",
 			"clean wait":
 			// Not done, no error - Ironic is working
+			if err := workflow.sleep(); err != nil {
+				return true, err
+			}
 			continue
 		default:
 			return true, fmt.Errorf("could not clean node, node is currently '%s'", state)
@@ -154,7 +238,7 @@ func (workflow *provisionStateWorkflow) toInspect() (bool, error) {
 		return true, err
 	}
 	if workflow.node.ProvisionState != string(nodes.Manageable) {
-		if err := ChangeProvisionStateToTarget(workflow.client, workflow.uuid, nodes.TargetManage, nil, nil, nil); err != nil {
+		if err := ChangeProvisionStateToTarget(workflow.ctx, workflow.api, workflow.uuid, nodes.TargetManage, nil, nil, nil); err != nil {
 			return true, err
 		}
 	}
@@ -178,13 +262,33 @@ func (workflow *provisionStateWorkflow) toInspect() (bool, error) {
 		case "inspecting",
 			"inspect wait":
 			// Not done, no error - Ironic is working
+			if err := workflow.sleep(); err != nil {
+				return true, err
+			}
 			continue
+		case "inspect failed":
+			if isInspectionRamdiskError(workflow.node.LastError) {
+				return true, fmt.Errorf("inspection ramdisk failed to boot or report back, check inspection_kernel and inspection_ramdisk")
+			}
+			return true, fmt.Errorf("could not inspect node, node is currently '%s'", state)
 		default:
 			return true, fmt.Errorf("could not inspect node, node is currently '%s'", state)
 		}
 	}
 }
 
+// isInspectionRamdiskError determines if inspection failed because the ramdisk never booted or called back, rather
+// than due to an error while inspecting.
+func isInspectionRamdiskError(lastError string) bool {
+	lastError = strings.ToLower(lastError)
+	for _, hint := range []string{"ramdisk", "deploy_kernel", "timeout reached while inspecting"} {
+		if strings.Contains(lastError, hint) {
+			return true
+		}
+	}
+	return false
+}
+
 // Change a node to "available" state
 func (workflow *provisionStateWorkflow) toAvailable() (bool, error) {
 	switch state := workflow.node.ProvisionState; state {
@@ -192,8 +296,10 @@ func (workflow *provisionStateWorkflow) toAvailable() (bool, error) {
 		// We're done!
 		return true, nil
 	case "cleaning",
-		"clean wait":
-		// Not done, no error - Ironic is working
+		"clean wait",
+		"inspecting",
+		"inspect wait":
+		// Not done, no error - Ironic is working, inspection returns the node to manageable when it finishes
 		log.Printf("[DEBUG] Node %s is '%s', waiting for Ironic to finish.", workflow.uuid, state)
 		return false, nil
 	case "manageable":
@@ -223,10 +329,19 @@ func (workflow *provisionStateWorkflow) toActive() (bool, error) {
 		// Not done, no error - Ironic is working
 		log.Printf("[DEBUG] Node %s is '%s', waiting for Ironic to finish.", workflow.uuid, state)
 		return false, nil
+	case "deploy failed":
+		// Ironic can deploy a node again straight from deploy failed, which is worth it for transient failures
+		if workflow.deployRetries == 0 {
+			return true, fmt.Errorf("could not deploy node, node is currently '%s'", state)
+		}
+		workflow.deployRetries--
+		log.Printf("[WARN] Deploying node %s failed, retrying with %d retries left, last error was '%s'", workflow.uuid,
+			workflow.deployRetries, workflow.node.LastError)
+		return workflow.changeProvisionState(nodes.TargetActive)
 	case "available":
 		// From available, we can go to active
 		log.Printf("[DEBUG] Node %s is 'available', going to change to 'active'.", workflow.uuid)
-		workflow.wait = 30 * time.Second // Deployment takes a while
+		workflow.wait = deployPollInterval
 		return workflow.changeProvisionState(nodes.TargetActive)
 	default:
 		// Otherwise we have to get into available state first
@@ -248,18 +363,47 @@ func (workflow *provisionStateWorkflow) toDeleted() (bool, error) {
 		// We're done deleting the node
 		return true, nil
 	case "cleaning",
-		"deleting":
+		"deleting",
+		"adopting",
+		"deploying",
+		"inspecting",
+		"rescuing",
+		"unrescuing":
 		// Not done, no error - Ironic is working
 		log.Printf("[DEBUG] Node %s is '%s', waiting for Ironic to finish.", workflow.uuid, state)
 		return false, nil
+	case "rescue":
+		// A rescued node is unrescued back to active first, and then undeployed
+		log.Printf("[DEBUG] Node %s is '%s', going to unrescue it before deleting.", workflow.uuid, state)
+		return workflow.changeProvisionState(nodes.TargetUnrescue)
+	case "rescue wait":
+		// Aborting the rescue leaves the node in rescue failed, which is undeployed
+		log.Printf("[DEBUG] Node %s is '%s', aborting it before deleting.", workflow.uuid, state)
+		return workflow.changeProvisionState(nodes.TargetAbort)
 	case "active",
 		"wait call-back",
 		"deploy failed",
+		"rescue failed",
+		"unrescue failed",
 		"error":
+		// Nodes adopted into active are undeployed too, even though Ironic didn't deploy them
 		log.Printf("[DEBUG] Node %s is '%s', going to change to 'deleted'.", workflow.uuid, state)
+		workflow.undeploying = true
 		return workflow.changeProvisionState(nodes.TargetDeleted)
+	case "clean wait",
+		"inspect wait":
+		if state == "clean wait" && workflow.undeploying {
+			// Ironic cleans the nodes we undeploy, that's not a node left behind
+			log.Printf("[DEBUG] Node %s is being cleaned after undeploying, waiting for Ironic to finish.", workflow.uuid)
+			return false, nil
+		}
+		// A node left waiting on the ramdisk by a failed apply won't finish on its own, so abort it, which fails the
+		// operation, and then go through manageable
+		log.Printf("[DEBUG] Node %s is '%s', aborting it before deleting.", workflow.uuid, state)
+		return workflow.changeProvisionState(nodes.TargetAbort)
 	case "inspect failed",
-		"clean failed":
+		"clean failed",
+		"adopt failed":
 		// We have to get into manageable state first
 		log.Printf("[DEBUG] Node %s is '%s', going to change to 'manageable'.", workflow.uuid, state)
 		_, err := workflow.toManageable()
@@ -309,22 +453,67 @@ func (workflow *provisionStateWorkflow) changeProvisionState(target nodes.Target
 		return true, nil
 	}
 
-	interval := 5 * time.Second
-	for retries := 0; retries < 5; retries++ {
-		err = nodes.ChangeProvisionState(workflow.client, workflow.uuid, *opts).ExtractErr()
-		if _, ok := err.(gophercloud.ErrDefault409); ok {
-			log.Printf("[DEBUG] Failed to change provision state: ironic is busy, will retry in %s.", interval.String())
-			time.Sleep(interval)
-			interval *= 2
-		} else {
-			break
-		}
-	}
+	err = retryOnConflict(workflow.ctx, workflow.api.RetryJitter(), "change provision state", func() error {
+		return workflow.api.ChangeProvisionState(workflow.uuid, *opts)
+	})
 
-	return false, err
+	return false, forbiddenError(workflow.api, workflow.uuid, err)
 }
 
 // Call Ironic's API and reload the node's current state
 func (workflow *provisionStateWorkflow) reloadNode() error {
-	return nodes.Get(workflow.client, workflow.uuid).ExtractInto(&workflow.node)
+	if err := workflow.api.GetNode(workflow.uuid, &workflow.node); err != nil {
+		return err
+	}
+
+	workflow.observeStep()
+	return nil
+}
+
+// observeStep logs the step Ironic is currently running whenever it, or its progress, changes. Long running steps like
+// disk erasure otherwise give no sign they're still going.
+func (workflow *provisionStateWorkflow) observeStep() {
+	step, progress := currentStep(workflow.node)
+	if step == "" || (step == workflow.lastStep && progress == workflow.lastProgress) {
+		return
+	}
+
+	if progress != "" {
+		log.Printf("[INFO] Node %s is running step %s (%s)", workflow.uuid, step, progress)
+	} else {
+		log.Printf("[INFO] Node %s is running step %s", workflow.uuid, step)
+	}
+	workflow.lastStep, workflow.lastProgress = step, progress
+}
+
+// currentStep returns the clean or deploy step the node is running as "interface.step", and a description of how far
+// along it is, based on what Ironic reports in driver_internal_info.
+func currentStep(node nodes.Node) (string, string) {
+	kind, step := "clean", node.CleanStep
+	if len(step) == 0 {
+		kind, step = "deploy", node.DeployStep
+	}
+	if len(step) == 0 {
+		return "", ""
+	}
+
+	var progress []string
+	index, ok := node.DriverInternalInfo[kind+"_step_index"].(float64)
+	if steps, _ := node.DriverInternalInfo[kind+"_steps"].([]interface{}); ok && len(steps) > 0 {
+		progress = append(progress, fmt.Sprintf("%s step %d of %d", kind, int(index)+1, len(steps)))
+	}
+
+	keys := make([]string, 0, len(node.DriverInternalInfo))
+	for k := range node.DriverInternalInfo {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if percent, ok := node.DriverInternalInfo[k].(float64); ok && (strings.Contains(k, "progress") || strings.Contains(k, "percent")) {
+			progress = append(progress, fmt.Sprintf("%g%% complete", percent))
+			break
+		}
+	}
+
+	return fmt.Sprintf("%v.%v", step["interface"], step["step"]), strings.Join(progress, ", ")
 }
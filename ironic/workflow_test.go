@@ -0,0 +1,1168 @@
+// +build acceptance
+
+package ironic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/drivers"
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/ports"
+	"github.com/gophercloud/gophercloud/openstack/identity/v3/tokens"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	th "github.com/openshift-metal3/terraform-provider-ironic/testhelper"
+)
+
+func TestCurrentStep(t *testing.T) {
+	cases := []struct {
+		Scenario         string
+		Node             nodes.Node
+		ExpectedStep     string
+		ExpectedProgress string
+	}{
+		{
+			Scenario: "no step",
+			Node:     nodes.Node{},
+		},
+		{
+			Scenario: "clean step with progress",
+			Node: nodes.Node{
+				CleanStep: map[string]interface{}{"interface": "deploy", "step": "erase_devices"},
+				DriverInternalInfo: map[string]interface{}{
+					"clean_step_index":      float64(1),
+					"clean_steps":           []interface{}{map[string]interface{}{}, map[string]interface{}{}, map[string]interface{}{}},
+					"erase_devices_percent": float64(40),
+				},
+			},
+			ExpectedStep:     "deploy.erase_devices",
+			ExpectedProgress: "clean step 2 of 3, 40% complete",
+		},
+		{
+			Scenario: "deploy step without progress",
+			Node: nodes.Node{
+				DeployStep: map[string]interface{}{"interface": "deploy", "step": "write_image"},
+			},
+			ExpectedStep: "deploy.write_image",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			step, progress := currentStep(c.Node)
+			if step != c.ExpectedStep || progress != c.ExpectedProgress {
+				t.Errorf("expected %q (%q), got %q (%q)", c.ExpectedStep, c.ExpectedProgress, step, progress)
+			}
+		})
+	}
+}
+
+// fakeIronicClient is an in-memory IronicClient. Provision and power state changes complete immediately.
+type fakeIronicClient struct {
+	nodes map[string]map[string]interface{}
+
+	// The provision state targets requested, in order
+	targets []nodes.TargetProvisionState
+
+	// The power state targets requested, in order
+	powerTargets []nodes.TargetPowerState
+
+	// The config drive sent with each provision state target requested, in order
+	configDrives []interface{}
+
+	// If set, power state changes fail with this last_error, leaving the power state unchanged
+	powerFailure string
+
+	// Returned by UpdateNode, if set
+	updateErr error
+
+	// The patch operations UpdateNode applied, in order
+	patches []nodes.UpdateOperation
+
+	// Returned by DeleteNode, one per call, before it deletes the node
+	deleteErrs []error
+
+	// Returned when aborting a node, if set
+	abortErr error
+
+	// Returned by UpdateNode for patches of these paths, if set
+	updatePathErrs map[string]error
+
+	// BIOS settings of each node
+	biosSettings map[string][]nodes.BIOSSetting
+
+	// Logical disk properties of each driver
+	diskProperties map[string]map[string]interface{}
+
+	// Details of each driver
+	drivers map[string]*drivers.Driver
+
+	// Boot state of each node
+	bootStates map[string]*nodeBootState
+
+	// The states a node passes through after each target is requested, before it settles in the target's result.
+	// Each read of the node moves it on to the next state.
+	transitions map[nodes.TargetProvisionState][]string
+
+	// The states each node has yet to pass through
+	pending map[string][]string
+
+	// The console of each node, nodes without one return an error
+	consoles map[string]*nodeConsole
+
+	// Returned by ListConductors, and by hostname by GetConductor
+	conductors []conductor
+
+	// Ports by UUID
+	ports map[string]ports.Port
+
+	// Returned by CreatePort, if set
+	createPortErr error
+
+	// Returned by ProjectID
+	projectID string
+
+	// Returned by Microversion
+	microversion string
+
+	// The vendor passthru methods of each node, and what each method returns. Nodes without methods don't support
+	// vendor passthru.
+	vendorMethods map[string]map[string]vendorPassthruMethod
+	vendorResults map[string]interface{}
+}
+
+// provisionStateResults is the state a node ends up in for each target.
+var provisionStateResults = map[nodes.TargetProvisionState]string{
+	nodes.TargetManage:   "manageable",
+	nodes.TargetProvide:  "available",
+	nodes.TargetActive:   "active",
+	nodes.TargetDeleted:  "available",
+	nodes.TargetClean:    "manageable",
+	nodes.TargetInspect:  "manageable",
+	nodes.TargetUnrescue: "active",
+}
+
+func (c *fakeIronicClient) CreateNode(opts nodes.CreateOptsBuilder) (*nodes.Node, error) {
+	body, err := opts.ToNodeCreateMap()
+	if err != nil {
+		return nil, err
+	}
+	body["uuid"] = fmt.Sprintf("node-%d", len(c.nodes))
+	body["provision_state"] = "enroll"
+	c.nodes[body["uuid"].(string)] = body
+
+	var node nodes.Node
+	return &node, c.GetNode(body["uuid"].(string), &node)
+}
+
+func (c *fakeIronicClient) GetNode(uuid string, node interface{}) error {
+	body, ok := c.nodes[uuid]
+	if !ok {
+		return gophercloud.ErrDefault404{}
+	}
+	if pending := c.pending[uuid]; len(pending) > 0 {
+		body["provision_state"], c.pending[uuid] = pending[0], pending[1:]
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, node)
+}
+
+func (c *fakeIronicClient) UpdateNode(uuid string, opts nodes.UpdateOpts) (*nodes.Node, error) {
+	if c.updateErr != nil {
+		return nil, c.updateErr
+	}
+	for _, patch := range opts {
+		if err := c.updatePathErrs[patch.(nodes.UpdateOperation).Path]; err != nil {
+			return nil, err
+		}
+	}
+	for _, patch := range opts {
+		op := patch.(nodes.UpdateOperation)
+		c.patches = append(c.patches, op)
+		if op.Op == nodes.RemoveOp {
+			delete(c.nodes[uuid], strings.TrimPrefix(op.Path, "/"))
+			continue
+		}
+		c.nodes[uuid][strings.TrimPrefix(op.Path, "/")] = op.Value
+	}
+
+	var node nodes.Node
+	return &node, c.GetNode(uuid, &node)
+}
+
+func (c *fakeIronicClient) ListBIOSSettings(uuid string) ([]nodes.BIOSSetting, error) {
+	return c.biosSettings[uuid], nil
+}
+
+func (c *fakeIronicClient) GetDriverDiskProperties(driver string) (map[string]interface{}, error) {
+	return c.diskProperties[driver], nil
+}
+
+func (c *fakeIronicClient) GetDriver(driver string) (*drivers.Driver, error) {
+	details, ok := c.drivers[driver]
+	if !ok {
+		return nil, gophercloud.ErrDefault404{}
+	}
+	return details, nil
+}
+
+func (c *fakeIronicClient) ListVendorPassthruMethods(uuid string) (map[string]vendorPassthruMethod, error) {
+	methods, ok := c.vendorMethods[uuid]
+	if !ok {
+		return nil, gophercloud.ErrDefault400{}
+	}
+	return methods, nil
+}
+
+func (c *fakeIronicClient) CallVendorPassthru(uuid string, method string, httpMethod string, result interface{}) error {
+	b, err := json.Marshal(c.vendorResults[method])
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, result)
+}
+
+func (c *fakeIronicClient) ListConductors() ([]conductor, error) {
+	return c.conductors, nil
+}
+
+func (c *fakeIronicClient) GetConductor(hostname string) (*conductor, error) {
+	for i := range c.conductors {
+		if c.conductors[i].Hostname == hostname {
+			return &c.conductors[i], nil
+		}
+	}
+	return nil, gophercloud.ErrDefault404{}
+}
+
+func (c *fakeIronicClient) ListNodePorts(uuid string) ([]ports.Port, error) {
+	var result []ports.Port
+	for _, port := range c.ports {
+		if port.NodeUUID == uuid {
+			result = append(result, port)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Address < result[j].Address })
+	return result, nil
+}
+
+func (c *fakeIronicClient) CreatePort(opts ports.CreateOptsBuilder) (*ports.Port, error) {
+	if c.createPortErr != nil {
+		return nil, c.createPortErr
+	}
+	body, err := opts.ToPortCreateMap()
+	if err != nil {
+		return nil, err
+	}
+	for _, port := range c.ports {
+		if port.Address == body["address"] {
+			return nil, gophercloud.ErrDefault409{}
+		}
+	}
+	if c.ports == nil {
+		c.ports = make(map[string]ports.Port)
+	}
+	port := ports.Port{UUID: fmt.Sprintf("port-%d", len(c.ports)), NodeUUID: body["node_uuid"].(string), Address: body["address"].(string)}
+	c.ports[port.UUID] = port
+	return &port, nil
+}
+
+func (c *fakeIronicClient) DeletePort(uuid string) error {
+	if _, ok := c.ports[uuid]; !ok {
+		return gophercloud.ErrDefault404{}
+	}
+	delete(c.ports, uuid)
+	return nil
+}
+
+func (c *fakeIronicClient) ListNodes(opts nodeListOpts) ([]extendedNode, error) {
+	query, err := gophercloud.BuildQueryString(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	uuids := make([]string, 0, len(c.nodes))
+	for uuid := range c.nodes {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	var result []extendedNode
+	for _, uuid := range uuids {
+		matches := true
+		for filter, values := range query.Query() {
+			if fmt.Sprint(c.nodes[uuid][filter]) != values[0] {
+				matches = false
+			}
+		}
+		if !matches {
+			continue
+		}
+		var node extendedNode
+		if err := c.GetNode(uuid, &node); err != nil {
+			return nil, err
+		}
+		result = append(result, node)
+	}
+	return result, nil
+}
+
+func (c *fakeIronicClient) GetConsole(uuid string) (*nodeConsole, error) {
+	console, ok := c.consoles[uuid]
+	if !ok {
+		return nil, gophercloud.ErrDefault404{}
+	}
+	return console, nil
+}
+
+func (c *fakeIronicClient) GetBootState(uuid string) (*nodeBootState, error) {
+	return c.bootStates[uuid], nil
+}
+
+func (c *fakeIronicClient) AddTrait(uuid string, trait string) error {
+	traits, _ := c.nodes[uuid]["traits"].([]string)
+	for _, t := range traits {
+		if t == trait {
+			return nil
+		}
+	}
+	c.nodes[uuid]["traits"] = append(traits, trait)
+	return nil
+}
+
+func (c *fakeIronicClient) RemoveTrait(uuid string, trait string) error {
+	traits, _ := c.nodes[uuid]["traits"].([]string)
+	for i, t := range traits {
+		if t == trait {
+			c.nodes[uuid]["traits"] = append(traits[:i:i], traits[i+1:]...)
+			return nil
+		}
+	}
+	return gophercloud.ErrDefault404{}
+}
+
+func (c *fakeIronicClient) RetryJitter() bool {
+	return false
+}
+
+func (c *fakeIronicClient) WithContext(ctx context.Context) IronicClient {
+	return c
+}
+
+func (c *fakeIronicClient) ProjectID() string {
+	return c.projectID
+}
+
+func (c *fakeIronicClient) Microversion() string {
+	return c.microversion
+}
+
+func (c *fakeIronicClient) DeleteNode(uuid string) error {
+	if len(c.deleteErrs) > 0 {
+		err := c.deleteErrs[0]
+		c.deleteErrs = c.deleteErrs[1:]
+		return err
+	}
+	delete(c.nodes, uuid)
+	return nil
+}
+
+func (c *fakeIronicClient) ChangePowerState(uuid string, opts nodes.PowerStateOptsBuilder) error {
+	body, err := opts.ToPowerStateMap()
+	if err != nil {
+		return err
+	}
+	target := nodes.TargetPowerState(body["target"].(string))
+	c.powerTargets = append(c.powerTargets, target)
+	if c.powerFailure != "" {
+		c.nodes[uuid]["last_error"] = c.powerFailure
+		return nil
+	}
+	c.nodes[uuid]["power_state"] = expectedPowerState(target)
+	return nil
+}
+
+func (c *fakeIronicClient) ChangeProvisionState(uuid string, opts nodes.ProvisionStateOptsBuilder) error {
+	target := opts.(nodes.ProvisionStateOpts).Target
+	if target == nodes.TargetAbort && c.abortErr != nil {
+		return c.abortErr
+	}
+	c.targets = append(c.targets, target)
+	c.configDrives = append(c.configDrives, opts.(nodes.ProvisionStateOpts).ConfigDrive)
+	if state, _ := c.nodes[uuid]["provision_state"].(string); target == nodes.TargetAbort && strings.HasSuffix(state, " wait") {
+		c.nodes[uuid]["provision_state"] = strings.TrimSuffix(state, " wait") + " failed"
+		return nil
+	}
+	result := provisionStateResults[target]
+	if retired, _ := c.nodes[uuid]["retired"].(bool); retired && result == "available" {
+		// Retired nodes are cleaned back to manageable
+		result = "manageable"
+	}
+	if transitions := c.transitions[target]; len(transitions) > 0 {
+		if c.pending == nil {
+			c.pending = make(map[string][]string)
+		}
+		c.nodes[uuid]["provision_state"] = transitions[0]
+		c.pending[uuid] = append([]string{}, transitions[1:]...)
+		// A node stays in the failed state a transition ends in
+		if !strings.HasSuffix(transitions[len(transitions)-1], " failed") {
+			c.pending[uuid] = append(c.pending[uuid], result)
+		}
+		return nil
+	}
+	// A new transition replaces whatever the node was going through
+	delete(c.pending, uuid)
+	c.nodes[uuid]["provision_state"] = result
+	return nil
+}
+
+func (c *fakeIronicClient) SetRAIDConfig(uuid string, opts nodes.RAIDConfigOptsBuilder) error {
+	body, err := opts.ToRAIDConfigMap()
+	if err != nil {
+		return err
+	}
+	c.nodes[uuid]["target_raid_config"] = body
+	return nil
+}
+
+func TestProvisionStateWorkflow(t *testing.T) {
+	cases := []struct {
+		Scenario        string
+		State           string
+		Target          nodes.TargetProvisionState
+		ExpectedTargets []nodes.TargetProvisionState
+		ExpectedState   string
+		ExpectedError   string
+	}{
+		{
+			Scenario:        "enroll to available",
+			State:           "enroll",
+			Target:          nodes.TargetProvide,
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetManage, nodes.TargetProvide},
+			ExpectedState:   "available",
+		},
+		{
+			Scenario:        "inspect",
+			State:           "manageable",
+			Target:          nodes.TargetInspect,
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetInspect},
+			ExpectedState:   "manageable",
+		},
+		{
+			Scenario:      "already manageable",
+			State:         "manageable",
+			Target:        nodes.TargetManage,
+			ExpectedState: "manageable",
+		},
+		{
+			Scenario:        "delete after a failed deployment",
+			State:           "deploy failed",
+			Target:          nodes.TargetDeleted,
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetDeleted},
+			ExpectedState:   "available",
+		},
+		{
+			Scenario:        "delete after a failed clean",
+			State:           "clean failed",
+			Target:          nodes.TargetDeleted,
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetManage},
+			ExpectedState:   "manageable",
+		},
+		{
+			Scenario:        "delete while stuck waiting on the ramdisk",
+			State:           "clean wait",
+			Target:          nodes.TargetDeleted,
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetAbort, nodes.TargetManage},
+			ExpectedState:   "manageable",
+		},
+		{
+			Scenario:      "delete from an unexpected state",
+			State:         "service wait",
+			Target:        nodes.TargetDeleted,
+			ExpectedState: "service wait",
+			ExpectedError: "cannot delete node in state 'service wait'",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+				"node-0": {"uuid": "node-0", "provision_state": c.State},
+			}}
+			wf := provisionStateWorkflow{api: api, uuid: "node-0", target: c.Target}
+
+			err := wf.run()
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+			}
+			if !reflect.DeepEqual(c.ExpectedTargets, api.targets) {
+				t.Errorf("expected targets %v, got %v", c.ExpectedTargets, api.targets)
+			}
+			if state := api.nodes["node-0"]["provision_state"]; state != c.ExpectedState {
+				t.Errorf("expected state '%s', got '%s'", c.ExpectedState, state)
+			}
+		})
+	}
+}
+
+func TestInspectThenProvide(t *testing.T) {
+	interval := provisionStatePollInterval
+	provisionStatePollInterval = 0
+	defer func() { provisionStatePollInterval = interval }()
+
+	api := &fakeIronicClient{
+		nodes: map[string]map[string]interface{}{"node-0": {"uuid": "node-0", "provision_state": "enroll"}},
+		transitions: map[nodes.TargetProvisionState][]string{
+			nodes.TargetManage:  {"verifying"},
+			nodes.TargetInspect: {"inspecting", "inspect wait", "inspect wait"},
+			nodes.TargetProvide: {"cleaning", "clean wait"},
+		},
+	}
+
+	// As resourceNodeV1Create does with manage, inspect and available set
+	ctx := context.Background()
+	th.AssertNoError(t, manageNode(ctx, api, "node-0", true))
+	th.AssertNoError(t, ChangeProvisionStateToTarget(ctx, api, "node-0", nodes.TargetInspect, nil, nil, nil))
+	if state := api.nodes["node-0"]["provision_state"]; state != "manageable" {
+		t.Fatalf("expected the node to be 'manageable' once inspected, got '%s'", state)
+	}
+	th.AssertNoError(t, ChangeProvisionStateToTarget(ctx, api, "node-0", nodes.TargetProvide, nil, nil, nil))
+
+	expected := []nodes.TargetProvisionState{nodes.TargetManage, nodes.TargetInspect, nodes.TargetProvide}
+	if !reflect.DeepEqual(expected, api.targets) {
+		t.Errorf("expected targets %v, got %v", expected, api.targets)
+	}
+	if state := api.nodes["node-0"]["provision_state"]; state != "available" {
+		t.Errorf("expected state 'available', got '%s'", state)
+	}
+}
+
+func TestCleanWithoutProvide(t *testing.T) {
+	interval := provisionStatePollInterval
+	provisionStatePollInterval = 0
+	defer func() { provisionStatePollInterval = interval }()
+
+	api := &fakeIronicClient{
+		nodes: map[string]map[string]interface{}{"node-0": {"uuid": "node-0", "provision_state": "enroll"}},
+		transitions: map[nodes.TargetProvisionState][]string{
+			nodes.TargetManage: {"verifying"},
+			nodes.TargetClean:  {"cleaning", "clean wait", "cleaning"},
+		},
+	}
+	d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, map[string]interface{}{
+		"clean":         true,
+		"bios_settings": `[{"name": "ProcVirtualization", "value": "Disabled"}]`,
+	})
+	d.SetId("node-0")
+
+	// As resourceNodeV1Create does with clean set and available unset
+	ctx := context.Background()
+	th.AssertNoError(t, manageNode(ctx, api, "node-0", true))
+	th.AssertNoError(t, cleanNode(ctx, api, d, true))
+
+	expected := []nodes.TargetProvisionState{nodes.TargetManage, nodes.TargetClean}
+	if !reflect.DeepEqual(expected, api.targets) {
+		t.Errorf("expected targets %v, got %v", expected, api.targets)
+	}
+	if state := api.nodes["node-0"]["provision_state"]; state != "manageable" {
+		t.Errorf("expected state 'manageable', got '%s'", state)
+	}
+}
+
+func TestCleanOnlyChanged(t *testing.T) {
+	raidConfig := `{"hardwareRAIDVolumes": [{"level": "1", "sizeGibibytes": 100}]}`
+	biosSettings := `[{"name": "ProcVirtualization", "value": "Disabled"}]`
+	cases := []struct {
+		Scenario        string
+		RAIDLevel       string
+		BIOSValue       string
+		ResetBMC        bool
+		OnlyChanged     bool
+		ExpectedTargets []nodes.TargetProvisionState
+		ExpectedRAID    bool
+	}{
+		{
+			Scenario:    "everything applied",
+			RAIDLevel:   "1",
+			BIOSValue:   "Disabled",
+			OnlyChanged: true,
+		},
+		{
+			Scenario:        "RAID level changed",
+			RAIDLevel:       "0",
+			BIOSValue:       "Disabled",
+			OnlyChanged:     true,
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetClean},
+			ExpectedRAID:    true,
+		},
+		{
+			Scenario:        "BIOS setting drifted",
+			RAIDLevel:       "1",
+			BIOSValue:       "Enabled",
+			OnlyChanged:     true,
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetClean},
+		},
+		{
+			Scenario:        "everything applied with extra steps",
+			RAIDLevel:       "1",
+			BIOSValue:       "Disabled",
+			ResetBMC:        true,
+			OnlyChanged:     true,
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetClean},
+		},
+		{
+			Scenario:        "cleaning regardless",
+			RAIDLevel:       "1",
+			BIOSValue:       "Disabled",
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetClean},
+			ExpectedRAID:    true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			api := &fakeIronicClient{
+				nodes: map[string]map[string]interface{}{
+					"node-0": {
+						"uuid":            "node-0",
+						"provision_state": "manageable",
+						"raid_config": map[string]interface{}{
+							"logical_disks": []interface{}{map[string]interface{}{"raid_level": c.RAIDLevel, "size_gb": 100}},
+						},
+					},
+				},
+				biosSettings: map[string][]nodes.BIOSSetting{
+					"node-0": {{Name: "ProcVirtualization", Value: c.BIOSValue}},
+				},
+			}
+			d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, map[string]interface{}{
+				"clean":                true,
+				"raid_interface":       "idrac-wsman",
+				"raid_config":          raidConfig,
+				"bios_settings":        biosSettings,
+				"management_interface": "idrac-wsman",
+				"reset_bmc":            c.ResetBMC,
+			})
+			d.SetId("node-0")
+
+			th.AssertNoError(t, cleanNode(context.Background(), api, d, c.OnlyChanged))
+			if !reflect.DeepEqual(c.ExpectedTargets, api.targets) {
+				t.Errorf("expected targets %v, got %v", c.ExpectedTargets, api.targets)
+			}
+			if _, ok := api.nodes["node-0"]["target_raid_config"]; ok != c.ExpectedRAID {
+				t.Errorf("expected the RAID config to be set %t, got %t", c.ExpectedRAID, ok)
+			}
+		})
+	}
+}
+
+func TestRAIDConfigApplied(t *testing.T) {
+	size := 100
+	desired := []nodes.LogicalDisk{{RAIDLevel: nodes.RAID1, SizeGB: &size}, {RAIDLevel: nodes.RAID0}}
+	cases := []struct {
+		Scenario string
+		Current  map[string]interface{}
+		Expected bool
+	}{
+		{
+			Scenario: "applied",
+			Current: map[string]interface{}{"logical_disks": []interface{}{
+				map[string]interface{}{"raid_level": "1", "size_gb": 100},
+				map[string]interface{}{"raid_level": "0", "size_gb": 1200},
+			}},
+			Expected: true,
+		},
+		{
+			Scenario: "size changed",
+			Current: map[string]interface{}{"logical_disks": []interface{}{
+				map[string]interface{}{"raid_level": "1", "size_gb": 200},
+				map[string]interface{}{"raid_level": "0", "size_gb": 1200},
+			}},
+		},
+		{
+			Scenario: "disk missing",
+			Current: map[string]interface{}{"logical_disks": []interface{}{
+				map[string]interface{}{"raid_level": "1", "size_gb": 100},
+			}},
+		},
+		{
+			Scenario: "never configured",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			if applied := raidConfigApplied(desired, c.Current); applied != c.Expected {
+				t.Errorf("expected %t, got %t", c.Expected, applied)
+			}
+		})
+	}
+}
+
+func TestProvideWaitsForInspection(t *testing.T) {
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+		"node-0": {"uuid": "node-0", "provision_state": "inspect wait"},
+	}}
+	api.pending = map[string][]string{"node-0": {"inspect wait", "inspecting", "manageable"}}
+	wf := provisionStateWorkflow{api: api, uuid: "node-0", target: nodes.TargetProvide}
+
+	th.AssertNoError(t, wf.run())
+	expected := []nodes.TargetProvisionState{nodes.TargetProvide}
+	if !reflect.DeepEqual(expected, api.targets) {
+		t.Errorf("expected targets %v, got %v", expected, api.targets)
+	}
+	if state := api.nodes["node-0"]["provision_state"]; state != "available" {
+		t.Errorf("expected state 'available', got '%s'", state)
+	}
+}
+
+func TestDeleteNode(t *testing.T) {
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+		"node-0": {"uuid": "node-0", "provision_state": "available"},
+	}}
+
+	th.AssertNoError(t, deleteNode(context.Background(), api, "node-0"))
+	if _, ok := api.nodes["node-0"]; ok {
+		t.Error("expected node-0 to be deleted")
+	}
+}
+
+func TestDeleteAdoptedNode(t *testing.T) {
+	interval := provisionStatePollInterval
+	provisionStatePollInterval = 0
+	defer func() { provisionStatePollInterval = interval }()
+
+	cases := []struct {
+		Scenario        string
+		State           string
+		Pending         []string
+		Undeploy        []string
+		ExpectedTargets []nodes.TargetProvisionState
+	}{
+		{
+			Scenario:        "adopted",
+			State:           "active",
+			Undeploy:        []string{"deleting", "cleaning", "clean wait", "clean wait", "cleaning"},
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetDeleted},
+		},
+		{
+			Scenario:        "still adopting",
+			State:           "adopting",
+			Pending:         []string{"adopting", "active"},
+			Undeploy:        []string{"deleting", "clean wait"},
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetDeleted},
+		},
+		{
+			Scenario:        "cleaning fails after undeploying",
+			State:           "active",
+			Undeploy:        []string{"deleting", "clean wait", "clean failed"},
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetDeleted, nodes.TargetManage},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			api := &fakeIronicClient{
+				nodes:       map[string]map[string]interface{}{"node-0": {"uuid": "node-0", "provision_state": c.State}},
+				pending:     map[string][]string{"node-0": c.Pending},
+				transitions: map[nodes.TargetProvisionState][]string{nodes.TargetDeleted: c.Undeploy},
+			}
+			th.AssertNoError(t, deleteNode(context.Background(), api, "node-0"))
+			if !reflect.DeepEqual(c.ExpectedTargets, api.targets) {
+				t.Errorf("expected targets %v, got %v", c.ExpectedTargets, api.targets)
+			}
+			if _, ok := api.nodes["node-0"]; ok {
+				t.Error("expected node-0 to be deleted")
+			}
+		})
+	}
+}
+
+func TestDeleteNodeInTransition(t *testing.T) {
+	interval := provisionStatePollInterval
+	provisionStatePollInterval = 0
+	defer func() { provisionStatePollInterval = interval }()
+
+	cases := []struct {
+		Scenario        string
+		State           string
+		Pending         []string
+		ExpectedTargets []nodes.TargetProvisionState
+	}{
+		{
+			Scenario:        "still deploying",
+			State:           "deploying",
+			Pending:         []string{"deploying", "active"},
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetDeleted},
+		},
+		{
+			Scenario: "still inspecting",
+			State:    "inspecting",
+			Pending:  []string{"inspecting", "manageable"},
+		},
+		{
+			Scenario:        "still rescuing",
+			State:           "rescuing",
+			Pending:         []string{"rescuing", "rescue"},
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetUnrescue, nodes.TargetDeleted},
+		},
+		{
+			Scenario:        "rescued",
+			State:           "rescue",
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetUnrescue, nodes.TargetDeleted},
+		},
+		{
+			Scenario:        "waiting on the rescue ramdisk",
+			State:           "rescue wait",
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetAbort, nodes.TargetDeleted},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			api := &fakeIronicClient{
+				nodes:   map[string]map[string]interface{}{"node-0": {"uuid": "node-0", "provision_state": c.State}},
+				pending: map[string][]string{"node-0": c.Pending},
+			}
+			th.AssertNoError(t, deleteNode(context.Background(), api, "node-0"))
+			if !reflect.DeepEqual(c.ExpectedTargets, api.targets) {
+				t.Errorf("expected targets %v, got %v", c.ExpectedTargets, api.targets)
+			}
+			if _, ok := api.nodes["node-0"]; ok {
+				t.Error("expected node-0 to be deleted")
+			}
+		})
+	}
+}
+
+func TestPowerOffNode(t *testing.T) {
+	cases := []struct {
+		Scenario       string
+		Mode           string
+		PowerState     string
+		ExpectedTarget []nodes.TargetPowerState
+		ExpectedState  string
+	}{
+		{
+			Scenario:      "disabled",
+			PowerState:    "power on",
+			ExpectedState: "power on",
+		},
+		{
+			Scenario:       "soft",
+			Mode:           "soft",
+			PowerState:     "power on",
+			ExpectedTarget: []nodes.TargetPowerState{nodes.SoftPowerOff},
+			ExpectedState:  "power off",
+		},
+		{
+			Scenario:       "hard",
+			Mode:           "hard",
+			PowerState:     "power on",
+			ExpectedTarget: []nodes.TargetPowerState{nodes.PowerOff},
+			ExpectedState:  "power off",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+				"node-0": {"uuid": "node-0", "power_state": c.PowerState},
+			}}
+			d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, map[string]interface{}{
+				"driver":              "ipmi",
+				"power_off_on_delete": c.Mode,
+			})
+			d.SetId("node-0")
+
+			th.AssertNoError(t, powerOffNode(context.Background(), api, d))
+			if !reflect.DeepEqual(api.powerTargets, c.ExpectedTarget) {
+				t.Errorf("expected power targets %v, got %v", c.ExpectedTarget, api.powerTargets)
+			}
+			if state := api.nodes["node-0"]["power_state"]; state != c.ExpectedState {
+				t.Errorf("expected power state '%s', got '%s'", c.ExpectedState, state)
+			}
+		})
+	}
+}
+
+func TestDrainNode(t *testing.T) {
+	cases := []struct {
+		Scenario        string
+		ProvisionState  string
+		PowerState      string
+		ExpectedTargets []nodes.TargetProvisionState
+		ExpectedPower   []nodes.TargetPowerState
+		ExpectedState   string
+	}{
+		{
+			Scenario:        "active",
+			ProvisionState:  "active",
+			PowerState:      "power on",
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetDeleted},
+			ExpectedPower:   []nodes.TargetPowerState{nodes.PowerOff},
+			ExpectedState:   "available",
+		},
+		{
+			Scenario:       "available",
+			ProvisionState: "available",
+			PowerState:     "power on",
+			ExpectedPower:  []nodes.TargetPowerState{nodes.PowerOff},
+			ExpectedState:  "available",
+		},
+		{
+			Scenario:       "already powered off",
+			ProvisionState: "manageable",
+			PowerState:     "power off",
+			ExpectedState:  "manageable",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+				"node-0": {"uuid": "node-0", "provision_state": c.ProvisionState, "power_state": c.PowerState},
+			}}
+			d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, map[string]interface{}{
+				"driver":       "ipmi",
+				"maintenance":  true,
+				"drain":        true,
+				"drain_reason": "Replacing a DIMM",
+			})
+			d.SetId("node-0")
+
+			th.AssertNoError(t, drainNode(context.Background(), context.Background(), api, d))
+			if !reflect.DeepEqual(api.targets, c.ExpectedTargets) {
+				t.Errorf("expected provision targets %v, got %v", c.ExpectedTargets, api.targets)
+			}
+			if !reflect.DeepEqual(api.powerTargets, c.ExpectedPower) {
+				t.Errorf("expected power targets %v, got %v", c.ExpectedPower, api.powerTargets)
+			}
+			node := api.nodes["node-0"]
+			if node["provision_state"] != c.ExpectedState || node["power_state"] != "power off" {
+				t.Errorf("expected node to be '%s' and powered off, got '%s' and '%s'", c.ExpectedState, node["provision_state"], node["power_state"])
+			}
+			if node["maintenance"] != true || node["maintenance_reason"] != "Replacing a DIMM" {
+				t.Errorf("expected node in maintenance because 'Replacing a DIMM', got %v because '%v'", node["maintenance"], node["maintenance_reason"])
+			}
+		})
+	}
+}
+
+func TestChangePowerStateMismatch(t *testing.T) {
+	api := &fakeIronicClient{
+		nodes: map[string]map[string]interface{}{
+			"node-0": {"uuid": "node-0", "power_state": "power off", "fault": "power failure"},
+		},
+		powerFailure: "IPMI call failed: power on.",
+	}
+	d := schema.TestResourceDataRaw(t, resourceNodeV1().Schema, map[string]interface{}{"driver": "ipmi"})
+	d.SetId("node-0")
+
+	err := changePowerState(context.Background(), api, d, nodes.PowerOn)
+	th.AssertError(t, err, "node node-0 is 'power off' rather than 'power on' after changing its power state to 'power on', its fault is 'power failure' , last error was 'IPMI call failed: power on.'")
+}
+
+func TestUpdateNodeForbidden(t *testing.T) {
+	api := &fakeIronicClient{
+		nodes: map[string]map[string]interface{}{
+			"node-0": {"uuid": "node-0", "owner": "project-a", "lessee": "project-b"},
+		},
+		updateErr: gophercloud.ErrDefault403{},
+	}
+
+	opts := nodes.UpdateOpts{
+		nodes.UpdateOperation{Op: nodes.ReplaceOp, Path: "/name", Value: "node-1"},
+	}
+
+	_, err := UpdateNode(context.Background(), api, "node-0", opts)
+	th.AssertError(t, err, `the provider isn't authenticated as a project, which must be the node's owner ("project-a") or lessee ("project-b")`)
+
+	api.projectID = "project-c"
+	_, err = UpdateNode(context.Background(), api, "node-0", opts)
+	th.AssertError(t, err, `the project the provider authenticated as ("project-c") must be the node's owner ("project-a") or lessee ("project-b")`)
+}
+
+func TestProjectID(t *testing.T) {
+	provider := &gophercloud.ProviderClient{}
+	api := &gophercloudIronicClient{client: &gophercloud.ServiceClient{ProviderClient: provider}}
+	if project := api.ProjectID(); project != "" {
+		t.Errorf("expected no project without a token, got %q", project)
+	}
+
+	var result tokens.CreateResult
+	result.Body = map[string]interface{}{"token": map[string]interface{}{"project": map[string]interface{}{"id": "project-a", "name": "admin"}}}
+	th.AssertNoError(t, provider.SetTokenAndAuthResult(result))
+	if project := api.ProjectID(); project != "project-a" {
+		t.Errorf("expected the token's project project-a, got %q", project)
+	}
+}
+
+func TestProvisionStateWorkflowInterrupted(t *testing.T) {
+	cases := []struct {
+		Scenario        string
+		State           string
+		ExpectedTargets []nodes.TargetProvisionState
+		ExpectedError   string
+	}{
+		{
+			Scenario:        "waiting on the ramdisk",
+			State:           "wait call-back",
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetAbort},
+			ExpectedError:   "interrupted while node node-0 was 'wait call-back', the operation was aborted",
+		},
+		{
+			Scenario:      "deploying",
+			State:         "deploying",
+			ExpectedError: "interrupted while node node-0 was 'deploying', Ironic will finish the operation on its own",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+				"node-0": {"uuid": "node-0", "provision_state": c.State},
+			}}
+			wf := provisionStateWorkflow{ctx: ctx, api: api, uuid: "node-0", target: nodes.TargetActive, wait: time.Hour}
+
+			th.AssertError(t, wf.sleep(), c.ExpectedError)
+			if !reflect.DeepEqual(c.ExpectedTargets, api.targets) {
+				t.Errorf("expected targets %v, got %v", c.ExpectedTargets, api.targets)
+			}
+		})
+	}
+}
+
+// contextFakeIronicClient fails the requests made once its context is done, as gophercloud does.
+type contextFakeIronicClient struct {
+	*fakeIronicClient
+	ctx context.Context
+}
+
+func (c *contextFakeIronicClient) WithContext(ctx context.Context) IronicClient {
+	return &contextFakeIronicClient{fakeIronicClient: c.fakeIronicClient, ctx: ctx}
+}
+
+func (c *contextFakeIronicClient) GetNode(uuid string, node interface{}) error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	return c.fakeIronicClient.GetNode(uuid, node)
+}
+
+func (c *contextFakeIronicClient) ChangeProvisionState(uuid string, opts nodes.ProvisionStateOptsBuilder) error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	return c.fakeIronicClient.ChangeProvisionState(uuid, opts)
+}
+
+func TestProvisionStateWorkflowAbortWithOwnContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	fake := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+		"node-0": {"uuid": "node-0", "provision_state": "clean wait"},
+	}}
+	wf := provisionStateWorkflow{ctx: ctx, api: &contextFakeIronicClient{fake, ctx}, uuid: "node-0", target: nodes.TargetClean, wait: time.Hour}
+
+	th.AssertError(t, wf.sleep(), "interrupted while node node-0 was 'clean wait', the operation was aborted")
+	if !reflect.DeepEqual([]nodes.TargetProvisionState{nodes.TargetAbort}, fake.targets) {
+		t.Errorf("expected the abort to reach Ironic, got targets %v", fake.targets)
+	}
+}
+
+func TestProvisionStateWorkflowAbortFailed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	api := &fakeIronicClient{
+		nodes:    map[string]map[string]interface{}{"node-0": {"uuid": "node-0", "provision_state": "wait call-back"}},
+		abortErr: gophercloud.ErrDefault409{},
+	}
+	wf := provisionStateWorkflow{ctx: ctx, api: api, uuid: "node-0", target: nodes.TargetActive, wait: time.Hour}
+
+	err := wf.sleep()
+	th.AssertError(t, err, "interrupted, and node node-0 is still 'wait call-back' as it could not be aborted")
+	if strings.Contains(err.Error(), "was aborted") {
+		t.Errorf("expected the error not to claim the node was aborted, got: %s", err)
+	}
+}
+
+func TestProvisionStateWorkflowTimedOut(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+		"node-0": {"uuid": "node-0", "provision_state": "clean wait"},
+	}}
+	wf := provisionStateWorkflow{ctx: ctx, api: api, uuid: "node-0", target: nodes.TargetClean, wait: time.Hour}
+
+	th.AssertError(t, wf.sleep(), "timed out while node node-0 was 'clean wait', the operation was aborted")
+	if !reflect.DeepEqual([]nodes.TargetProvisionState{nodes.TargetAbort}, api.targets) {
+		t.Errorf("expected the clean to be aborted, got targets %v", api.targets)
+	}
+}
+
+func TestDeployRetries(t *testing.T) {
+	cases := []struct {
+		Scenario        string
+		Retries         int
+		ExpectedTargets []nodes.TargetProvisionState
+		ExpectedState   string
+		ExpectedError   string
+	}{
+		{
+			Scenario:        "retried",
+			Retries:         1,
+			ExpectedTargets: []nodes.TargetProvisionState{nodes.TargetActive},
+			ExpectedState:   "active",
+		},
+		{
+			Scenario:      "no retries",
+			ExpectedState: "deploy failed",
+			ExpectedError: "could not deploy node, node is currently 'deploy failed'",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Scenario, func(t *testing.T) {
+			api := &fakeIronicClient{nodes: map[string]map[string]interface{}{
+				"node-0": {"uuid": "node-0", "provision_state": "deploy failed", "last_error": "image download failed"},
+			}}
+			wf := provisionStateWorkflow{api: api, uuid: "node-0", target: nodes.TargetActive, deployRetries: c.Retries}
+
+			err := wf.run()
+			if c.ExpectedError == "" {
+				th.AssertNoError(t, err)
+			} else {
+				th.AssertError(t, err, c.ExpectedError)
+				if !errors.Is(err, ErrProvisionFailed) {
+					t.Errorf("expected a provisioning failure, got %v", err)
+				}
+			}
+			if !reflect.DeepEqual(c.ExpectedTargets, api.targets) {
+				t.Errorf("expected targets %v, got %v", c.ExpectedTargets, api.targets)
+			}
+			if state := api.nodes["node-0"]["provision_state"]; state != c.ExpectedState {
+				t.Errorf("expected state '%s', got '%s'", c.ExpectedState, state)
+			}
+		})
+	}
+}
@@ -1,15 +1,12 @@
 package main
 
 import (
-	"github.com/hashicorp/terraform-plugin-sdk/plugin"
-	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/plugin"
 	"github.com/openshift-metal3/terraform-provider-ironic/ironic"
 )
 
 func main() {
 	plugin.Serve(&plugin.ServeOpts{
-		ProviderFunc: func() terraform.ResourceProvider {
-			return ironic.Provider()
-		},
+		ProviderFunc: ironic.Provider,
 	})
 }
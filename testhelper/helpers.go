@@ -6,6 +6,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 )
 
 func init() {
@@ -25,6 +27,23 @@ func AssertError(t *testing.T, err error, expected string) {
 	}
 }
 
+func AssertNoDiagErrors(t *testing.T, diags diag.Diagnostics) {
+	for _, d := range diags {
+		if d.Severity == diag.Error {
+			t.Fatalf("err: %s", d.Summary)
+		}
+	}
+}
+
+func AssertDiagError(t *testing.T, diags diag.Diagnostics, expected string) {
+	for _, d := range diags {
+		if d.Severity == diag.Error && strings.Contains(d.Summary, expected) {
+			return
+		}
+	}
+	t.Fatalf("expected an error containing '%s', but was %v", expected, diags)
+}
+
 func RandomString(prefix string, length int) string {
 	b := make([]byte, length)
 	for i := range b {